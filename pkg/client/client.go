@@ -147,9 +147,19 @@ func (c *Client) newDatabase(ctx context.Context, databaseProto *databaseapi.Dat
 	}
 
 	// Iterate through partitions and open sessions
+	sessionOpts := []primitive.SessionOption{primitive.WithSessionTimeout(c.options.sessionTimeout)}
+	if c.options.lazy {
+		sessionOpts = append(sessionOpts, primitive.WithLazyOpen())
+	}
+	if c.options.sessionManager != nil {
+		sessionOpts = append(sessionOpts, primitive.WithSessionManager(c.options.sessionManager))
+	}
+	if len(c.options.callOpts) > 0 {
+		sessionOpts = append(sessionOpts, primitive.WithCallOptions(c.options.callOpts...))
+	}
 	sessions := make([]*primitive.Session, len(partitions))
 	for i, partition := range partitions {
-		session, err := primitive.NewSession(ctx, partition, primitive.WithSessionTimeout(c.options.sessionTimeout))
+		session, err := primitive.NewSession(ctx, partition, sessionOpts...)
 		if err != nil {
 			return nil, err
 		}
@@ -12,6 +12,12 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package counter provides a distributed counter primitive
+//
+// The counter service publishes no change events and supports no transactions. WatchThreshold therefore
+// polls the counter, so a crossing that is reverted between two polls is not observed, and TransactCounters
+// reverts the ops applied before a failed op with their inverse deltas, so other clients may observe the
+// counters while ops are applied or reverted.
 package counter
 
 import (
@@ -21,6 +27,8 @@ import (
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/util"
 	"google.golang.org/grpc"
+	"sync/atomic"
+	"time"
 )
 
 // Type is the counter type
@@ -33,6 +41,10 @@ type Client interface {
 }
 
 // Counter provides a distributed atomic counter
+// Errors returned by the counter are typed and can be classified with the predicates in the errors package.
+// Operations on a counter that has been closed or deleted fail with ErrClosed, although a closed counter may
+// still be deleted. Updates rejected because they would move the counter out of its bounds fail with
+// ErrBoundExceeded.
 type Counter interface {
 	primitive.Primitive
 
@@ -45,10 +57,48 @@ type Counter interface {
 	// Increment increments the counter by the given delta
 	Increment(ctx context.Context, delta int64) (int64, error)
 
+	// IncrementWithResult increments the counter by the given delta and returns the result of the increment
+	// The result includes the version at which the increment was applied, which is unique to the increment and
+	// increases with each change to the counter. Callers can record the version to recognize an increment they
+	// have already observed, e.g. when deciding whether to retry an increment after an ambiguous failure.
+	IncrementWithResult(ctx context.Context, delta int64) (*IncrementResult, error)
+
 	// Decrement decrements the counter by the given delta
 	Decrement(ctx context.Context, delta int64) (int64, error)
+
+	// CheckAndSet sets the value of the counter if its current value is the expected value
+	// A bool indicating whether the value was updated is returned.
+	CheckAndSet(ctx context.Context, expect int64, update int64) (bool, error)
+
+	// WatchThreshold watches the counter for crossings of the given threshold
+	// This is a non-blocking method. If the method returns without error, the counter value will be pushed onto
+	// the given channel each time it crosses the threshold in the given direction. A Rising crossing occurs when
+	// the value moves from below the threshold to at or above it, and a Falling crossing occurs when it moves
+	// from at or above the threshold to below it. The channel is closed once the context is cancelled or the
+	// counter is closed.
+	WatchThreshold(ctx context.Context, threshold int64, direction Direction, ch chan<- int64, opts ...WatchOption) error
+}
+
+// IncrementResult is the result of an increment
+type IncrementResult struct {
+	// Value is the value of the counter after the increment
+	Value int64
+
+	// Version is the partition index at which the increment was applied
+	Version uint64
 }
 
+// Direction is the direction of a threshold crossing
+type Direction int
+
+const (
+	// Rising indicates the counter value crossed the threshold upward
+	Rising Direction = iota
+
+	// Falling indicates the counter value crossed the threshold downward
+	Falling
+)
+
 // New creates a new counter for the given partitions
 func New(ctx context.Context, name primitive.Name, partitions []*primitive.Session) (Counter, error) {
 	i, err := util.GetPartitionIndex(name.Name, len(partitions))
@@ -71,6 +121,12 @@ func New(ctx context.Context, name primitive.Name, partitions []*primitive.Sessi
 type counter struct {
 	name     primitive.Name
 	instance *primitive.Instance
+	closed   int32
+}
+
+// isClosed returns whether the counter has been closed or deleted
+func (c *counter) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
 }
 
 func (c *counter) Name() primitive.Name {
@@ -78,6 +134,9 @@ func (c *counter) Name() primitive.Name {
 }
 
 func (c *counter) Get(ctx context.Context) (int64, error) {
+	if c.isClosed() {
+		return 0, ErrClosed
+	}
 	response, err := c.instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewCounterServiceClient(conn)
 		request := &api.GetRequest{
@@ -90,12 +149,15 @@ func (c *counter) Get(ctx context.Context) (int64, error) {
 		return response.Header, response, nil
 	})
 	if err != nil {
-		return 0, err
+		return 0, classifyError(err, false)
 	}
 	return response.(*api.GetResponse).Value, nil
 }
 
 func (c *counter) Set(ctx context.Context, value int64) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
 	_, err := c.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewCounterServiceClient(conn)
 		request := &api.SetRequest{
@@ -108,11 +170,22 @@ func (c *counter) Set(ctx context.Context, value int64) error {
 		}
 		return response.Header, response, nil
 	})
-	return err
+	return classifyError(err, true)
 }
 
 func (c *counter) Increment(ctx context.Context, delta int64) (int64, error) {
-	response, err := c.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+	result, err := c.IncrementWithResult(ctx, delta)
+	if err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+func (c *counter) IncrementWithResult(ctx context.Context, delta int64) (*IncrementResult, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	r, err := c.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewCounterServiceClient(conn)
 		request := &api.IncrementRequest{
 			Header: header,
@@ -125,12 +198,19 @@ func (c *counter) Increment(ctx context.Context, delta int64) (int64, error) {
 		return response.Header, response, nil
 	})
 	if err != nil {
-		return 0, err
+		return nil, classifyError(err, true)
 	}
-	return response.(*api.IncrementResponse).NextValue, nil
+	response := r.(*api.IncrementResponse)
+	return &IncrementResult{
+		Value:   response.NextValue,
+		Version: response.Header.Index,
+	}, nil
 }
 
 func (c *counter) Decrement(ctx context.Context, delta int64) (int64, error) {
+	if c.isClosed() {
+		return 0, ErrClosed
+	}
 	response, err := c.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewCounterServiceClient(conn)
 		request := &api.DecrementRequest{
@@ -144,15 +224,95 @@ func (c *counter) Decrement(ctx context.Context, delta int64) (int64, error) {
 		return response.Header, response, nil
 	})
 	if err != nil {
-		return 0, err
+		return 0, classifyError(err, true)
 	}
 	return response.(*api.DecrementResponse).NextValue, nil
 }
 
+func (c *counter) CheckAndSet(ctx context.Context, expect int64, update int64) (bool, error) {
+	if c.isClosed() {
+		return false, ErrClosed
+	}
+	response, err := c.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		client := api.NewCounterServiceClient(conn)
+		request := &api.CheckAndSetRequest{
+			Header: header,
+			Expect: expect,
+			Update: update,
+		}
+		response, err := client.CheckAndSet(ctx, request)
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	})
+	if err != nil {
+		return false, classifyError(err, true)
+	}
+	return response.(*api.CheckAndSetResponse).Succeeded, nil
+}
+
+func (c *counter) WatchThreshold(ctx context.Context, threshold int64, direction Direction, ch chan<- int64, opts ...WatchOption) error {
+	options := &watchOptions{
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt.applyWatch(options)
+	}
+
+	last, err := c.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(options.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				value, err := c.Get(ctx)
+				if err == ErrClosed {
+					return
+				} else if err != nil {
+					continue
+				}
+				if crossed(last, value, threshold, direction) {
+					select {
+					case ch <- value:
+					case <-ctx.Done():
+						return
+					}
+				}
+				last = value
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// crossed returns whether the change from prev to next crosses the threshold in the given direction
+func crossed(prev, next, threshold int64, direction Direction) bool {
+	switch direction {
+	case Rising:
+		return prev < threshold && next >= threshold
+	case Falling:
+		return prev >= threshold && next < threshold
+	}
+	return false
+}
+
 func (c *counter) Close(ctx context.Context) error {
-	return c.instance.Close(ctx)
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+	return classifyError(c.instance.Close(ctx), false)
 }
 
 func (c *counter) Delete(ctx context.Context) error {
-	return c.instance.Delete(ctx)
+	atomic.StoreInt32(&c.closed, 1)
+	return classifyError(c.instance.Delete(ctx), false)
 }
@@ -16,11 +16,15 @@ package counter
 
 import (
 	"context"
+	goerrors "errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/test"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"testing"
+	"time"
 )
 
 func TestCounterOperations(t *testing.T) {
@@ -70,6 +74,18 @@ func TestCounterOperations(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(10), value)
 
+	updated, err := counter.CheckAndSet(context.TODO(), 0, 20)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+
+	updated, err = counter.CheckAndSet(context.TODO(), 10, 20)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	updated, err = counter.CheckAndSet(context.TODO(), 20, 10)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
 	err = counter.Close(context.Background())
 	assert.NoError(t, err)
 
@@ -100,3 +116,245 @@ func TestCounterOperations(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), value)
 }
+
+func TestCounterIncrementWithResult(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	counter1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	counter2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	result1, err := counter1.IncrementWithResult(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result1.Value)
+	assert.NotEqual(t, uint64(0), result1.Version)
+
+	// Distinct increments of the same delta are applied at distinct versions
+	result2, err := counter2.IncrementWithResult(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result2.Value)
+	assert.True(t, result2.Version > result1.Version)
+
+	result3, err := counter1.IncrementWithResult(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result3.Value)
+	assert.True(t, result3.Version > result2.Version)
+}
+
+func TestCounterWatchThreshold(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	counter, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rising := make(chan int64)
+	err = counter.WatchThreshold(ctx, 10, Rising, rising, WithPollInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	falling := make(chan int64)
+	err = counter.WatchThreshold(ctx, 10, Falling, falling, WithPollInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	err = counter.Set(context.TODO(), 5)
+	assert.NoError(t, err)
+
+	select {
+	case <-rising:
+		t.Fatal("unexpected rising crossing")
+	case <-falling:
+		t.Fatal("unexpected falling crossing")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	err = counter.Set(context.TODO(), 15)
+	assert.NoError(t, err)
+
+	select {
+	case value := <-rising:
+		assert.Equal(t, int64(15), value)
+	case <-falling:
+		t.Fatal("unexpected falling crossing")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rising crossing")
+	}
+
+	err = counter.Set(context.TODO(), 20)
+	assert.NoError(t, err)
+
+	select {
+	case <-rising:
+		t.Fatal("unexpected rising crossing")
+	case <-falling:
+		t.Fatal("unexpected falling crossing")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	err = counter.Set(context.TODO(), 0)
+	assert.NoError(t, err)
+
+	select {
+	case value := <-falling:
+		assert.Equal(t, int64(0), value)
+	case <-rising:
+		t.Fatal("unexpected rising crossing")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no falling crossing")
+	}
+
+	cancel()
+	_, ok := <-rising
+	assert.False(t, ok)
+	_, ok = <-falling
+	assert.False(t, ok)
+}
+
+// failingCounter is a Counter that fails to increment
+type failingCounter struct {
+	Counter
+}
+
+func (c *failingCounter) Increment(ctx context.Context, delta int64) (int64, error) {
+	return 0, errors.NewUnavailable("increment failed")
+}
+
+func TestTransactCounters(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	debit, err := New(context.TODO(), primitive.NewName("default", "test", "default", "debit"), sessions)
+	assert.NoError(t, err)
+	credit, err := New(context.TODO(), primitive.NewName("default", "test", "default", "credit"), sessions)
+	assert.NoError(t, err)
+	other, err := New(context.TODO(), primitive.NewName("default", "test", "default", "other"), sessions)
+	assert.NoError(t, err)
+
+	err = TransactCounters(context.TODO(), []CounterOp{{Counter: debit, Delta: -10}, {Counter: credit, Delta: 10}})
+	assert.NoError(t, err)
+
+	value, err := debit.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-10), value)
+	value, err = credit.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), value)
+
+	// A failure in one op leaves all counters unchanged
+	err = TransactCounters(context.TODO(), []CounterOp{
+		{Counter: debit, Delta: -5},
+		{Counter: credit, Delta: 5},
+		{Counter: &failingCounter{Counter: other}, Delta: 1},
+	})
+	assert.Error(t, err)
+	assert.True(t, errors.IsUnavailable(err))
+
+	value, err = debit.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-10), value)
+	value, err = credit.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), value)
+	value, err = other.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), value)
+}
+
+func TestCounterErrors(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	counter, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	ch := make(chan int64)
+	err = counter.WatchThreshold(context.Background(), 10, Rising, ch, WithPollInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	assert.NoError(t, counter.Close(context.TODO()))
+	assert.NoError(t, counter.Close(context.TODO()))
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	err = counter.Set(context.TODO(), 1)
+	assert.Equal(t, ErrClosed, err)
+	assert.True(t, errors.IsUnavailable(err))
+	_, err = counter.Get(context.TODO())
+	assert.Equal(t, ErrClosed, err)
+	_, err = counter.Increment(context.TODO(), 1)
+	assert.Equal(t, ErrClosed, err)
+	_, err = counter.Decrement(context.TODO(), 1)
+	assert.Equal(t, ErrClosed, err)
+
+	// Commands whose context is done before they're sent may stall later requests in the session, so
+	// they're made last
+	counter, err = New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = counter.Set(ctx, 1)
+	assert.Error(t, err)
+	assert.True(t, errors.IsCanceled(err))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	_, err = counter.Increment(ctx, 1)
+	assert.Error(t, err)
+	assert.True(t, errors.IsTimeout(err))
+}
+
+func TestCounterClassifyError(t *testing.T) {
+	assert.NoError(t, classifyError(nil, true))
+	assert.Equal(t, ErrBoundExceeded, classifyError(errors.NewConflict("out of bounds"), true))
+	assert.True(t, errors.IsConflict(classifyError(errors.NewConflict("conflict"), false)))
+	assert.True(t, errors.IsNotFound(classifyError(errors.NewNotFound("not found"), true)))
+	assert.True(t, errors.IsCanceled(classifyError(context.Canceled, false)))
+	assert.True(t, errors.IsTimeout(classifyError(context.DeadlineExceeded, false)))
+	assert.True(t, errors.IsTimeout(classifyError(status.Error(codes.DeadlineExceeded, "deadline"), false)))
+	assert.True(t, errors.IsUnavailable(classifyError(status.Error(codes.Unavailable, "unavailable"), false)))
+	assert.True(t, errors.IsUnknown(classifyError(goerrors.New("unknown"), false)))
+
+	opErr := &errors.OperationError{
+		Partition: 1,
+		SessionID: 2,
+		Primitive: "test",
+		Operation: "command",
+		Err:       errors.NewConflict("out of bounds"),
+	}
+	err := classifyError(opErr, true)
+	assert.True(t, goerrors.Is(err, ErrBoundExceeded))
+	assert.True(t, errors.IsConflict(err))
+	var classified *errors.OperationError
+	assert.True(t, goerrors.As(err, &classified))
+	assert.Equal(t, 1, classified.Partition)
+	assert.Equal(t, uint64(2), classified.SessionID)
+}
@@ -0,0 +1,66 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	goerrors "errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrClosed is returned by operations on a counter that has been closed or deleted
+var ErrClosed = errors.NewUnavailable("counter is closed")
+
+// ErrBoundExceeded is returned when an update is rejected because it would move the counter out of its bounds
+// The counter service rejects such updates with a Conflict status. Counters are not currently bounded by the
+// counter service, but the error is returned by any service that rejects updates with a Conflict status.
+var ErrBoundExceeded = errors.NewConflict("counter bound exceeded")
+
+// classifyError maps an error returned by an operation on the counter to a typed error
+// Typed errors keep their type, except that updates rejected with a Conflict status are mapped to
+// ErrBoundExceeded. Context and gRPC errors that were not typed by the session are mapped to Canceled, Timeout
+// and Unavailable errors, and any other error to an Unknown error. If the error is an *errors.OperationError,
+// the error it wraps is classified and the context of the operation is retained.
+func classifyError(err error, update bool) error {
+	if err == nil {
+		return nil
+	}
+	if opErr, ok := err.(*errors.OperationError); ok {
+		classified := *opErr
+		classified.Err = classifyError(opErr.Err, update)
+		return &classified
+	}
+
+	var typed *errors.TypedError
+	if goerrors.As(err, &typed) {
+		if update && typed.Type == errors.Conflict {
+			return ErrBoundExceeded
+		}
+		return err
+	}
+
+	switch {
+	case err == context.Canceled || status.Code(err) == codes.Canceled:
+		return errors.NewCanceled(err.Error())
+	case err == context.DeadlineExceeded || status.Code(err) == codes.DeadlineExceeded:
+		return errors.NewTimeout(err.Error())
+	case status.Code(err) == codes.Unavailable:
+		return errors.NewUnavailable(err.Error())
+	default:
+		return errors.NewUnknown(err.Error())
+	}
+}
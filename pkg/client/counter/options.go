@@ -0,0 +1,41 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import "time"
+
+const defaultPollInterval = time.Second
+
+// WatchOption is an option for WatchThreshold calls
+type WatchOption interface {
+	applyWatch(options *watchOptions)
+}
+
+type watchOptions struct {
+	pollInterval time.Duration
+}
+
+// WithPollInterval sets the interval at which WatchThreshold polls the counter value
+func WithPollInterval(interval time.Duration) WatchOption {
+	return pollIntervalOption{interval: interval}
+}
+
+type pollIntervalOption struct {
+	interval time.Duration
+}
+
+func (o pollIntervalOption) applyWatch(options *watchOptions) {
+	options.pollInterval = o.interval
+}
@@ -0,0 +1,41 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semaphore
+
+import "time"
+
+const defaultPollInterval = 100 * time.Millisecond
+
+// Option is an option for a Semaphore
+type Option interface {
+	apply(options *options)
+}
+
+type options struct {
+	pollInterval time.Duration
+}
+
+// WithPollInterval sets the interval at which Acquire checks the counter for permits released by other instances
+func WithPollInterval(interval time.Duration) Option {
+	return pollIntervalOption{interval: interval}
+}
+
+type pollIntervalOption struct {
+	interval time.Duration
+}
+
+func (o pollIntervalOption) apply(options *options) {
+	options.pollInterval = o.interval
+}
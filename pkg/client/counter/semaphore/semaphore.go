@@ -0,0 +1,162 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semaphore
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/counter"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"sync"
+	"time"
+)
+
+// ErrOverReleased is returned when more permits are released than are held
+var ErrOverReleased = errors.NewConflict("released more permits than were acquired")
+
+// New returns a semaphore that limits the permits acquired from the given counter to the given number of permits
+// The counter's value is the number of permits held by all instances of the semaphore, so every instance built on
+// the counter must be created with the same number of permits, and the counter must not be updated other than
+// through a semaphore.
+func New(c counter.Counter, permits int, opts ...Option) (*Semaphore, error) {
+	if permits <= 0 {
+		return nil, errors.NewInvalid("semaphore permits must be positive")
+	}
+	options := &options{
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt.apply(options)
+	}
+	return &Semaphore{
+		counter:      c,
+		permits:      int64(permits),
+		pollInterval: options.pollInterval,
+		released:     make(chan struct{}),
+	}, nil
+}
+
+// Semaphore is a distributed counting semaphore built on a counter
+// Permits are acquired and released by atomically updating the counter with check-and-set operations, so the
+// number of permits held never exceeds the semaphore's permits and the number of available permits never goes
+// negative.
+type Semaphore struct {
+	counter      counter.Counter
+	permits      int64
+	pollInterval time.Duration
+	mu           sync.Mutex
+	released     chan struct{}
+}
+
+// Acquire acquires n permits, blocking until they're available or the context is done
+// If the context is done before the permits are acquired, a Timeout or Canceled error is returned.
+// Waiters are woken as soon as permits are released through the same Semaphore instance, and permits released
+// through other instances are observed by checking the counter at the interval configured with WithPollInterval.
+func (s *Semaphore) Acquire(ctx context.Context, n int) error {
+	if err := s.validate(n); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		released := s.waitRelease()
+		acquired, err := s.TryAcquire(ctx, n)
+		if err != nil {
+			return err
+		} else if acquired {
+			return nil
+		}
+
+		select {
+		case <-released:
+		case <-ticker.C:
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.NewTimeout("timed out waiting for permits")
+			}
+			return errors.NewCanceled("canceled waiting for permits")
+		}
+	}
+}
+
+// TryAcquire acquires n permits if they're available without waiting for permits to be released
+// A bool indicating whether the permits were acquired is returned.
+func (s *Semaphore) TryAcquire(ctx context.Context, n int) (bool, error) {
+	if err := s.validate(n); err != nil {
+		return false, err
+	}
+	for {
+		held, err := s.counter.Get(ctx)
+		if err != nil {
+			return false, err
+		}
+		if held+int64(n) > s.permits {
+			return false, nil
+		}
+		updated, err := s.counter.CheckAndSet(ctx, held, held+int64(n))
+		if err != nil {
+			return false, err
+		} else if updated {
+			return true, nil
+		}
+	}
+}
+
+// Release releases n permits
+// If fewer than n permits are held, ErrOverReleased is returned and no permits are released.
+func (s *Semaphore) Release(ctx context.Context, n int) error {
+	if err := s.validate(n); err != nil {
+		return err
+	}
+	for {
+		held, err := s.counter.Get(ctx)
+		if err != nil {
+			return err
+		}
+		if held < int64(n) {
+			return ErrOverReleased
+		}
+		updated, err := s.counter.CheckAndSet(ctx, held, held-int64(n))
+		if err != nil {
+			return err
+		} else if updated {
+			s.notifyRelease()
+			return nil
+		}
+	}
+}
+
+// validate returns an Invalid error if n permits can never be acquired from the semaphore
+func (s *Semaphore) validate(n int) error {
+	if n <= 0 || int64(n) > s.permits {
+		return errors.New(errors.Invalid, "permits must be between 1 and %d", s.permits)
+	}
+	return nil
+}
+
+// waitRelease returns a channel that's closed the next time permits are released through the semaphore
+func (s *Semaphore) waitRelease() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.released
+}
+
+// notifyRelease wakes the goroutines waiting for permits to be released through the semaphore
+func (s *Semaphore) notifyRelease() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.released)
+	s.released = make(chan struct{})
+}
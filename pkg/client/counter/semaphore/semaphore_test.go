@@ -0,0 +1,139 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semaphore
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/counter"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	c, err := counter.New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = New(c, 0)
+	assert.True(t, errors.IsInvalid(err))
+
+	semaphore, err := New(c, 2, WithPollInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	assert.True(t, errors.IsInvalid(semaphore.Acquire(context.TODO(), 3)))
+	assert.True(t, errors.IsInvalid(semaphore.Release(context.TODO(), 0)))
+	assert.Equal(t, ErrOverReleased, semaphore.Release(context.TODO(), 1))
+
+	acquired, err := semaphore.TryAcquire(context.TODO(), 2)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = semaphore.TryAcquire(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	assert.True(t, errors.IsTimeout(semaphore.Acquire(ctx, 1)))
+	cancel()
+
+	// A waiter is woken when permits are released through the same semaphore
+	ch := make(chan error)
+	go func() {
+		ch <- semaphore.Acquire(context.TODO(), 2)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, semaphore.Release(context.TODO(), 2))
+	select {
+	case err := <-ch:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("permits were not acquired")
+	}
+	assert.NoError(t, semaphore.Release(context.TODO(), 2))
+
+	held, err := c.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), held)
+}
+
+func TestSemaphoreContention(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	c, err := counter.New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	// Half of the goroutines share a semaphore, and the rest each create their own semaphore on the counter, so
+	// permits released by other instances are observed by watching the counter
+	shared, err := New(c, 2, WithPollInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	var holders int32
+	var maxHolders int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		semaphore := shared
+		if i%2 == 1 {
+			semaphore, err = New(c, 2, WithPollInterval(10*time.Millisecond))
+			assert.NoError(t, err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				err := semaphore.Acquire(ctx, 1)
+				cancel()
+				if !assert.NoError(t, err) {
+					return
+				}
+				n := atomic.AddInt32(&holders, 1)
+				for {
+					max := atomic.LoadInt32(&maxHolders)
+					if n <= max || atomic.CompareAndSwapInt32(&maxHolders, max, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				assert.NoError(t, semaphore.Release(context.TODO(), 1))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, maxHolders <= 2)
+	held, err := c.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), held)
+}
@@ -0,0 +1,52 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+)
+
+// CounterOp is an operation on a counter within a call to TransactCounters
+type CounterOp struct { //nolint:golint
+	// Counter is the counter to update
+	Counter Counter
+
+	// Delta is the amount by which to increment the counter
+	Delta int64
+}
+
+// TransactCounters increments each of the given counters by the delta of its op
+// Either all the ops are applied or, if any op fails, none of them are. If an op cannot be reverted, an Internal
+// error is returned and the ops that could not be reverted remain applied.
+func TransactCounters(ctx context.Context, ops []CounterOp) error {
+	for i, op := range ops {
+		if _, err := op.Counter.Increment(ctx, op.Delta); err != nil {
+			return revertCounters(ctx, ops[:i], err)
+		}
+	}
+	return nil
+}
+
+// revertCounters reverts the given applied ops in reverse order after the given error
+func revertCounters(ctx context.Context, ops []CounterOp, err error) error {
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := ops[i]
+		if _, revertErr := op.Counter.Decrement(ctx, op.Delta); revertErr != nil {
+			return errors.New(errors.Internal, "failed to revert counter %s after error '%s': %s", op.Counter.Name(), err, revertErr)
+		}
+	}
+	return err
+}
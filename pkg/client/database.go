@@ -120,8 +120,8 @@ func (d *Database) GetLeaderLatch(ctx context.Context, name string, opts ...lead
 }
 
 // GetList gets or creates a List with the given name
-func (d *Database) GetList(ctx context.Context, name string) (list.List, error) {
-	return list.New(ctx, primitive.NewName(d.Namespace, d.Name, d.scope, name), d.sessions)
+func (d *Database) GetList(ctx context.Context, name string, opts ...list.Option) (list.List, error) {
+	return list.New(ctx, primitive.NewName(d.Namespace, d.Name, d.scope, name), d.sessions, opts...)
 }
 
 // GetLock gets or creates a Lock with the given name
@@ -148,3 +148,10 @@ func (d *Database) GetSet(ctx context.Context, name string) (set.Set, error) {
 func (d *Database) GetValue(ctx context.Context, name string) (value.Value, error) {
 	return value.New(ctx, primitive.NewName(d.Namespace, d.Name, d.scope, name), d.sessions)
 }
+
+// HealthChecker returns a HealthChecker for the database's partition sessions
+// Every primitive in the database shares the same sessions, so the checker reports whether the database's
+// partitions are reachable by all of them.
+func (d *Database) HealthChecker() *primitive.HealthChecker {
+	return primitive.NewHealthChecker(d.sessions...)
+}
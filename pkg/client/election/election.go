@@ -12,18 +12,29 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package election provides a distributed leader election primitive
+//
+// The election service stores no candidate metadata, so the info passed to EnterWithInfo is written to a map
+// alongside the election, keyed by candidate ID, before the instance enters the election.
 package election
 
 import (
 	"context"
+	"fmt"
 	api "github.com/atomix/api/proto/atomix/election"
 	"github.com/atomix/api/proto/atomix/headers"
+	"github.com/google/uuid"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	_map "github.com/lucasbfernandes/go-client/pkg/client/map"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/util"
-	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"sync"
 )
 
+// infoSuffix is the suffix of the name of the map in which candidate info is stored
+const infoSuffix = "-candidate-info"
+
 // Option is an election option
 type Option interface {
 	apply(options *options)
@@ -31,7 +42,9 @@ type Option interface {
 
 // options is election options
 type options struct {
-	id string
+	id          string
+	autoReenter bool
+	historySize int
 }
 
 // idOption is an identifier option
@@ -44,12 +57,58 @@ func (o *idOption) apply(options *options) {
 }
 
 // WithID sets the election instance identifier
+// WithID is equivalent to WithCandidateID.
 func WithID(id string) Option {
+	return WithCandidateID(id)
+}
+
+// WithCandidateID sets the ID with which the instance is a candidate in the election
+// By default, each instance has a random candidate ID. Candidates are identified by their ID rather than
+// by their session, so instances with the same candidate ID are the same candidate: an instance entering
+// the election with the ID of an existing candidate does not add a new candidate or change the term, and
+// any of the instances may leave the election on behalf of the candidate. This allows a restarted process
+// to re-enter the election with its previous identity, and combined with WithAutoReenter, ties leadership
+// to a logical identity rather than to a session.
+func WithCandidateID(id string) Option {
 	return &idOption{
 		id: id,
 	}
 }
 
+// autoReenterOption is an automatic re-entry option
+type autoReenterOption struct{}
+
+func (o *autoReenterOption) apply(options *options) {
+	options.autoReenter = true
+}
+
+// WithAutoReenter enables automatic re-entry into the election when the session is reopened
+// If the election instance has entered the election and has not left it, the instance will re-enter the
+// election with the same candidate ID once the session has been reopened and the election re-created.
+func WithAutoReenter() Option {
+	return &autoReenterOption{}
+}
+
+// termHistorySizeOption is a term history size option
+type termHistorySizeOption struct {
+	size int
+}
+
+func (o *termHistorySizeOption) apply(options *options) {
+	options.historySize = o.size
+}
+
+// WithTermHistorySize sets the maximum number of terms recorded in the election's term history
+// Once the history is full, the oldest term is discarded when a new term is recorded. The default size is 16.
+func WithTermHistorySize(size int) Option {
+	if size <= 0 {
+		panic("term history size must be positive")
+	}
+	return &termHistorySizeOption{
+		size: size,
+	}
+}
+
 // Type is the election type
 const Type primitive.Type = "Election"
 
@@ -63,7 +122,7 @@ type Client interface {
 type Election interface {
 	primitive.Primitive
 
-	// ID returns the ID of the instance of the election
+	// ID returns the candidate ID of the instance of the election
 	ID() string
 
 	// GetTerm gets the current election term
@@ -72,12 +131,35 @@ type Election interface {
 	// Enter enters the instance into the election
 	Enter(ctx context.Context) (*Term, error)
 
+	// EnterWithInfo enters the instance into the election with the given info
+	// The info is advertised to other election instances in the Info of each Term in which the instance is a
+	// candidate.
+	EnterWithInfo(ctx context.Context, info []byte) (*Term, error)
+
 	// Leave removes the instance from the election
 	Leave(ctx context.Context) (*Term, error)
 
 	// Anoint assigns leadership to the instance with the given ID
 	Anoint(ctx context.Context, id string) (*Term, error)
 
+	// TransferLeadership anoints the instance with the given ID and waits until it's confirmed as the leader
+	// The election is watched before the instance is anointed, and the first term in which the instance is the
+	// leader is returned. If the instance is not a candidate in the election, a NotFound error is returned. If
+	// the context's deadline expires before the instance is confirmed as the leader, a Timeout error is
+	// returned, and the caller should not assume leadership was transferred.
+	TransferLeadership(ctx context.Context, id string) (*Term, error)
+
+	// LeadershipContext enters the instance into the election, waits until it's the leader, and returns a context
+	// that's canceled once it's no longer the leader
+	// The returned context is derived from the given context, and the term in which the instance was elected is
+	// returned with it. The election is watched until the returned context is done, and the context is canceled
+	// as soon as an event shows a new term or another leader, or if the watch is closed, since leadership can then
+	// no longer be observed. Leader-only work should run under the returned context, and callers should cancel the
+	// given context once the work has stopped to release the watch. Leadership loss is observed through the watch,
+	// so the work may briefly continue after another instance has been elected. If the given context is done
+	// before the instance is elected, its error is returned.
+	LeadershipContext(ctx context.Context) (context.Context, *Term, error)
+
 	// Promote increases the priority of the instance with the given ID in the election queue
 	Promote(ctx context.Context, id string) (*Term, error)
 
@@ -85,7 +167,15 @@ type Election interface {
 	Evict(ctx context.Context, id string) (*Term, error)
 
 	// Watch watches the election for changes
+	// Each event is typed by comparing its term to the previous term: EventLeaderChanged indicates a new term
+	// or leader, and EventCandidatesChanged indicates only the candidate queue changed.
 	Watch(ctx context.Context, c chan<- *Event) error
+
+	// TermHistory returns the most recent terms observed by the instance's watches, from oldest to newest
+	// The history is recorded in memory by the client and is only populated while the election is watched.
+	// Events observed by concurrent watches are recorded once. The number of terms recorded is bounded by
+	// WithTermHistorySize.
+	TermHistory() []Term
 }
 
 // newTerm returns a new term from the response term
@@ -111,6 +201,10 @@ type Term struct {
 
 	// Candidates is a list of candidates currently participating in the election
 	Candidates []string
+
+	// Info is a mapping of candidate IDs to the info with which the candidates entered the election
+	// Candidates that entered the election without info are not present in the mapping.
+	Info map[string][]byte
 }
 
 // EventType is the type of an Election event
@@ -119,6 +213,12 @@ type EventType string
 const (
 	// EventChanged indicates the election term changed
 	EventChanged EventType = "changed"
+
+	// EventLeaderChanged indicates the term ID or the leader changed
+	EventLeaderChanged EventType = "leaderChanged"
+
+	// EventCandidatesChanged indicates the leader is unchanged but the candidate queue was modified or reordered
+	EventCandidatesChanged EventType = "candidatesChanged"
 )
 
 // Event is an election event
@@ -133,7 +233,8 @@ type Event struct {
 // New creates a new election primitive
 func New(ctx context.Context, name primitive.Name, partitions []*primitive.Session, opts ...Option) (Election, error) {
 	options := &options{
-		id: uuid.New().String(),
+		id:          uuid.New().String(),
+		historySize: defaultTermHistorySize,
 	}
 	for _, opt := range opts {
 		opt.apply(options)
@@ -149,11 +250,25 @@ func New(ctx context.Context, name primitive.Name, partitions []*primitive.Sessi
 		return nil, err
 	}
 
-	return &election{
+	infoName := name
+	infoName.Name = name.Name + infoSuffix
+	info, err := _map.New(ctx, infoName, partitions[i:i+1])
+	if err != nil {
+		_ = instance.Close(ctx)
+		return nil, err
+	}
+
+	election := &election{
 		id:       options.id,
 		name:     name,
 		instance: instance,
-	}, nil
+		info:     info,
+		history:  newTermHistory(options.historySize),
+	}
+	if options.autoReenter {
+		election.removeListener = partitions[i].OnReopen(election.reenter)
+	}
+	return election, nil
 }
 
 // election is the default single-partition implementation of Election
@@ -161,6 +276,66 @@ type election struct {
 	id       string
 	name     primitive.Name
 	instance *primitive.Instance
+	info     _map.Map
+	history  *termHistory
+	entered  bool
+	closed   bool
+	// entryInfo is the info with which the instance entered the election
+	entryInfo []byte
+	mu        sync.RWMutex
+
+	removeListener func()
+}
+
+// reenter re-enters the election if the instance had entered it
+func (e *election) reenter(ctx context.Context) {
+	e.mu.RLock()
+	entered := e.entered && !e.closed
+	info := e.entryInfo
+	e.mu.RUnlock()
+	if entered {
+		if info != nil {
+			_, _ = e.EnterWithInfo(ctx, info)
+		} else {
+			_, _ = e.Enter(ctx)
+		}
+	}
+}
+
+// setEntered records whether the instance has entered the election and the info it entered with
+func (e *election) setEntered(entered bool, info []byte) {
+	e.mu.Lock()
+	e.entered = entered
+	e.entryInfo = info
+	e.mu.Unlock()
+}
+
+// getTerm returns a new term from the response term with the info of its candidates
+func (e *election) getTerm(ctx context.Context, term *api.Term) (*Term, error) {
+	t := newTerm(term)
+	if t == nil || len(t.Candidates) == 0 {
+		return t, nil
+	}
+
+	candidates := make(map[string]bool)
+	for _, candidate := range t.Candidates {
+		candidates[candidate] = true
+	}
+
+	ch := make(chan *_map.Entry)
+	if err := e.info.Entries(ctx, ch); err != nil {
+		return nil, err
+	}
+	for entry := range ch {
+		// Info may remain for candidates that were evicted or whose sessions expired
+		if candidates[entry.Key] {
+			if t.Info == nil {
+				t.Info = make(map[string][]byte)
+			}
+			t.Info[entry.Key] = entry.Value
+		}
+	}
+	return t, nil
 }
 
 func (e *election) Name() primitive.Name {
@@ -186,10 +361,32 @@ func (e *election) GetTerm(ctx context.Context) (*Term, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newTerm(response.(*api.GetTermResponse).Term), nil
+	return e.getTerm(ctx, response.(*api.GetTermResponse).Term)
 }
 
 func (e *election) Enter(ctx context.Context) (*Term, error) {
+	term, err := e.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.setEntered(true, nil)
+	return e.getTerm(ctx, term)
+}
+
+func (e *election) EnterWithInfo(ctx context.Context, info []byte) (*Term, error) {
+	if _, err := e.info.Put(ctx, e.ID(), info); err != nil {
+		return nil, err
+	}
+	term, err := e.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.setEntered(true, info)
+	return e.getTerm(ctx, term)
+}
+
+// enter enters the instance into the election
+func (e *election) enter(ctx context.Context) (*api.Term, error) {
 	response, err := e.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewLeaderElectionServiceClient(conn)
 		request := &api.EnterRequest{
@@ -205,7 +402,7 @@ func (e *election) Enter(ctx context.Context) (*Term, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newTerm(response.(*api.EnterResponse).Term), nil
+	return response.(*api.EnterResponse).Term, nil
 }
 
 func (e *election) Leave(ctx context.Context) (*Term, error) {
@@ -224,7 +421,11 @@ func (e *election) Leave(ctx context.Context) (*Term, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newTerm(response.(*api.WithdrawResponse).Term), nil
+	e.setEntered(false, nil)
+	if _, err := e.info.Remove(ctx, e.ID()); err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return e.getTerm(ctx, response.(*api.WithdrawResponse).Term)
 }
 
 func (e *election) Anoint(ctx context.Context, id string) (*Term, error) {
@@ -243,7 +444,103 @@ func (e *election) Anoint(ctx context.Context, id string) (*Term, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newTerm(response.(*api.AnointResponse).Term), nil
+	return e.getTerm(ctx, response.(*api.AnointResponse).Term)
+}
+
+func (e *election) TransferLeadership(ctx context.Context, id string) (*Term, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan *Event)
+	if err := e.Watch(ctx, ch); err != nil {
+		cancel()
+		return nil, err
+	}
+	defer func() {
+		cancel()
+		go func() {
+			for range ch {
+			}
+		}()
+	}()
+
+	term, err := e.Anoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if term.Leader == id {
+		return term, nil
+	}
+	if !containsCandidate(term, id) {
+		return nil, errors.NewNotFound(fmt.Sprintf("candidate %s is not in the election", id))
+	}
+
+	for event := range ch {
+		if event.Term.Leader == id {
+			term := event.Term
+			return &term, nil
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, errors.NewTimeout(fmt.Sprintf("timed out waiting for %s to become the leader", id))
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, errors.NewUnavailable(fmt.Sprintf("election watch closed before %s became the leader", id))
+}
+
+func (e *election) LeadershipContext(ctx context.Context) (context.Context, *Term, error) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	ch := make(chan *Event)
+	if err := e.Watch(leaderCtx, ch); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	term, err := e.Enter(ctx)
+	if err != nil {
+		cancel()
+		go func() {
+			for range ch {
+			}
+		}()
+		return nil, nil, err
+	}
+
+	for term.Leader != e.ID() {
+		event, ok := <-ch
+		if !ok {
+			cancel()
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			return nil, nil, errors.NewUnavailable("election watch closed before the instance became the leader")
+		}
+		eventTerm := event.Term
+		term = &eventTerm
+	}
+
+	// Cancel the context once the term changes or another instance is elected
+	go func() {
+		defer cancel()
+		for event := range ch {
+			if event.Term.ID != term.ID || event.Term.Leader != term.Leader {
+				cancel()
+				for range ch {
+				}
+				return
+			}
+		}
+	}()
+	return leaderCtx, term, nil
+}
+
+// containsCandidate returns whether the given ID is a candidate in the given term
+func containsCandidate(term *Term, id string) bool {
+	for _, candidate := range term.Candidates {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
 }
 
 func (e *election) Promote(ctx context.Context, id string) (*Term, error) {
@@ -262,7 +559,7 @@ func (e *election) Promote(ctx context.Context, id string) (*Term, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newTerm(response.(*api.PromoteResponse).Term), nil
+	return e.getTerm(ctx, response.(*api.PromoteResponse).Term)
 }
 
 func (e *election) Evict(ctx context.Context, id string) (*Term, error) {
@@ -281,10 +578,16 @@ func (e *election) Evict(ctx context.Context, id string) (*Term, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newTerm(response.(*api.EvictResponse).Term), nil
+	return e.getTerm(ctx, response.(*api.EvictResponse).Term)
 }
 
 func (e *election) Watch(ctx context.Context, ch chan<- *Event) error {
+	// Get the current term to use as the baseline for computing event types
+	term, err := e.GetTerm(ctx)
+	if err != nil {
+		return err
+	}
+
 	stream, err := e.instance.DoCommandStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewLeaderElectionServiceClient(conn)
 		request := &api.EventRequest{
@@ -304,21 +607,68 @@ func (e *election) Watch(ctx context.Context, ch chan<- *Event) error {
 
 	go func() {
 		defer close(ch)
+		prevTerm := *term
 		for event := range stream {
 			response := event.(*api.EventResponse)
+			// If candidate info cannot be read, the event is published without it
+			term, err := e.getTerm(ctx, response.Term)
+			if err != nil {
+				term = newTerm(response.Term)
+			}
+			nextTerm := *term
+			e.history.record(response.Header.Index, nextTerm)
 			ch <- &Event{
-				Type: EventChanged,
-				Term: *newTerm(response.Term),
+				Type: getEventType(prevTerm, nextTerm),
+				Term: nextTerm,
 			}
+			prevTerm = nextTerm
 		}
 	}()
 	return nil
 }
 
+func (e *election) TermHistory() []Term {
+	return e.history.list()
+}
+
+// getEventType returns the type of the event for a change from the given previous term to the given next term
+func getEventType(prevTerm Term, nextTerm Term) EventType {
+	if prevTerm.ID != nextTerm.ID || prevTerm.Leader != nextTerm.Leader {
+		return EventLeaderChanged
+	}
+	if len(prevTerm.Candidates) != len(nextTerm.Candidates) {
+		return EventCandidatesChanged
+	}
+	for i := range prevTerm.Candidates {
+		if prevTerm.Candidates[i] != nextTerm.Candidates[i] {
+			return EventCandidatesChanged
+		}
+	}
+	return EventChanged
+}
+
 func (e *election) Close(ctx context.Context) error {
+	e.setClosed()
+	if err := e.info.Close(ctx); err != nil {
+		return err
+	}
 	return e.instance.Close(ctx)
 }
 
 func (e *election) Delete(ctx context.Context) error {
+	e.setClosed()
+	if err := e.info.Delete(ctx); err != nil {
+		return err
+	}
 	return e.instance.Delete(ctx)
 }
+
+// setClosed marks the election closed to prevent it re-entering the election when the session is reopened
+func (e *election) setClosed() {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+	if e.removeListener != nil {
+		e.removeListener()
+	}
+}
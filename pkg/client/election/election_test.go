@@ -17,10 +17,12 @@ package election
 import (
 	"context"
 	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	_map "github.com/lucasbfernandes/go-client/pkg/client/map"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/test"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestElectionOperations(t *testing.T) {
@@ -70,7 +72,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election1.ID(), term.Candidates[0])
 
 	event := <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventLeaderChanged, event.Type)
 	assert.Equal(t, uint64(1), event.Term.ID)
 	assert.Equal(t, election1.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 1)
@@ -85,7 +87,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election2.ID(), term.Candidates[1])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventCandidatesChanged, event.Type)
 	assert.Equal(t, uint64(1), event.Term.ID)
 	assert.Equal(t, election1.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 2)
@@ -102,7 +104,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election3.ID(), term.Candidates[2])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventCandidatesChanged, event.Type)
 	assert.Equal(t, uint64(1), event.Term.ID)
 	assert.Equal(t, election1.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 3)
@@ -120,7 +122,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election2.ID(), term.Candidates[2])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventCandidatesChanged, event.Type)
 	assert.Equal(t, uint64(1), event.Term.ID)
 	assert.Equal(t, election1.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 3)
@@ -138,7 +140,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election2.ID(), term.Candidates[2])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventLeaderChanged, event.Type)
 	assert.Equal(t, uint64(2), event.Term.ID)
 	assert.Equal(t, election3.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 3)
@@ -156,7 +158,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election1.ID(), term.Candidates[2])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventLeaderChanged, event.Type)
 	assert.Equal(t, uint64(3), event.Term.ID)
 	assert.Equal(t, election2.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 3)
@@ -173,7 +175,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election1.ID(), term.Candidates[1])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventLeaderChanged, event.Type)
 	assert.Equal(t, uint64(4), event.Term.ID)
 	assert.Equal(t, election3.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 2)
@@ -188,7 +190,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election1.ID(), term.Candidates[0])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventLeaderChanged, event.Type)
 	assert.Equal(t, uint64(5), event.Term.ID)
 	assert.Equal(t, election1.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 1)
@@ -202,7 +204,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election1.ID(), term.Candidates[0])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventCandidatesChanged, event.Type)
 	assert.Equal(t, uint64(5), event.Term.ID)
 	assert.Equal(t, election1.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 2)
@@ -216,7 +218,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, election2.ID(), term.Candidates[0])
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventLeaderChanged, event.Type)
 	assert.Equal(t, uint64(6), event.Term.ID)
 	assert.Equal(t, election2.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 2)
@@ -226,7 +228,7 @@ func TestElectionOperations(t *testing.T) {
 	assert.NoError(t, err)
 
 	event = <-ch
-	assert.Equal(t, EventChanged, event.Type)
+	assert.Equal(t, EventLeaderChanged, event.Type)
 	assert.Equal(t, uint64(7), event.Term.ID)
 	assert.Equal(t, election1.ID(), event.Term.Leader)
 	assert.Len(t, event.Term.Candidates, 1)
@@ -268,3 +270,400 @@ func TestElectionOperations(t *testing.T) {
 	assert.Equal(t, "", term.Leader)
 	assert.Len(t, term.Candidates, 0)
 }
+
+func TestElectionAutoReenter(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions, primitive.WithSessionTimeout(time.Second))
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	election1, err := New(context.TODO(), name, sessions1, WithAutoReenter())
+	assert.NoError(t, err)
+
+	election2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	term, err := election1.Enter(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, election1.ID(), term.Leader)
+
+	// Reopen the session and wait for the original session to expire
+	err = sessions1[0].Reopen(context.TODO())
+	assert.NoError(t, err)
+	time.Sleep(3 * time.Second)
+
+	term, err = election2.GetTerm(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, election1.ID(), term.Leader)
+	assert.Len(t, term.Candidates, 1)
+	assert.Equal(t, election1.ID(), term.Candidates[0])
+}
+
+func TestElectionCandidateID(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	sessions3, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions3)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	election1, err := New(context.TODO(), name, sessions1, WithCandidateID("node-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "node-1", election1.ID())
+
+	election2, err := New(context.TODO(), name, sessions2, WithCandidateID("node-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "node-1", election2.ID())
+
+	election3, err := New(context.TODO(), name, sessions3)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "node-1", election3.ID())
+
+	term, err := election1.Enter(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), term.ID)
+	assert.Equal(t, "node-1", term.Leader)
+
+	term, err = election3.Enter(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-1", election3.ID()}, term.Candidates)
+
+	// Entering with the ID of an existing candidate does not add a candidate or change the term
+	term, err = election2.Enter(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), term.ID)
+	assert.Equal(t, "node-1", term.Leader)
+	assert.Equal(t, []string{"node-1", election3.ID()}, term.Candidates)
+
+	// Either instance may leave the election on behalf of the candidate
+	term, err = election2.Leave(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, election3.ID(), term.Leader)
+	assert.Equal(t, []string{election3.ID()}, term.Candidates)
+}
+
+func TestElectionEnterWithInfo(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	election1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+
+	election2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	ch := make(chan *Event)
+	err = election2.Watch(context.TODO(), ch)
+	assert.NoError(t, err)
+
+	term, err := election1.EnterWithInfo(context.TODO(), []byte("host1"))
+	assert.NoError(t, err)
+	assert.Equal(t, election1.ID(), term.Leader)
+	assert.Equal(t, "host1", string(term.Info[election1.ID()]))
+
+	event := <-ch
+	assert.Equal(t, EventLeaderChanged, event.Type)
+	assert.Equal(t, "host1", string(event.Term.Info[election1.ID()]))
+
+	term, err = election2.Enter(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, term.Candidates, 2)
+	assert.Len(t, term.Info, 1)
+	assert.Equal(t, "host1", string(term.Info[election1.ID()]))
+
+	event = <-ch
+	assert.Equal(t, EventCandidatesChanged, event.Type)
+
+	term, err = election1.Leave(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, election2.ID(), term.Leader)
+	assert.Len(t, term.Info, 0)
+
+	event = <-ch
+	assert.Equal(t, EventLeaderChanged, event.Type)
+	assert.Len(t, event.Term.Info, 0)
+
+	term, err = election2.GetTerm(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, term.Info, 0)
+}
+
+func TestElectionTermHistory(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	sessions3, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions3)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	election1, err := New(context.TODO(), name, sessions1, WithTermHistorySize(3))
+	assert.NoError(t, err)
+	election2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+	election3, err := New(context.TODO(), name, sessions3)
+	assert.NoError(t, err)
+
+	assert.Len(t, election1.TermHistory(), 0)
+
+	// Events observed by both watches are only recorded once
+	ch1 := make(chan *Event)
+	assert.NoError(t, election1.Watch(context.TODO(), ch1))
+	ch2 := make(chan *Event)
+	assert.NoError(t, election1.Watch(context.TODO(), ch2))
+
+	_, err = election1.Enter(context.TODO())
+	assert.NoError(t, err)
+	_, err = election2.Enter(context.TODO())
+	assert.NoError(t, err)
+	_, err = election3.Enter(context.TODO())
+	assert.NoError(t, err)
+	_, err = election1.Leave(context.TODO())
+	assert.NoError(t, err)
+	_, err = election2.Leave(context.TODO())
+	assert.NoError(t, err)
+
+	events := make([]*Event, 5)
+	for i := range events {
+		events[i] = <-ch1
+		<-ch2
+	}
+	assert.Equal(t, election1.ID(), events[0].Term.Leader)
+	assert.Equal(t, election3.ID(), events[4].Term.Leader)
+
+	// Only the most recent terms are retained
+	history := election1.TermHistory()
+	assert.Len(t, history, 3)
+	for i, term := range history {
+		assert.Equal(t, events[i+2].Term.ID, term.ID)
+		assert.Equal(t, events[i+2].Term.Leader, term.Leader)
+		assert.Equal(t, events[i+2].Term.Candidates, term.Candidates)
+	}
+	assert.Equal(t, election2.ID(), history[1].Leader)
+	assert.Equal(t, election3.ID(), history[2].Leader)
+}
+
+func TestElectionTransferLeadership(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	election1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+
+	election2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	term, err := election1.Enter(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, election1.ID(), term.Leader)
+
+	_, err = election2.Enter(context.TODO())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	term, err = election1.TransferLeadership(ctx, election2.ID())
+	assert.NoError(t, err)
+	assert.Equal(t, election2.ID(), term.Leader)
+
+	// The target is confirmed as the leader once the transfer has returned
+	term, err = election2.GetTerm(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, election2.ID(), term.Leader)
+
+	// Transferring leadership to the current leader returns immediately
+	term, err = election1.TransferLeadership(ctx, election2.ID())
+	assert.NoError(t, err)
+	assert.Equal(t, election2.ID(), term.Leader)
+
+	// Leadership cannot be transferred to an instance that is not a candidate
+	_, err = election1.TransferLeadership(ctx, "unknown")
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestElectionGuardWrites(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	election1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	election2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	mapName := primitive.NewName("default", "test", "default", "guarded")
+	map1, err := _map.New(context.TODO(), mapName, sessions1)
+	assert.NoError(t, err)
+	map2, err := _map.New(context.TODO(), mapName, sessions2)
+	assert.NoError(t, err)
+
+	// Writes cannot be guarded by an instance that is not the leader
+	_, err = GuardWrites(context.TODO(), election1, map1)
+	assert.Equal(t, ErrNotLeader, err)
+
+	_, err = election1.Enter(context.TODO())
+	assert.NoError(t, err)
+	_, err = election2.Enter(context.TODO())
+	assert.NoError(t, err)
+
+	guarded1, err := GuardWrites(context.TODO(), election1, map1)
+	assert.NoError(t, err)
+	_, err = guarded1.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+
+	_, err = GuardWrites(context.TODO(), election2, map2)
+	assert.Equal(t, ErrNotLeader, err)
+
+	// Once leadership is lost, the former leader's guarded writes fail and the new leader's succeed
+	_, err = election1.Anoint(context.TODO(), election2.ID())
+	assert.NoError(t, err)
+	guarded2, err := GuardWrites(context.TODO(), election2, map2)
+	assert.NoError(t, err)
+
+	_, err = guarded1.Put(context.TODO(), "foo", []byte("baz"))
+	assert.Equal(t, ErrNotLeader, err)
+	assert.True(t, errors.IsForbidden(err))
+	_, err = guarded1.Remove(context.TODO(), "foo")
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Equal(t, ErrNotLeader, guarded1.Clear(context.TODO()))
+
+	_, err = guarded2.Put(context.TODO(), "foo", []byte("qux"))
+	assert.NoError(t, err)
+
+	// Reads are not guarded
+	entry, err := guarded1.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "qux", string(entry.Value))
+
+	// The former leader's guard remains superseded even once it's leader again
+	_, err = election2.Anoint(context.TODO(), election1.ID())
+	assert.NoError(t, err)
+	_, err = guarded1.Put(context.TODO(), "foo", []byte("baz"))
+	assert.Equal(t, ErrNotLeader, err)
+	_, err = guarded2.Put(context.TODO(), "foo", []byte("baz"))
+	assert.Equal(t, ErrNotLeader, err)
+}
+
+func TestElectionLeadershipContext(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	election1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	election2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leaderCtx1, term, err := election1.LeadershipContext(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, election1.ID(), term.Leader)
+
+	// The second instance waits until it's elected
+	type leadership struct {
+		ctx  context.Context
+		term *Term
+		err  error
+	}
+	ch := make(chan leadership)
+	go func() {
+		leaderCtx, term, err := election2.LeadershipContext(ctx)
+		ch <- leadership{leaderCtx, term, err}
+	}()
+
+	// Candidates joining the election do not end the leadership
+	select {
+	case <-leaderCtx1.Done():
+		t.Fatal("leadership context canceled")
+	case <-ch:
+		t.Fatal("second instance elected")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// The context is canceled once another instance is anointed
+	_, err = election1.Anoint(context.TODO(), election2.ID())
+	assert.NoError(t, err)
+	select {
+	case <-leaderCtx1.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("leadership context not canceled")
+	}
+
+	var result leadership
+	select {
+	case result = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second instance not elected")
+	}
+	assert.NoError(t, result.err)
+	assert.Equal(t, election2.ID(), result.term.Leader)
+	assert.NoError(t, result.ctx.Err())
+
+	// The context is canceled with the given context
+	cancel()
+	select {
+	case <-result.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("leadership context not canceled")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	_map "github.com/lucasbfernandes/go-client/pkg/client/map"
+)
+
+// ErrNotLeader is returned by a guarded write when the instance is no longer the leader in the term in which
+// the guard was created
+var ErrNotLeader = errors.NewForbidden("not the leader")
+
+// GuardWrites returns a map whose writes are fenced by the current term of the given election
+// The instance must be the leader of the current term, or ErrNotLeader is returned. The term becomes the
+// guard's fencing term: before each write to the returned map, the current term of the election is read, and if
+// the term has changed or the instance is no longer its leader, the write is rejected with ErrNotLeader. Once the
+// fencing term has been superseded, every write is rejected, even if the instance is elected again, so a new
+// guard must be created for the new term. Reads are not guarded.
+// The term is checked before each write is sent, so a write that's sent just as the term changes may still be
+// applied, and the check adds a read of the term to every write.
+func GuardWrites(ctx context.Context, election Election, m _map.Map) (_map.Map, error) {
+	term, err := election.GetTerm(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if term == nil || term.Leader != election.ID() {
+		return nil, ErrNotLeader
+	}
+	return &guardedMap{
+		Map:      m,
+		election: election,
+		term:     term.ID,
+	}, nil
+}
+
+// guardedMap is a Map whose writes are fenced by an election term
+type guardedMap struct {
+	_map.Map
+	election Election
+	term     uint64
+}
+
+// checkTerm returns ErrNotLeader if the guard's term has been superseded
+func (m *guardedMap) checkTerm(ctx context.Context) error {
+	term, err := m.election.GetTerm(ctx)
+	if err != nil {
+		return err
+	}
+	if term == nil || term.ID != m.term || term.Leader != m.election.ID() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+func (m *guardedMap) Put(ctx context.Context, key string, value []byte, opts ..._map.PutOption) (*_map.Entry, error) {
+	if err := m.checkTerm(ctx); err != nil {
+		return nil, err
+	}
+	return m.Map.Put(ctx, key, value, opts...)
+}
+
+func (m *guardedMap) Remove(ctx context.Context, key string, opts ..._map.RemoveOption) (*_map.Entry, error) {
+	if err := m.checkTerm(ctx); err != nil {
+		return nil, err
+	}
+	return m.Map.Remove(ctx, key, opts...)
+}
+
+func (m *guardedMap) RemoveAll(ctx context.Context, keys []string) (int, error) {
+	if err := m.checkTerm(ctx); err != nil {
+		return 0, err
+	}
+	return m.Map.RemoveAll(ctx, keys)
+}
+
+func (m *guardedMap) MultiCAS(ctx context.Context, conditions map[string]_map.Version, updates map[string][]byte) (bool, error) {
+	if err := m.checkTerm(ctx); err != nil {
+		return false, err
+	}
+	return m.Map.MultiCAS(ctx, conditions, updates)
+}
+
+func (m *guardedMap) LockKey(ctx context.Context, key string) (_map.KeyLock, error) {
+	if err := m.checkTerm(ctx); err != nil {
+		return nil, err
+	}
+	return m.Map.LockKey(ctx, key)
+}
+
+func (m *guardedMap) Clear(ctx context.Context, opts ..._map.ClearOption) error {
+	if err := m.checkTerm(ctx); err != nil {
+		return err
+	}
+	return m.Map.Clear(ctx, opts...)
+}
+
+func (m *guardedMap) ReplaceAll(ctx context.Context, entries map[string][]byte) error {
+	if err := m.checkTerm(ctx); err != nil {
+		return err
+	}
+	return m.Map.ReplaceAll(ctx, entries)
+}
+
+func (m *guardedMap) Delete(ctx context.Context) error {
+	if err := m.checkTerm(ctx); err != nil {
+		return err
+	}
+	return m.Map.Delete(ctx)
+}
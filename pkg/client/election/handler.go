@@ -18,10 +18,25 @@ import (
 	"context"
 	api "github.com/atomix/api/proto/atomix/election"
 	"github.com/atomix/api/proto/atomix/headers"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"google.golang.org/grpc"
 )
 
+func init() {
+	primitive.Register(Type, func(ctx context.Context, name primitive.Name, sessions []*primitive.Session, opts ...interface{}) (primitive.Primitive, error) {
+		options := make([]Option, len(opts))
+		for i, opt := range opts {
+			option, ok := opt.(Option)
+			if !ok {
+				return nil, errors.New(errors.Invalid, "%T is not a %s option", opt, Type)
+			}
+			options[i] = option
+		}
+		return New(ctx, name, sessions, options...)
+	})
+}
+
 type primitiveHandler struct{}
 
 func (m *primitiveHandler) Create(ctx context.Context, s *primitive.Instance) error {
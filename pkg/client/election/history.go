@@ -0,0 +1,67 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election
+
+import "sync"
+
+// defaultTermHistorySize is the default number of terms recorded in the term history
+const defaultTermHistorySize = 16
+
+// newTermHistory returns a new term history that records up to the given number of terms
+func newTermHistory(size int) *termHistory {
+	return &termHistory{
+		terms: make([]Term, 0, size),
+		size:  size,
+	}
+}
+
+// termHistory is a bounded ring buffer of the most recently observed terms
+type termHistory struct {
+	terms []Term
+	size  int
+	// index is the partition index at which the most recent term was observed
+	index uint64
+	// next is the position of the next term to be overwritten once the buffer is full
+	next int
+	mu   sync.RWMutex
+}
+
+// record records the given term observed at the given partition index
+// Terms observed at or before the index of the most recently recorded term are ignored, so terms observed by
+// concurrent watches are only recorded once and in order.
+func (h *termHistory) record(index uint64, term Term) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if index <= h.index {
+		return
+	}
+	h.index = index
+	if len(h.terms) < h.size {
+		h.terms = append(h.terms, term)
+		return
+	}
+	h.terms[h.next] = term
+	h.next = (h.next + 1) % h.size
+}
+
+// list returns the recorded terms from the oldest to the most recent
+func (h *termHistory) list() []Term {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	terms := make([]Term, 0, len(h.terms))
+	terms = append(terms, h.terms[h.next:]...)
+	terms = append(terms, h.terms[:h.next]...)
+	return terms
+}
@@ -15,8 +15,12 @@
 package errors
 
 import (
+	"context"
+	goerrors "errors"
 	"fmt"
 	"github.com/atomix/api/proto/atomix/headers"
+	"sort"
+	"strings"
 )
 
 // Type is an error type
@@ -55,14 +59,72 @@ type TypedError struct {
 	Type Type
 	// Message is the error message
 	Message string
+	// Err is the error that caused the typed error, if any
+	Err error
 }
 
 func (e *TypedError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the error that caused the typed error
+func (e *TypedError) Unwrap() error {
+	return e.Err
+}
+
 var _ error = &TypedError{}
 
+// OperationError is an error returned by an operation on a primitive
+// The error records the context in which the operation failed and wraps the underlying error, so the
+// underlying error can still be inspected with errors.Is and errors.As and by the type predicates in
+// this package.
+type OperationError struct {
+	// Partition is the ID of the partition on which the operation was performed
+	Partition int
+	// SessionID is the ID of the session in which the operation was performed
+	SessionID uint64
+	// Primitive is the name of the primitive on which the operation was performed
+	Primitive string
+	// Operation is the kind of operation that failed
+	Operation string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("%s %s failed (partition %d, session %d): %s", e.Primitive, e.Operation, e.Partition, e.SessionID, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+var _ error = &OperationError{}
+
+// PartialError is returned by an operation on multiple partitions that failed on some of the partitions
+// The results of the partitions on which the operation succeeded are returned along with the error, so the
+// caller can decide whether the partial results are acceptable.
+type PartialError struct {
+	// Errors maps the index of each partition on which the operation failed to the error
+	Errors map[int]error
+}
+
+func (e *PartialError) Error() string {
+	partitions := make([]int, 0, len(e.Errors))
+	for partition := range e.Errors {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+	failures := make([]string, len(partitions))
+	for i, partition := range partitions {
+		failures[i] = fmt.Sprintf("partition %d: %s", partition, e.Errors[partition])
+	}
+	return fmt.Sprintf("operation failed on %d partition(s): %s", len(partitions), strings.Join(failures, "; "))
+}
+
+var _ error = &PartialError{}
+
 // FromHeader creates a typed error from a response header
 func FromHeader(header *headers.ResponseHeader) error {
 	switch header.Status {
@@ -170,17 +232,35 @@ func NewInternal(msg string) error {
 	return New(Internal, msg)
 }
 
+// FromContext creates a typed error from the given context error
+// context.Canceled is returned as a Canceled error and context.DeadlineExceeded as a Timeout error. The typed
+// error wraps the context error, so it can still be matched with errors.Is. Other errors are returned unchanged.
+func FromContext(err error) error {
+	switch err {
+	case context.Canceled:
+		return &TypedError{Type: Canceled, Message: err.Error(), Err: err}
+	case context.DeadlineExceeded:
+		return &TypedError{Type: Timeout, Message: err.Error(), Err: err}
+	default:
+		return err
+	}
+}
+
 // TypeOf returns the type of the given error
+// If the error wraps a typed error, the type of the wrapped error is returned.
 func TypeOf(err error) Type {
-	if typed, ok := err.(*TypedError); ok {
+	var typed *TypedError
+	if goerrors.As(err, &typed) {
 		return typed.Type
 	}
 	return Unknown
 }
 
 // IsType checks whether the given error is of the given type
+// If the error wraps a typed error, the type of the wrapped error is checked.
 func IsType(err error, t Type) bool {
-	if typed, ok := err.(*TypedError); ok {
+	var typed *TypedError
+	if goerrors.As(err, &typed) {
 		return typed.Type == t
 	}
 	return false
@@ -245,3 +325,9 @@ func IsTimeout(err error) bool {
 func IsInternal(err error) bool {
 	return IsType(err, Internal)
 }
+
+// IsPartial checks whether the given error is a PartialError
+func IsPartial(err error) bool {
+	var partialErr *PartialError
+	return goerrors.As(err, &partialErr)
+}
@@ -15,7 +15,9 @@
 package errors
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -73,3 +75,47 @@ func TestPredicates(t *testing.T) {
 	assert.False(t, IsInternal(errors.New("Internal")))
 	assert.True(t, IsInternal(NewInternal("Internal")))
 }
+
+func TestOperationError(t *testing.T) {
+	cause := NewNotFound("not found")
+	err := error(&OperationError{
+		Partition: 1,
+		SessionID: 2,
+		Primitive: "foo",
+		Operation: "query",
+		Err:       cause,
+	})
+	assert.Equal(t, "foo query failed (partition 1, session 2): not found", err.Error())
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsConflict(err))
+	assert.Equal(t, NotFound, TypeOf(err))
+
+	var typed *TypedError
+	assert.True(t, errors.As(err, &typed))
+	assert.Equal(t, NotFound, typed.Type)
+}
+
+func TestPartialError(t *testing.T) {
+	err := error(&PartialError{
+		Errors: map[int]error{
+			2: NewTimeout("timed out"),
+			0: NewUnavailable("unavailable"),
+		},
+	})
+	assert.Equal(t, "operation failed on 2 partition(s): partition 0: unavailable; partition 2: timed out", err.Error())
+	assert.True(t, IsPartial(err))
+	assert.True(t, IsPartial(fmt.Errorf("wrapped: %w", err)))
+	assert.False(t, IsPartial(NewUnavailable("unavailable")))
+}
+
+func TestFromContext(t *testing.T) {
+	err := FromContext(context.Canceled)
+	assert.True(t, IsCanceled(err))
+	assert.True(t, errors.Is(err, context.Canceled))
+	err = FromContext(context.DeadlineExceeded)
+	assert.True(t, IsTimeout(err))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	err = errors.New("foo")
+	assert.Equal(t, err, FromContext(err))
+}
@@ -113,6 +113,8 @@ type IndexedMap interface {
 	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
 }
 
+var _ primitive.Sized = IndexedMap(nil)
+
 // Entry is an indexed key/value pair
 type Entry struct {
 	// Index is the unique, monotonically increasing, globally unique index of the entry. The index is static
@@ -838,7 +840,7 @@ func (m *indexedMap) Entries(ctx context.Context, ch chan<- *Entry) error {
 		defer close(ch)
 		for event := range stream {
 			response := event.(*api.EntriesResponse)
-			ch <- &Entry{
+			entry := &Entry{
 				Index:   Index(response.Index),
 				Key:     response.Key,
 				Value:   response.Value,
@@ -846,6 +848,11 @@ func (m *indexedMap) Entries(ctx context.Context, ch chan<- *Entry) error {
 				Created: response.Created,
 				Updated: response.Updated,
 			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return nil
@@ -0,0 +1,58 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/lock"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+)
+
+// newAppendLock creates the lock that serializes appends to the list with the given name
+func newAppendLock(ctx context.Context, name primitive.Name, partition *primitive.Session) (lock.Lock, error) {
+	lockName := primitive.NewName(name.Namespace, name.Database, name.Scope, fmt.Sprintf("%s.locks.append", name.Name))
+	return lock.New(ctx, lockName, []*primitive.Session{partition})
+}
+
+// getAppendLock returns the lock that serializes appends to the list, creating it on first use
+func (l *list) getAppendLock(ctx context.Context) (lock.Lock, error) {
+	l.appendMu.Lock()
+	defer l.appendMu.Unlock()
+	if l.appendLock == nil {
+		appendLock, err := newAppendLock(ctx, l.name, l.instance.Session)
+		if err != nil {
+			return nil, err
+		}
+		l.appendLock = appendLock
+	}
+	return l.appendLock, nil
+}
+
+// lockAppend acquires the append lock and returns a function that releases it
+// The index of an appended value is read from the size of the list before the lock is released.
+func (l *list) lockAppend(ctx context.Context) (func(), error) {
+	appendLock, err := l.getAppendLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version, err := appendLock.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		_, _ = appendLock.Unlock(context.Background(), lock.IfVersion(version))
+	}, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// ClearCondition is a condition on the values of a list that must be met for ClearIf to clear the list
+type ClearCondition interface {
+	// Matches returns whether the given values of the list meet the condition
+	Matches(values [][]byte) bool
+}
+
+// IfLen returns a ClearCondition that is met if the list has the given length
+func IfLen(n int) ClearCondition {
+	return lenCondition{n: n}
+}
+
+type lenCondition struct {
+	n int
+}
+
+func (c lenCondition) Matches(values [][]byte) bool {
+	return len(values) == c.n
+}
+
+// IfContentHash returns a ClearCondition that is met if the content hash of the list is the given hash
+// The hash of a list's values is computed by ContentHash.
+func IfContentHash(hash []byte) ClearCondition {
+	return contentHashCondition{hash: hash}
+}
+
+type contentHashCondition struct {
+	hash []byte
+}
+
+func (c contentHashCondition) Matches(values [][]byte) bool {
+	return bytes.Equal(ContentHash(values), c.hash)
+}
+
+// ContentHash returns the SHA-256 hash of the given list values
+// Each value is hashed with its length, so the hash distinguishes lists whose concatenated values are equal.
+func ContentHash(values [][]byte) []byte {
+	hash := sha256.New()
+	length := make([]byte, 8)
+	for _, value := range values {
+		binary.BigEndian.PutUint64(length, uint64(len(value)))
+		hash.Write(length)
+		hash.Write(value)
+	}
+	return hash.Sum(nil)
+}
+
+// readValues reads all the values in the list
+func (l *list) readValues(ctx context.Context) ([][]byte, error) {
+	ch := make(chan []byte)
+	if err := l.Items(ctx, ch); err != nil {
+		return nil, err
+	}
+	values := make([][]byte, 0)
+	for value := range ch {
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (l *list) ClearIf(ctx context.Context, condition ClearCondition) (bool, error) {
+	values, err := l.readValues(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !condition.Matches(values) {
+		return false, nil
+	}
+
+	// Verify the list was not modified while the condition was checked
+	current, err := l.readValues(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(ContentHash(current), ContentHash(values)) {
+		return false, nil
+	}
+
+	if err := l.Clear(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
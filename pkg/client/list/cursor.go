@@ -0,0 +1,97 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"strconv"
+	"strings"
+)
+
+// cursorPrefix is the prefix of encoded list cursors
+const cursorPrefix = "list:"
+
+// ListIterator iterates through the values in a list from a cursor
+// The iterator reads one value per call to Next rather than holding a stream open, so it can be used for
+// long-running scans. The position of the iterator can be saved with Cursor and restored with IteratorFrom, even
+// by another client. A cursor identifies the index of the next value to be read: values appended to the list are
+// read when the iterator reaches them, but values inserted or removed before the cursor shift the values after it,
+// so an insertion before the cursor causes a value to be read twice and a removal before the cursor causes a value
+// to be skipped.
+type ListIterator struct { //nolint:golint
+	ctx   context.Context
+	list  List
+	index int
+	size  int
+}
+
+// newIterator returns an iterator for the given list starting at the given index
+func newIterator(ctx context.Context, l List, index int) *ListIterator {
+	return &ListIterator{
+		ctx:   ctx,
+		list:  l,
+		index: index,
+	}
+}
+
+// Next reads the next value from the list
+// A bool indicating whether a value was read is returned. Once the iterator reaches the end of the list, Next
+// returns false until values are appended to the list.
+func (i *ListIterator) Next() ([]byte, bool, error) {
+	if i.index >= i.size {
+		size, err := i.list.Len(i.ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		i.size = size
+		if i.index >= size {
+			return nil, false, nil
+		}
+	}
+
+	value, err := i.list.Get(i.ctx, i.index)
+	if err != nil {
+		// If values were removed since the length of the list was read, the iterator may have reached the end
+		size, lenErr := i.list.Len(i.ctx)
+		if lenErr == nil && i.index >= size {
+			i.size = size
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	i.index++
+	return value, true, nil
+}
+
+// Cursor returns a cursor that resumes iteration at the next value to be read by the iterator
+// The cursor is an opaque string that can be passed to IteratorFrom to create an iterator at the same position.
+func (i *ListIterator) Cursor() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(i.index)))
+}
+
+// decodeCursor decodes the index of the next value from the given cursor
+func decodeCursor(cursor string) (int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(bytes), cursorPrefix) {
+		return 0, errors.NewInvalid("invalid list cursor")
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(string(bytes), cursorPrefix))
+	if err != nil || index < 0 {
+		return 0, errors.NewInvalid("invalid list cursor")
+	}
+	return index, nil
+}
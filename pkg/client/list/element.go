@@ -0,0 +1,136 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"bytes"
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+)
+
+// elementTag is the prefix used to tag values stored with an element ID
+var elementTag = []byte("\x00aid")
+
+// ElementEntry is a list element with a stable identifier
+// Element IDs are assigned by the client when a value is written to the list by Append, AppendAll, Insert
+// or Set. An element's ID does not change when other elements are inserted or removed and its index shifts.
+// Setting the value at an index replaces the element there with a new element that has a new ID, and the ID
+// of a removed element is never reused. Values written by instances without element IDs have an empty ID.
+type ElementEntry struct {
+	// ID is the stable identifier of the element
+	ID string
+
+	// Index is the index of the element at the time it was read
+	Index int
+
+	// Value is the element value
+	Value []byte
+
+	// Metadata is the element metadata, if the value was written with AppendWithMeta
+	Metadata map[string]string
+}
+
+// wrapElement prefixes the given value with the given element ID
+func wrapElement(id string, value []byte) []byte {
+	wrapped := make([]byte, 0, len(elementTag)+1+len(id)+len(value))
+	wrapped = append(wrapped, elementTag...)
+	wrapped = append(wrapped, byte(len(id)))
+	wrapped = append(wrapped, id...)
+	return append(wrapped, value...)
+}
+
+// unwrapElement returns the element ID and value from the given value
+// If the value is not prefixed with an element ID, the ID is empty.
+func unwrapElement(value []byte) (string, []byte) {
+	if !bytes.HasPrefix(value, elementTag) {
+		return "", value
+	}
+	wrapped := value[len(elementTag):]
+	if len(wrapped) == 0 || len(wrapped) < 1+int(wrapped[0]) {
+		return "", value
+	}
+	return string(wrapped[1 : 1+int(wrapped[0])]), wrapped[1+int(wrapped[0]):]
+}
+
+func (l *list) GetEntry(ctx context.Context, index int) (*ElementEntry, error) {
+	value, err := l.get(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	id, meta, bytes, err := l.decodeEntry(value)
+	if err != nil {
+		return nil, err
+	}
+	return &ElementEntry{
+		ID:       id,
+		Index:    index,
+		Value:    bytes,
+		Metadata: meta,
+	}, nil
+}
+
+func (l *list) GetByID(ctx context.Context, id string) (*ElementEntry, error) {
+	if !l.elementIDs {
+		return nil, errors.NewNotSupported("list element IDs are not enabled")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch := make(chan *ElementEntry)
+	if err := l.entries(ctx, ch); err != nil {
+		return nil, err
+	}
+	for entry := range ch {
+		if entry.ID == id {
+			go func() {
+				for range ch {
+				}
+			}()
+			return entry, nil
+		}
+	}
+	return nil, errors.New(errors.NotFound, "element %s not found", id)
+}
+
+func (l *list) RemoveByID(ctx context.Context, id string) (*ElementEntry, error) {
+	for {
+		entry, err := l.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := l.remove(ctx, entry.Index)
+		if err != nil {
+			return nil, err
+		}
+		removedID, meta, bytes, err := l.decodeEntry(value)
+		if err != nil {
+			return nil, err
+		}
+		if removedID == id {
+			return &ElementEntry{
+				ID:       id,
+				Index:    entry.Index,
+				Value:    bytes,
+				Metadata: meta,
+			}, nil
+		}
+
+		// Another element was removed in place of the element, so restore it and retry
+		if err := l.insert(ctx, entry.Index, value); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build go1.23
+
+package list
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/util"
+	"iter"
+)
+
+// itemsLister is implemented by List and ReadOnlyList
+type itemsLister interface {
+	Items(ctx context.Context, ch chan<- []byte) error
+}
+
+// All returns an iterator over the values in the given List or ReadOnlyList
+// The values are read from the list each time the iterator is ranged over, in the same order as by Items.
+// Breaking out of the loop cancels the iteration and closes the underlying stream. If the values cannot be
+// read, or the context is done before all the values have been read, the error is yielded with a nil value
+// and the iteration ends. All requires Go 1.23; on earlier versions of Go, use Items.
+func All(ctx context.Context, l itemsLister) iter.Seq2[[]byte, error] {
+	return util.Seq(ctx, l.Items)
+}
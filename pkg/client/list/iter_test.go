@@ -0,0 +1,66 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build go1.23
+
+package list
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestListAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = list.Append(context.TODO(), []byte(strconv.Itoa(i)))
+		assert.NoError(t, err)
+	}
+
+	values := make([]string, 0)
+	for value, err := range All(context.TODO(), list) {
+		assert.NoError(t, err)
+		values = append(values, string(value))
+	}
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, values)
+
+	// Breaking out of the loop stops the iteration
+	values = values[:0]
+	for value, err := range All(context.TODO(), list.ReadOnly()) {
+		assert.NoError(t, err)
+		values = append(values, string(value))
+		if len(values) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"0", "1", "2"}, values)
+
+	// The list can still be read once the iteration has been canceled
+	size, err := list.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 10, size)
+}
@@ -12,16 +12,40 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package list provides a distributed list primitive
+//
+// The list service only stores values by index and applies one command per value, so operations that
+// need more are composed by the client and are not atomic with respect to other clients:
+//
+// Appends are serialized by a lock stored alongside the list, and the index of an appended value is read
+// from the size of the list before the lock is released, so Insert and Remove may shift the index before
+// it's returned. SetIfAbsent and WithUniqueValues serialize with a lock too, but Set is not serialized with
+// them. Metadata and element IDs are stored with the value in an envelope, and only AppendWithMeta writes
+// metadata.
+//
+// SetRange, ReplaceValue, TrimFirst and TrimLast apply their updates in a single batch, so no other command
+// from the same session is interleaved with them, but other clients may observe them partially applied.
+// PopPush removes the value before appending it, so other clients may briefly observe it in neither list.
+// ClearIf and RemoveByID read the list before updating it and detect most, but not all, concurrent changes.
+//
+// A watch cannot be resumed from a given event, so if events were missed, e.g. while reconnecting to a new
+// leader, the watch pushes an EventGap event and closes its channel, and the consumer should read the list
+// again before watching it again.
 package list
 
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"github.com/atomix/api/proto/atomix/headers"
 	api "github.com/atomix/api/proto/atomix/list"
+	"github.com/google/uuid"
+	"github.com/lucasbfernandes/go-client/pkg/client/lock"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/util"
 	"google.golang.org/grpc"
+	"sync"
+	"time"
 )
 
 // Type is the list type
@@ -30,7 +54,7 @@ const Type primitive.Type = "List"
 // Client provides an API for creating Lists
 type Client interface {
 	// GetList gets the List instance of the given name
-	GetList(ctx context.Context, name string) (List, error)
+	GetList(ctx context.Context, name string, opts ...Option) (List, error)
 }
 
 // List provides a distributed list data structure
@@ -39,8 +63,18 @@ type Client interface {
 type List interface {
 	primitive.Primitive
 
-	// Append pushes a value on to the end of the list
-	Append(ctx context.Context, value []byte) error
+	// Append pushes a value on to the end of the list and returns the index at which it was appended
+	Append(ctx context.Context, value []byte) (int, error)
+
+	// AppendWithMeta pushes a value with the given metadata on to the end of the list and returns the index at
+	// which it was appended
+	// The metadata is returned with the element by GetEntry, Entries and Watch, but not by Get and Items.
+	AppendWithMeta(ctx context.Context, value []byte, meta map[string]string) (int, error)
+
+	// AppendAll pushes the given values on to the end of the list in order
+	// The values are appended while holding the lock that serializes appends, so appends from other clients
+	// are not interleaved with the values. If an append fails, the values preceding it remain in the list.
+	AppendAll(ctx context.Context, values [][]byte) error
 
 	// Insert inserts a value at the given index
 	Insert(ctx context.Context, index int, value []byte) error
@@ -48,12 +82,59 @@ type List interface {
 	// Set sets the value at the given index
 	Set(ctx context.Context, index int, value []byte) error
 
+	// SetIfAbsent sets the value at the given index if the value at the index is empty
+	// A bool indicating whether the value was set is returned.
+	SetIfAbsent(ctx context.Context, index int, value []byte) (bool, error)
+
+	// SetRange replaces the values starting at the given index with the given values
+	SetRange(ctx context.Context, from int, values [][]byte) error
+
+	// ReplaceValue replaces each value in the list equal to old with new and returns the number of values replaced
+	ReplaceValue(ctx context.Context, old []byte, new []byte) (int, error)
+
 	// Get gets the value at the given index
 	Get(ctx context.Context, index int) ([]byte, error)
 
 	// Remove removes and returns the value at the given index
 	Remove(ctx context.Context, index int) ([]byte, error)
 
+	// BlockingPollFirst removes and returns the value at the head of the list
+	// If the list is empty, BlockingPollFirst blocks until a value is inserted into the list and then retries,
+	// returning a Timeout error if no value could be removed within the given timeout. The list is watched
+	// rather than polled while waiting. Removal of the head is atomic, so when multiple consumers are blocked
+	// on the same list, each value is returned to exactly one of them.
+	BlockingPollFirst(ctx context.Context, timeout time.Duration) ([]byte, error)
+
+	// PopPush removes the value at the head of the list, appends it to the given list, and returns the value
+	// If the list is empty, a NotFound error is returned.
+	PopPush(ctx context.Context, dest List) ([]byte, error)
+
+	// TrimFirst removes up to n values from the head of the list and returns the number of values removed
+	// The values are removed in a single batch, and an EventRemoved event is published for each removed value.
+	// If the list is modified concurrently such that a value can no longer be removed, the number of values
+	// removed before the failure is returned along with the error.
+	TrimFirst(ctx context.Context, n int) (int, error)
+
+	// TrimLast removes up to n values from the tail of the list and returns the number of values removed
+	// The values are removed in a single batch, and an EventRemoved event is published for each removed value.
+	// If the list is modified concurrently such that a value can no longer be removed, the number of values
+	// removed before the failure is returned along with the error.
+	TrimLast(ctx context.Context, n int) (int, error)
+
+	// GetEntry gets the element at the given index
+	// The element ID is only set if the list was created with WithElementIDs.
+	GetEntry(ctx context.Context, index int) (*ElementEntry, error)
+
+	// GetByID gets the element with the given ID
+	// The list must be created with WithElementIDs. The element is located by iterating through the list,
+	// so the cost of the lookup grows with the size of the list. If no element with the given ID is found,
+	// a NotFound error is returned.
+	GetByID(ctx context.Context, id string) (*ElementEntry, error)
+
+	// RemoveByID removes and returns the element with the given ID
+	// The list must be created with WithElementIDs.
+	RemoveByID(ctx context.Context, id string) (*ElementEntry, error)
+
 	// Len gets the length of the list
 	Len(ctx context.Context) (int, error)
 
@@ -68,18 +149,57 @@ type List interface {
 
 	// Items iterates through the values in the list
 	// This is a non-blocking method. If the method returns without error, values will be pushed on to the
-	// given channel and the channel will be closed once all values have been read from the list.
+	// given channel and the channel will be closed once all values have been read from the list. If the context
+	// is canceled, the iteration is stopped and the channel is closed without the remaining values.
 	Items(ctx context.Context, ch chan<- []byte) error
 
+	// ItemsFrom iterates through the values in the list starting at the given index
+	// This is a non-blocking method. If the method returns without error, values will be pushed on to the
+	// given channel and the channel will be closed once all values have been read from the list. If the
+	// start index is beyond the end of the list, the channel will be closed without any values.
+	ItemsFrom(ctx context.Context, start int, ch chan<- []byte) error
+
+	// Iterator returns an iterator that reads the values in the list from the head of the list
+	// Unlike Items, the iterator does not hold a stream open, and its position can be saved as a cursor to resume
+	// the iteration with IteratorFrom. The iterator reads values with the given context.
+	Iterator(ctx context.Context) (*ListIterator, error)
+
+	// IteratorFrom returns an iterator that resumes reading the values in the list at the given cursor
+	// The cursor must have been returned by ListIterator.Cursor, or an Invalid error is returned. See ListIterator
+	// for how concurrent modifications of the list affect a cursor.
+	IteratorFrom(ctx context.Context, cursor string) (*ListIterator, error)
+
+	// ToSlice reads all the values in the list into a slice
+	// ToSlice is intended for tests and lists of bounded size: the entire list is read into memory, so it
+	// should not be used with lists that may grow without bound. Use Items to iterate through large lists. If
+	// a value cannot be decoded, the error is returned rather than the value being skipped. If the context is
+	// canceled before all the values have been read, the context's error is returned.
+	ToSlice(ctx context.Context) ([][]byte, error)
+
+	// Entries iterates through the elements in the list
+	// This is a non-blocking method. If the method returns without error, elements will be pushed on to the
+	// given channel and the channel will be closed once all elements have been read from the list. Elements
+	// carry their metadata and, if the list was created with WithElementIDs, their IDs.
+	Entries(ctx context.Context, ch chan<- *ElementEntry) error
+
 	// Watch watches the list for changes
 	// This is a non-blocking method. If the method returns without error, list events will be pushed onto
-	// the given channel.
+	// the given channel. If events may have been missed, an EventGap event is pushed and the channel is closed.
 	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
 
 	// Clear removes all values from the list
 	Clear(ctx context.Context) error
+
+	// ClearIf removes all values from the list if the list meets the given condition
+	// A bool indicating whether the list was cleared is returned.
+	ClearIf(ctx context.Context, condition ClearCondition) (bool, error)
+
+	// ReadOnly returns a read-only view of the list
+	ReadOnly() ReadOnlyList
 }
 
+var _ primitive.Sized = List(nil)
+
 // EventType is the type for a list Event
 type EventType string
 
@@ -92,6 +212,10 @@ const (
 
 	// EventRemoved indicates a value was removed from the list
 	EventRemoved EventType = "removed"
+
+	// EventGap indicates events may have been missed by the watch
+	// EventGap is the last event pushed by a watch. Its index and value are not set.
+	EventGap EventType = "gap"
 )
 
 // Event is a list change event
@@ -104,45 +228,187 @@ type Event struct {
 
 	// Value is the value that was changed
 	Value []byte
+
+	// Version is the partition index at which the event occurred
+	Version uint64
+
+	// Metadata is the metadata of the value, if it was written with AppendWithMeta
+	Metadata map[string]string
 }
 
 // New creates a new list primitive
-func New(ctx context.Context, name primitive.Name, partitions []*primitive.Session) (List, error) {
+func New(ctx context.Context, name primitive.Name, partitions []*primitive.Session, opts ...Option) (List, error) {
+	options := &options{}
+	for _, opt := range opts {
+		opt.apply(options)
+	}
+
 	i, err := util.GetPartitionIndex(name.Name, len(partitions))
 	if err != nil {
 		return nil, err
 	}
-	return newList(ctx, name, partitions[i])
+	return newList(ctx, name, partitions[i], options)
 }
 
 // newList creates a new list for the given partition
-func newList(ctx context.Context, name primitive.Name, partition *primitive.Session) (*list, error) {
+func newList(ctx context.Context, name primitive.Name, partition *primitive.Session, options *options) (*list, error) {
 	instance, err := primitive.NewInstance(ctx, name, partition, &primitiveHandler{})
 	if err != nil {
 		return nil, err
 	}
-	return &list{
-		name:     name,
-		instance: instance,
-	}, nil
+	l := &list{
+		name:       name,
+		instance:   instance,
+		codec:      options.codec,
+		elementIDs: options.elementIDs,
+	}
+	if options.uniqueValues {
+		uniqueLock, err := newUniqueLock(ctx, name, partition)
+		if err != nil {
+			_ = instance.Close(ctx)
+			return nil, err
+		}
+		l.uniqueLock = uniqueLock
+	}
+	return l, nil
 }
 
 // list is the single partition implementation of List
 type list struct {
-	name     primitive.Name
-	instance *primitive.Instance
+	name       primitive.Name
+	instance   *primitive.Instance
+	codec      primitive.Codec
+	elementIDs bool
+	uniqueLock lock.Lock
+	slotLock   lock.Lock
+	slotMu     sync.Mutex
+	appendLock lock.Lock
+	appendMu   sync.Mutex
+}
+
+// encode encodes the given value for a request
+func (l *list) encode(value []byte) (string, error) {
+	return l.encodeWithMeta(value, nil)
+}
+
+// encodeWithMeta encodes the given value with the given metadata for a request
+func (l *list) encodeWithMeta(value []byte, meta map[string]string) (string, error) {
+	value = wrapMetadata(meta, value)
+	if l.elementIDs {
+		value = wrapElement(uuid.New().String(), value)
+	}
+	bytes, err := primitive.EncodeValue(l.codec, value)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bytes), nil
+}
+
+// decode decodes the given value from a response
+func (l *list) decode(value string) ([]byte, error) {
+	_, bytes, err := l.decodeElement(value)
+	return bytes, err
+}
+
+// decodeElement decodes the element ID and value from the given value
+func (l *list) decodeElement(value string) (string, []byte, error) {
+	id, _, bytes, err := l.decodeEntry(value)
+	return id, bytes, err
+}
+
+// decodeEntry decodes the element ID, metadata and value from the given value
+func (l *list) decodeEntry(value string) (string, map[string]string, []byte, error) {
+	bytes, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	bytes, err = primitive.DecodeValue(l.codec, bytes)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var id string
+	if l.elementIDs {
+		id, bytes = unwrapElement(bytes)
+	}
+	meta, bytes := unwrapMetadata(bytes)
+	return id, meta, bytes, nil
 }
 
 func (l *list) Name() primitive.Name {
 	return l.name
 }
 
-func (l *list) Append(ctx context.Context, value []byte) error {
+func (l *list) Append(ctx context.Context, value []byte) (int, error) {
+	return l.AppendWithMeta(ctx, value, nil)
+}
+
+func (l *list) AppendWithMeta(ctx context.Context, value []byte, meta map[string]string) (int, error) {
+	unlock, err := l.lockUnique(ctx, [][]byte{value})
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	encoded, err := l.encodeWithMeta(value, meta)
+	if err != nil {
+		return 0, err
+	}
+
+	unlockAppend, err := l.lockAppend(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlockAppend()
+
+	if err := l.append(ctx, encoded); err != nil {
+		return 0, err
+	}
+
+	// The value is the last in the list until the append lock is released
+	size, err := l.Len(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return size - 1, nil
+}
+
+func (l *list) AppendAll(ctx context.Context, values [][]byte) error {
+	unlock, err := l.lockUnique(ctx, values)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		if encoded[i], err = l.encode(value); err != nil {
+			return err
+		}
+	}
+
+	// The values are appended while holding the append lock rather than in a session batch, since a batch
+	// cannot be sent while an append from the same session is waiting for the lock
+	unlockAppend, err := l.lockAppend(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlockAppend()
+
+	for _, value := range encoded {
+		if err := l.append(ctx, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// append appends an encoded value to the list
+func (l *list) append(ctx context.Context, encoded string) error {
 	_, err := l.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewListServiceClient(conn)
 		request := &api.AppendRequest{
 			Header: header,
-			Value:  base64.StdEncoding.EncodeToString(value),
+			Value:  encoded,
 		}
 		response, err := client.Append(ctx, request)
 		if err != nil {
@@ -154,12 +420,27 @@ func (l *list) Append(ctx context.Context, value []byte) error {
 }
 
 func (l *list) Insert(ctx context.Context, index int, value []byte) error {
+	unlock, err := l.lockUnique(ctx, [][]byte{value})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	encoded, err := l.encode(value)
+	if err != nil {
+		return err
+	}
+	return l.insert(ctx, index, encoded)
+}
+
+// insert inserts an encoded value at the given index
+func (l *list) insert(ctx context.Context, index int, encoded string) error {
 	_, err := l.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewListServiceClient(conn)
 		request := &api.InsertRequest{
 			Header: header,
 			Index:  uint32(index),
-			Value:  base64.StdEncoding.EncodeToString(value),
+			Value:  encoded,
 		}
 		response, err := client.Insert(ctx, request)
 		if err != nil {
@@ -171,12 +452,21 @@ func (l *list) Insert(ctx context.Context, index int, value []byte) error {
 }
 
 func (l *list) Set(ctx context.Context, index int, value []byte) error {
+	encoded, err := l.encode(value)
+	if err != nil {
+		return err
+	}
+	return l.set(ctx, index, encoded)
+}
+
+// set sets the encoded value at the given index
+func (l *list) set(ctx context.Context, index int, encoded string) error {
 	_, err := l.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewListServiceClient(conn)
 		request := &api.SetRequest{
 			Header: header,
 			Index:  uint32(index),
-			Value:  base64.StdEncoding.EncodeToString(value),
+			Value:  encoded,
 		}
 		response, err := client.Set(ctx, request)
 		if err != nil {
@@ -187,7 +477,56 @@ func (l *list) Set(ctx context.Context, index int, value []byte) error {
 	return err
 }
 
+func (l *list) SetRange(ctx context.Context, from int, values [][]byte) error {
+	if from < 0 {
+		return errors.New("index out of range")
+	}
+	size, err := l.Len(ctx)
+	if err != nil {
+		return err
+	}
+	if from+len(values) > size {
+		return errors.New("index out of range")
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	fns := make([]primitive.CommandFunc, len(values))
+	for i, value := range values {
+		index := from + i
+		encoded, err := l.encode(value)
+		if err != nil {
+			return err
+		}
+		fns[i] = func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewListServiceClient(conn)
+			request := &api.SetRequest{
+				Header: header,
+				Index:  uint32(index),
+				Value:  encoded,
+			}
+			response, err := client.Set(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		}
+	}
+	_, err = l.instance.DoBatch(ctx, fns)
+	return err
+}
+
 func (l *list) Get(ctx context.Context, index int) ([]byte, error) {
+	value, err := l.get(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	return l.decode(value)
+}
+
+// get gets the encoded value at the given index
+func (l *list) get(ctx context.Context, index int) (string, error) {
 	r, err := l.instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewListServiceClient(conn)
 		request := &api.GetRequest{
@@ -201,13 +540,21 @@ func (l *list) Get(ctx context.Context, index int) ([]byte, error) {
 		return response.Header, response, nil
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	response := r.(*api.GetResponse)
-	return base64.StdEncoding.DecodeString(response.Value)
+	return r.(*api.GetResponse).Value, nil
 }
 
 func (l *list) Remove(ctx context.Context, index int) ([]byte, error) {
+	value, err := l.remove(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	return l.decode(value)
+}
+
+// remove removes and returns the encoded value at the given index
+func (l *list) remove(ctx context.Context, index int) (string, error) {
 	r, err := l.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewListServiceClient(conn)
 		request := &api.RemoveRequest{
@@ -221,10 +568,56 @@ func (l *list) Remove(ctx context.Context, index int) ([]byte, error) {
 		return response.Header, response, nil
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	response := r.(*api.RemoveResponse)
-	return base64.StdEncoding.DecodeString(response.Value)
+	return r.(*api.RemoveResponse).Value, nil
+}
+
+func (l *list) TrimFirst(ctx context.Context, n int) (int, error) {
+	return l.trim(ctx, n, true)
+}
+
+func (l *list) TrimLast(ctx context.Context, n int) (int, error) {
+	return l.trim(ctx, n, false)
+}
+
+// trim removes up to n values from the head or tail of the list in a single batch
+func (l *list) trim(ctx context.Context, n int, head bool) (int, error) {
+	if n < 0 {
+		return 0, errors.New("count must be non-negative")
+	}
+	size, err := l.Len(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if n > size {
+		n = size
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	fns := make([]primitive.CommandFunc, n)
+	for i := 0; i < n; i++ {
+		index := 0
+		if !head {
+			index = size - 1 - i
+		}
+		fns[i] = func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewListServiceClient(conn)
+			request := &api.RemoveRequest{
+				Header: header,
+				Index:  uint32(index),
+			}
+			response, err := client.Remove(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		}
+	}
+	results, err := l.instance.DoBatch(ctx, fns)
+	return len(results), err
 }
 
 func (l *list) Len(ctx context.Context) (int, error) {
@@ -246,6 +639,56 @@ func (l *list) Len(ctx context.Context) (int, error) {
 }
 
 func (l *list) Items(ctx context.Context, ch chan<- []byte) error {
+	entryCh := make(chan *ElementEntry)
+	go func() {
+		defer close(ch)
+		for entry := range entryCh {
+			select {
+			case ch <- entry.Value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return l.entries(ctx, entryCh)
+}
+
+func (l *list) Entries(ctx context.Context, ch chan<- *ElementEntry) error {
+	return l.entries(ctx, ch)
+}
+
+// entries iterates through the elements in the list
+func (l *list) entries(ctx context.Context, ch chan<- *ElementEntry) error {
+	valueCh := make(chan string)
+	if err := l.iterate(ctx, valueCh); err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(ch)
+		index := 0
+		for value := range valueCh {
+			if id, meta, bytes, err := l.decodeEntry(value); err == nil {
+				entry := &ElementEntry{
+					ID:       id,
+					Index:    index,
+					Value:    bytes,
+					Metadata: meta,
+				}
+				select {
+				case ch <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+			index++
+		}
+	}()
+	return nil
+}
+
+// iterate iterates through the encoded values in the list
+func (l *list) iterate(ctx context.Context, ch chan<- string) error {
 	stream, err := l.instance.DoQueryStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewListServiceClient(conn)
 		request := &api.IterateRequest{
@@ -266,16 +709,85 @@ func (l *list) Items(ctx context.Context, ch chan<- []byte) error {
 	go func() {
 		defer close(ch)
 		for event := range stream {
-			response := event.(*api.IterateResponse)
-			if bytes, err := base64.StdEncoding.DecodeString(response.Value); err == nil {
-				ch <- bytes
+			select {
+			case ch <- event.(*api.IterateResponse).Value:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 	return nil
 }
 
+func (l *list) ToSlice(ctx context.Context) ([][]byte, error) {
+	// Stop the iteration if a value cannot be decoded
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan string)
+	if err := l.iterate(ctx, ch); err != nil {
+		return nil, err
+	}
+	values := make([][]byte, 0)
+	for value := range ch {
+		bytes, err := l.decode(value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, bytes)
+	}
+
+	// If the context was canceled, the values may be incomplete
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (l *list) ItemsFrom(ctx context.Context, start int, ch chan<- []byte) error {
+	return itemsFrom(ctx, l, start, ch)
+}
+
+// itemsFrom iterates through the values in the given list, skipping values before the given start index
+func itemsFrom(ctx context.Context, l List, start int, ch chan<- []byte) error {
+	if start < 0 {
+		return errors.New("index out of range")
+	}
+	itemsCh := make(chan []byte)
+	go func() {
+		defer close(ch)
+		i := 0
+		for item := range itemsCh {
+			if i >= start {
+				select {
+				case ch <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+			i++
+		}
+	}()
+	return l.Items(ctx, itemsCh)
+}
+
+func (l *list) Iterator(ctx context.Context) (*ListIterator, error) {
+	return newIterator(ctx, l, 0), nil
+}
+
+func (l *list) IteratorFrom(ctx context.Context, cursor string) (*ListIterator, error) {
+	index, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(ctx, l, index), nil
+}
+
 func (l *list) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	// The stream handshake is sent at the index at which the listener is registered
+	var index uint64
+	gaps := &gapDetector{}
+	ctx, cancel := context.WithCancel(ctx)
 	stream, err := l.instance.DoCommandStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewListServiceClient(conn)
 		request := &api.EventRequest{
@@ -290,16 +802,29 @@ func (l *list) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption)
 		if err != nil {
 			return nil, nil, err
 		}
+		if response.Header.Type == headers.ResponseType_OPEN_STREAM && index == 0 {
+			index = response.Header.Index
+		}
+		if err := gaps.observe(response.Header); err != nil {
+			return nil, nil, err
+		}
 		for _, opt := range opts {
 			opt.afterWatch(response)
 		}
 		return response.Header, response, nil
 	})
 	if err != nil {
+		cancel()
+		return err
+	}
+
+	if err := checkFromVersion(opts, index); err != nil {
+		cancel()
 		return err
 	}
 
 	go func() {
+		defer cancel()
 		defer close(ch)
 		for event := range stream {
 			response := event.(*api.EventResponse)
@@ -313,18 +838,74 @@ func (l *list) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption)
 				t = EventRemoved
 			}
 
-			if bytes, err := base64.StdEncoding.DecodeString(response.Value); err == nil {
-				ch <- &Event{
-					Type:  t,
-					Index: int(response.Index),
-					Value: bytes,
+			if _, meta, bytes, err := l.decodeEntry(response.Value); err == nil {
+				event := &Event{
+					Type:     t,
+					Index:    int(response.Index),
+					Value:    bytes,
+					Version:  response.Header.Index,
+					Metadata: meta,
+				}
+				if filterEvent(event, opts) {
+					ch <- event
 				}
 			}
 		}
+		if gaps.detected() && ctx.Err() == nil {
+			ch <- &Event{
+				Type: EventGap,
+			}
+		}
 	}()
 	return nil
 }
 
+// errGap is returned by a watch stream when it misses responses
+var errGap = errors.New("watch missed events")
+
+// gapDetector detects responses missed by a watch stream
+// Responses on a stream are numbered sequentially, including the responses sent by a new leader once the
+// stream has been moved to it. Responses numbered at or below the last response are duplicates.
+type gapDetector struct {
+	responseID uint64
+	gap        bool
+	mu         sync.RWMutex
+}
+
+// observe records the given response header and returns errGap if responses preceding it were missed
+func (d *gapDetector) observe(header *headers.ResponseHeader) error {
+	if header.Status != headers.ResponseStatus_OK {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if header.ResponseID > d.responseID+1 {
+		d.gap = true
+		return errGap
+	}
+	if header.ResponseID > d.responseID {
+		d.responseID = header.ResponseID
+	}
+	return nil
+}
+
+// detected returns whether responses were missed
+func (d *gapDetector) detected() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.gap
+}
+
+// filterEvent returns a bool indicating whether the given event passes all the filters in the given options
+func filterEvent(event *Event, opts []WatchOption) bool {
+	for _, opt := range opts {
+		if filter, ok := opt.(eventFilter); ok && !filter.filterEvent(event) {
+			return false
+		}
+	}
+	return true
+}
+
 func (l *list) Slice(ctx context.Context, from int, to int) (List, error) {
 	return &slicedList{
 		from: &from,
@@ -347,6 +928,10 @@ func (l *list) SliceTo(ctx context.Context, to int) (List, error) {
 	}, nil
 }
 
+func (l *list) ReadOnly() ReadOnlyList {
+	return newReadOnlyList(l)
+}
+
 func (l *list) Clear(ctx context.Context) error {
 	_, err := l.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewListServiceClient(conn)
@@ -363,9 +948,51 @@ func (l *list) Clear(ctx context.Context) error {
 }
 
 func (l *list) Close(ctx context.Context) error {
+	if l.uniqueLock != nil {
+		if err := l.uniqueLock.Close(ctx); err != nil {
+			return err
+		}
+	}
+	l.slotMu.Lock()
+	slotLock := l.slotLock
+	l.slotMu.Unlock()
+	if slotLock != nil {
+		if err := slotLock.Close(ctx); err != nil {
+			return err
+		}
+	}
+	l.appendMu.Lock()
+	appendLock := l.appendLock
+	l.appendMu.Unlock()
+	if appendLock != nil {
+		if err := appendLock.Close(ctx); err != nil {
+			return err
+		}
+	}
 	return l.instance.Close(ctx)
 }
 
 func (l *list) Delete(ctx context.Context) error {
+	if l.uniqueLock != nil {
+		if err := l.uniqueLock.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	l.slotMu.Lock()
+	slotLock := l.slotLock
+	l.slotMu.Unlock()
+	if slotLock != nil {
+		if err := slotLock.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	l.appendMu.Lock()
+	appendLock := l.appendLock
+	l.appendMu.Unlock()
+	if appendLock != nil {
+		if err := appendLock.Delete(ctx); err != nil {
+			return err
+		}
+	}
 	return l.instance.Delete(ctx)
 }
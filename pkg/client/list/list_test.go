@@ -15,12 +15,26 @@
 package list
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/atomix/api/proto/atomix/headers"
+	api "github.com/atomix/api/proto/atomix/list"
+	sessionapi "github.com/atomix/api/proto/atomix/session"
 	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	netutil "github.com/lucasbfernandes/go-client/pkg/client/util/net"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestListOperations(t *testing.T) {
@@ -44,7 +58,7 @@ func TestListOperations(t *testing.T) {
 	assert.Error(t, err)
 	assert.True(t, errors.IsInvalid(err))
 
-	err = list.Append(context.TODO(), []byte("foo"))
+	_, err = list.Append(context.TODO(), []byte("foo"))
 	assert.NoError(t, err)
 
 	size, err = list.Len(context.TODO())
@@ -55,7 +69,7 @@ func TestListOperations(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "foo", string(value))
 
-	err = list.Append(context.TODO(), []byte("bar"))
+	_, err = list.Append(context.TODO(), []byte("bar"))
 	assert.NoError(t, err)
 
 	size, err = list.Len(context.TODO())
@@ -120,7 +134,7 @@ func TestListOperations(t *testing.T) {
 		close(done)
 	}()
 
-	err = list.Append(context.TODO(), []byte("Hello world!"))
+	_, err = list.Append(context.TODO(), []byte("Hello world!"))
 	assert.NoError(t, err)
 
 	err = list.Insert(context.TODO(), 2, []byte("Hello world again!"))
@@ -165,12 +179,18 @@ func TestListOperations(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, size)
 
-	assert.NoError(t, list.Append(context.TODO(), []byte("1")))
-	assert.NoError(t, list.Append(context.TODO(), []byte("2")))
-	assert.NoError(t, list.Append(context.TODO(), []byte("3")))
-	assert.NoError(t, list.Append(context.TODO(), []byte("4")))
-	assert.NoError(t, list.Append(context.TODO(), []byte("5")))
-	assert.NoError(t, list.Append(context.TODO(), []byte("6")))
+	_, err = list.Append(context.TODO(), []byte("1"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("2"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("3"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("4"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("5"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("6"))
+	assert.NoError(t, err)
 
 	slice, err := list.Slice(context.TODO(), 1, 4)
 	assert.NoError(t, err)
@@ -220,3 +240,1280 @@ func TestListOperations(t *testing.T) {
 	_, ok = <-ch
 	assert.False(t, ok)
 }
+
+func TestListAppendAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	values := make([][]byte, 10)
+	for i := range values {
+		values[i] = []byte(fmt.Sprintf("batch-%d", i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			_, err := list.Append(context.TODO(), []byte("other"))
+			assert.NoError(t, err)
+		}
+		close(done)
+	}()
+
+	err = list.AppendAll(context.TODO(), values)
+	assert.NoError(t, err)
+	<-done
+
+	size, err := list.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 20, size)
+
+	ch := make(chan []byte)
+	err = list.Items(context.TODO(), ch)
+	assert.NoError(t, err)
+
+	batch := make([]string, 0, len(values))
+	for value := range ch {
+		if string(value) != "other" {
+			batch = append(batch, string(value))
+		} else if len(batch) > 0 && len(batch) < len(values) {
+			assert.Fail(t, "batch interleaved with other appends")
+		}
+	}
+	assert.Len(t, batch, len(values))
+	for i, value := range values {
+		assert.Equal(t, string(value), batch[i])
+	}
+}
+
+func TestListWatchIndexRange(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	events := make(chan *Event)
+	err = list.Watch(context.TODO(), events, WithIndexRange(1, 3))
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		event := <-events
+		assert.Equal(t, EventInserted, event.Type)
+		assert.Equal(t, 1, event.Index)
+		assert.Equal(t, "b", string(event.Value))
+
+		event = <-events
+		assert.Equal(t, EventInserted, event.Type)
+		assert.Equal(t, 2, event.Index)
+		assert.Equal(t, "c", string(event.Value))
+
+		event = <-events
+		assert.Equal(t, EventRemoved, event.Type)
+		assert.Equal(t, 1, event.Index)
+		assert.Equal(t, "b", string(event.Value))
+		close(done)
+	}()
+
+	_, err = list.Append(context.TODO(), []byte("a"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("b"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("c"))
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), []byte("d"))
+	assert.NoError(t, err)
+	_, err = list.Remove(context.TODO(), 3)
+	assert.NoError(t, err)
+	_, err = list.Remove(context.TODO(), 1)
+	assert.NoError(t, err)
+	<-done
+}
+
+func TestListItemsFrom(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	err = list.AppendAll(context.TODO(), [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")})
+	assert.NoError(t, err)
+
+	ch := make(chan []byte)
+	err = list.ItemsFrom(context.TODO(), 2, ch)
+	assert.NoError(t, err)
+	value, ok := <-ch
+	assert.True(t, ok)
+	assert.Equal(t, "c", string(value))
+	value, ok = <-ch
+	assert.True(t, ok)
+	assert.Equal(t, "d", string(value))
+	_, ok = <-ch
+	assert.False(t, ok)
+
+	ch = make(chan []byte)
+	err = list.ItemsFrom(context.TODO(), 10, ch)
+	assert.NoError(t, err)
+	_, ok = <-ch
+	assert.False(t, ok)
+
+	err = list.ItemsFrom(context.TODO(), -1, make(chan []byte))
+	assert.Error(t, err)
+}
+
+func TestListValueCompression(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions, WithValueCompression(primitive.NewGzipCodec()))
+	assert.NoError(t, err)
+
+	compressible := bytes.Repeat([]byte("foo"), 1000)
+	incompressible := make([]byte, 1000)
+	_, err = rand.Read(incompressible)
+	assert.NoError(t, err)
+
+	_, err = list.Append(context.TODO(), compressible)
+	assert.NoError(t, err)
+	_, err = list.Append(context.TODO(), incompressible)
+	assert.NoError(t, err)
+
+	value, err := list.Get(context.TODO(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, compressible, value)
+	value, err = list.Get(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, incompressible, value)
+
+	ch := make(chan []byte)
+	err = list.Items(context.TODO(), ch)
+	assert.NoError(t, err)
+	assert.Equal(t, compressible, <-ch)
+	assert.Equal(t, incompressible, <-ch)
+
+	value, err = list.Remove(context.TODO(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, compressible, value)
+}
+
+func TestListElementIDs(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions, WithElementIDs())
+	assert.NoError(t, err)
+
+	err = list.AppendAll(context.TODO(), [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	assert.NoError(t, err)
+
+	entry, err := list.GetEntry(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", entry.ID)
+	assert.Equal(t, 1, entry.Index)
+	assert.Equal(t, "b", string(entry.Value))
+	id := entry.ID
+
+	err = list.Insert(context.TODO(), 0, []byte("z"))
+	assert.NoError(t, err)
+
+	entry, err = list.GetByID(context.TODO(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, id, entry.ID)
+	assert.Equal(t, 2, entry.Index)
+	assert.Equal(t, "b", string(entry.Value))
+
+	value, err := list.Get(context.TODO(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(value))
+
+	entry, err = list.RemoveByID(context.TODO(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, id, entry.ID)
+	assert.Equal(t, "b", string(entry.Value))
+
+	_, err = list.GetByID(context.TODO(), id)
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	ch := make(chan []byte)
+	err = list.Items(context.TODO(), ch)
+	assert.NoError(t, err)
+	assert.Equal(t, "z", string(<-ch))
+	assert.Equal(t, "a", string(<-ch))
+	assert.Equal(t, "c", string(<-ch))
+
+	plain, err := New(context.TODO(), primitive.NewName("default", "test", "default", "plain"), sessions)
+	assert.NoError(t, err)
+	_, err = plain.GetByID(context.TODO(), id)
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotSupported(err))
+}
+
+func TestListWatchFromVersion(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	all := make(chan *Event)
+	err = list.Watch(context.TODO(), all)
+	assert.NoError(t, err)
+
+	_, err = list.Append(context.TODO(), []byte("0"))
+	assert.NoError(t, err)
+	first := <-all
+
+	// Appends are serialized by a lock, so the versions of consecutive appends are not consecutive
+	_, err = list.Append(context.TODO(), []byte("0"))
+	assert.NoError(t, err)
+	second := <-all
+	version := second.Version + (second.Version - first.Version) + 1
+	ch := make(chan *Event)
+	err = list.Watch(context.TODO(), ch, WithFromVersion(version))
+	assert.NoError(t, err)
+
+	expected := make([]*Event, 0)
+	for i := 1; i <= 5; i++ {
+		_, err = list.Append(context.TODO(), []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+		event := <-all
+		if event.Version > version {
+			expected = append(expected, event)
+		}
+	}
+	assert.True(t, len(expected) > 0 && len(expected) < 5)
+
+	for _, event := range expected {
+		actual := <-ch
+		assert.Equal(t, event.Version, actual.Version)
+		assert.Equal(t, event.Value, actual.Value)
+	}
+
+	err = list.Watch(context.TODO(), make(chan *Event), WithFromVersion(first.Version))
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotSupported(err))
+}
+
+func TestListTrim(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = list.Append(context.TODO(), []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+
+	events := make(chan *Event)
+	err = list.Watch(context.TODO(), events)
+	assert.NoError(t, err)
+
+	removed, err := list.TrimFirst(context.TODO(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	removed, err = list.TrimLast(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	for _, value := range []string{"0", "1", "4"} {
+		event := <-events
+		assert.Equal(t, EventRemoved, event.Type)
+		assert.Equal(t, value, string(event.Value))
+	}
+
+	value, err := list.Get(context.TODO(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(value))
+
+	removed, err = list.TrimLast(context.TODO(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	size, err := list.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+
+	removed, err = list.TrimFirst(context.TODO(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+}
+
+func TestListSetRange(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = list.Append(context.TODO(), []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+
+	events := make(chan *Event)
+	err = list.Watch(context.TODO(), events)
+	assert.NoError(t, err)
+
+	err = list.SetRange(context.TODO(), 1, [][]byte{[]byte("a"), []byte("b")})
+	assert.NoError(t, err)
+
+	for i, value := range []string{"1", "2"} {
+		event := <-events
+		assert.Equal(t, EventRemoved, event.Type)
+		assert.Equal(t, i+1, event.Index)
+		assert.Equal(t, value, string(event.Value))
+		event = <-events
+		assert.Equal(t, EventInserted, event.Type)
+		assert.Equal(t, i+1, event.Index)
+		assert.Equal(t, string(rune('a'+i)), string(event.Value))
+	}
+
+	// The range may end at the end of the list
+	err = list.SetRange(context.TODO(), 3, [][]byte{[]byte("c"), []byte("d")})
+	assert.NoError(t, err)
+
+	err = list.SetRange(context.TODO(), 4, [][]byte{[]byte("e"), []byte("f")})
+	assert.Error(t, err)
+	err = list.SetRange(context.TODO(), -1, [][]byte{[]byte("e")})
+	assert.Error(t, err)
+	err = list.SetRange(context.TODO(), 5, nil)
+	assert.NoError(t, err)
+
+	ch := make(chan []byte)
+	err = list.Items(context.TODO(), ch)
+	assert.NoError(t, err)
+	var values []string
+	for value := range ch {
+		values = append(values, string(value))
+	}
+	assert.Equal(t, []string{"0", "a", "b", "c", "d"}, values)
+
+	slice, err := list.SliceFrom(context.TODO(), 2)
+	assert.NoError(t, err)
+	err = slice.SetRange(context.TODO(), 1, [][]byte{[]byte("e"), []byte("f")})
+	assert.NoError(t, err)
+	err = slice.SetRange(context.TODO(), 2, [][]byte{[]byte("g"), []byte("h")})
+	assert.Error(t, err)
+
+	value, err := list.Get(context.TODO(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "f", string(value))
+}
+
+func TestListUniqueValues(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list1, err := New(context.TODO(), name, sessions1, WithUniqueValues())
+	assert.NoError(t, err)
+	list2, err := New(context.TODO(), name, sessions2, WithUniqueValues())
+	assert.NoError(t, err)
+
+	_, err = list1.Append(context.TODO(), []byte("foo"))
+	assert.NoError(t, err)
+	_, err = list1.Append(context.TODO(), []byte("foo"))
+	assert.Equal(t, ErrDuplicateValue, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+	err = list2.Insert(context.TODO(), 0, []byte("foo"))
+	assert.Equal(t, ErrDuplicateValue, err)
+	err = list2.AppendAll(context.TODO(), [][]byte{[]byte("bar"), []byte("foo")})
+	assert.Equal(t, ErrDuplicateValue, err)
+	err = list2.AppendAll(context.TODO(), [][]byte{[]byte("bar"), []byte("bar")})
+	assert.Equal(t, ErrDuplicateValue, err)
+	err = list2.AppendAll(context.TODO(), [][]byte{[]byte("bar"), []byte("baz")})
+	assert.NoError(t, err)
+
+	// Only one of the concurrent appends of an identical value succeeds
+	var added int32
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		list := list1
+		if i%2 == 1 {
+			list = list2
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := list.Append(context.TODO(), []byte("qux"))
+			if err == nil {
+				atomic.AddInt32(&added, 1)
+			} else {
+				assert.Equal(t, ErrDuplicateValue, err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), added)
+
+	size, err := list1.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, size)
+}
+
+func TestListReadOnly(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	view := list.ReadOnly()
+	assert.Equal(t, name, view.Name())
+
+	err = list.AppendAll(context.TODO(), [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")})
+	assert.NoError(t, err)
+
+	value, err := view.Get(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(value))
+
+	size, err := view.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+
+	slice, err := view.SliceFrom(context.TODO(), 1)
+	assert.NoError(t, err)
+	ch := make(chan []byte)
+	err = slice.Items(context.TODO(), ch)
+	assert.NoError(t, err)
+	values := make([]string, 0)
+	for value := range ch {
+		values = append(values, string(value))
+	}
+	assert.Equal(t, []string{"bar", "baz"}, values)
+}
+
+func TestListClearIf(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	list2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	values := [][]byte{[]byte("foo"), []byte("bar")}
+	err = list1.AppendAll(context.TODO(), values)
+	assert.NoError(t, err)
+
+	cleared, err := list1.ClearIf(context.TODO(), IfLen(3))
+	assert.NoError(t, err)
+	assert.False(t, cleared)
+	cleared, err = list1.ClearIf(context.TODO(), IfContentHash(ContentHash([][]byte{[]byte("foob"), []byte("ar")})))
+	assert.NoError(t, err)
+	assert.False(t, cleared)
+
+	// A modification between the check and the clear causes the clear to be skipped
+	cleared, err = list1.ClearIf(context.TODO(), &modifyingCondition{
+		ClearCondition: IfLen(2),
+		modify: func() {
+			assert.NoError(t, list2.Set(context.TODO(), 0, []byte("baz")))
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, cleared)
+	size, err := list1.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+
+	cleared, err = list1.ClearIf(context.TODO(), IfContentHash(ContentHash([][]byte{[]byte("baz"), []byte("bar")})))
+	assert.NoError(t, err)
+	assert.True(t, cleared)
+	size, err = list1.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+}
+
+// modifyingCondition is a ClearCondition that modifies the list while the condition is checked
+type modifyingCondition struct {
+	ClearCondition
+	modify func()
+}
+
+func (c *modifyingCondition) Matches(values [][]byte) bool {
+	c.modify()
+	return c.ClearCondition.Matches(values)
+}
+
+func TestListBlockingPollFirst(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	// Polling an empty list times out
+	_, err = list.BlockingPollFirst(context.TODO(), 100*time.Millisecond)
+	assert.Error(t, err)
+	assert.True(t, errors.IsTimeout(err))
+
+	// A value at the head of the list is returned immediately
+	_, err = list.Append(context.TODO(), []byte("foo"))
+	assert.NoError(t, err)
+	value, err := list.BlockingPollFirst(context.TODO(), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(value))
+
+	// Each value appended by the producers is returned to exactly one blocked consumer
+	const producers = 2
+	const consumers = 4
+	const count = 20
+	values := make(chan string, producers*count)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < consumers; i++ {
+		consumerSessions, err := test.OpenSessions(partitions)
+		assert.NoError(t, err)
+		defer test.CloseSessions(consumerSessions)
+		consumer, err := New(context.TODO(), name, consumerSessions)
+		assert.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				value, err := consumer.BlockingPollFirst(context.TODO(), time.Second)
+				if errors.IsTimeout(err) {
+					return
+				}
+				assert.NoError(t, err)
+				values <- string(value)
+			}
+		}()
+	}
+
+	for i := 0; i < producers; i++ {
+		producerSessions, err := test.OpenSessions(partitions)
+		assert.NoError(t, err)
+		defer test.CloseSessions(producerSessions)
+		producer, err := New(context.TODO(), name, producerSessions)
+		assert.NoError(t, err)
+		i := i
+		go func() {
+			for j := 0; j < count; j++ {
+				_, err := producer.Append(context.TODO(), []byte(fmt.Sprintf("%d-%d", i, j)))
+				assert.NoError(t, err)
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(values)
+	polled := make(map[string]bool)
+	for value := range values {
+		assert.False(t, polled[value], "value %s polled more than once", value)
+		polled[value] = true
+	}
+	assert.Len(t, polled, producers*count)
+
+	size, err := list.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+}
+
+// renamedCodec is a codec that tags values with a different name than the codec it wraps
+type renamedCodec struct {
+	primitive.Codec
+	name string
+}
+
+func (c *renamedCodec) Name() string {
+	return c.name
+}
+
+func TestListToSlice(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	values, err := list.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, values, 0)
+
+	assert.NoError(t, list.AppendAll(context.TODO(), [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}))
+	values, err = list.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}, values)
+
+	slice, err := list.SliceFrom(context.TODO(), 1)
+	assert.NoError(t, err)
+	values, err = slice.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("bar"), []byte("baz")}, values)
+
+	values, err = list.ReadOnly().ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, values, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = list.ToSlice(ctx)
+	assert.Error(t, err)
+
+	// Values that cannot be decoded are not skipped
+	compressed, err := New(context.TODO(), name, sessions, WithValueCompression(&renamedCodec{Codec: primitive.NewGzipCodec(), name: "other"}))
+	assert.NoError(t, err)
+	_, err = compressed.Append(context.TODO(), bytes.Repeat([]byte("qux"), 100))
+	assert.NoError(t, err)
+	_, err = list.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	gzip, err := New(context.TODO(), name, sessions, WithValueCompression(primitive.NewGzipCodec()))
+	assert.NoError(t, err)
+	_, err = gzip.ToSlice(context.TODO())
+	assert.Error(t, err)
+	assert.True(t, errors.IsInvalid(err))
+}
+
+func TestListWatchLeaderChange(t *testing.T) {
+	// The watch continues on the new leader if no events were missed
+	events := watchLeaderChange(t, []uint64{3, 4})
+	assert.Len(t, events, 3)
+	for i, event := range events {
+		assert.Equal(t, EventInserted, event.Type)
+		assert.Equal(t, fmt.Sprintf("value-%d", i+2), string(event.Value))
+	}
+
+	// The watch is closed with a gap event if events were missed
+	events = watchLeaderChange(t, []uint64{5})
+	assert.Len(t, events, 3)
+	assert.Equal(t, EventInserted, events[0].Type)
+	assert.Equal(t, EventInserted, events[1].Type)
+	assert.Equal(t, EventGap, events[2].Type)
+}
+
+// watchLeaderChange watches a list whose leader publishes events 2 and 3 and then moves the watch to a new
+// leader that publishes events with the given response IDs
+// The events received by the watch are returned once the watch has been closed or has stopped receiving events.
+func watchLeaderChange(t *testing.T, responseIDs []uint64) []*Event {
+	follower, stopFollower := startWatchServer(t, &watchServer{responseIDs: responseIDs})
+	defer stopFollower()
+	leader, stopLeader := startWatchServer(t, &watchServer{responseIDs: []uint64{1, 2, 3}, leader: follower})
+	defer stopLeader()
+
+	partition := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(leader),
+	}
+	session, err := primitive.NewSession(context.TODO(), partition, primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer session.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, []*primitive.Session{session})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan *Event)
+	assert.NoError(t, list.Watch(ctx, ch))
+
+	events := make([]*Event, 0)
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		case <-time.After(time.Second):
+			return events
+		}
+	}
+}
+
+// startWatchServer starts a partition serving the given watch server and returns its address and a function
+// to stop the partition
+func startWatchServer(t *testing.T, server *watchServer) (string, func()) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	s := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(s, server)
+	api.RegisterListServiceServer(s, server)
+	go s.Serve(lis)
+	return lis.Addr().String(), s.Stop
+}
+
+// watchServer is a partition that publishes a list event for each of its response IDs to each watch
+// The first response ID is sent as the stream handshake. Once all the events have been published, the watch is
+// redirected to the leader, if any.
+type watchServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+	api.UnimplementedListServiceServer
+	responseIDs []uint64
+	leader      string
+}
+
+func (s *watchServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	return &sessionapi.OpenSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *watchServer) KeepAlive(ctx context.Context, request *sessionapi.KeepAliveRequest) (*sessionapi.KeepAliveResponse, error) {
+	return &sessionapi.KeepAliveResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *watchServer) CloseSession(ctx context.Context, request *sessionapi.CloseSessionRequest) (*sessionapi.CloseSessionResponse, error) {
+	return &sessionapi.CloseSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *watchServer) Create(ctx context.Context, request *api.CreateRequest) (*api.CreateResponse, error) {
+	return &api.CreateResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *watchServer) Close(ctx context.Context, request *api.CloseRequest) (*api.CloseResponse, error) {
+	return &api.CloseResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *watchServer) Events(request *api.EventRequest, srv api.ListService_EventsServer) error {
+	for _, responseID := range s.responseIDs {
+		responseType := headers.ResponseType_RESPONSE
+		if responseID == 1 {
+			responseType = headers.ResponseType_OPEN_STREAM
+		}
+		err := srv.Send(&api.EventResponse{
+			Header: &headers.ResponseHeader{
+				SessionID:  1,
+				StreamID:   request.Header.RequestID,
+				ResponseID: responseID,
+				Index:      responseID,
+				Type:       responseType,
+			},
+			Type:  api.EventResponse_ADDED,
+			Index: uint32(responseID),
+			Value: base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("value-%d", responseID))),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if s.leader != "" {
+		return srv.Send(&api.EventResponse{
+			Header: &headers.ResponseHeader{
+				Type:   headers.ResponseType_RESPONSE,
+				Status: headers.ResponseStatus_NOT_LEADER,
+				Leader: s.leader,
+			},
+		})
+	}
+	<-srv.Context().Done()
+	return nil
+}
+
+func TestListReplaceValue(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions, WithElementIDs())
+	assert.NoError(t, err)
+
+	err = list.AppendAll(context.TODO(), [][]byte{[]byte("foo"), []byte("bar"), []byte("foo"), []byte("baz"), []byte("foo")})
+	assert.NoError(t, err)
+
+	replaced, err := list.ReplaceValue(context.TODO(), []byte("foo"), []byte("qux"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, replaced)
+
+	values, err := list.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("qux"), []byte("bar"), []byte("qux"), []byte("baz"), []byte("qux")}, values)
+
+	replaced, err = list.ReplaceValue(context.TODO(), []byte("foo"), []byte("qux"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, replaced)
+
+	replaced, err = list.ReplaceValue(context.TODO(), []byte("bar"), []byte("foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replaced)
+
+	values, err = list.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("qux"), []byte("foo"), []byte("qux"), []byte("baz"), []byte("qux")}, values)
+}
+
+func TestListMetadata(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions, WithElementIDs())
+	assert.NoError(t, err)
+
+	events := make(chan *Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = list.Watch(ctx, events)
+	assert.NoError(t, err)
+
+	meta := map[string]string{"content-type": "text/plain", "seq": "1"}
+	_, err = list.Append(context.TODO(), []byte("foo"))
+	assert.NoError(t, err)
+	_, err = list.AppendWithMeta(context.TODO(), []byte("bar"), meta)
+	assert.NoError(t, err)
+	_, err = list.AppendWithMeta(context.TODO(), []byte{}, map[string]string{"": ""})
+	assert.NoError(t, err)
+
+	event := <-events
+	assert.Equal(t, "foo", string(event.Value))
+	assert.Nil(t, event.Metadata)
+	event = <-events
+	assert.Equal(t, "bar", string(event.Value))
+	assert.Equal(t, meta, event.Metadata)
+	event = <-events
+	assert.Len(t, event.Value, 0)
+	assert.Equal(t, map[string]string{"": ""}, event.Metadata)
+
+	value, err := list.Get(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(value))
+
+	entry, err := list.GetEntry(context.TODO(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(entry.Value))
+	assert.Nil(t, entry.Metadata)
+	entry, err = list.GetEntry(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+	assert.Equal(t, meta, entry.Metadata)
+	assert.NotEqual(t, "", entry.ID)
+
+	values, err := list.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("foo"), []byte("bar"), {}}, values)
+
+	ch := make(chan *ElementEntry)
+	assert.NoError(t, list.ReadOnly().Entries(context.TODO(), ch))
+	entries := make([]*ElementEntry, 0)
+	for entry := range ch {
+		entries = append(entries, entry)
+	}
+	assert.Len(t, entries, 3)
+	assert.Nil(t, entries[0].Metadata)
+	assert.Equal(t, meta, entries[1].Metadata)
+	assert.Equal(t, 1, entries[1].Index)
+
+	slice, err := list.SliceFrom(context.TODO(), 1)
+	assert.NoError(t, err)
+	ch = make(chan *ElementEntry)
+	assert.NoError(t, slice.Entries(context.TODO(), ch))
+	entry = <-ch
+	assert.Equal(t, 0, entry.Index)
+	assert.Equal(t, meta, entry.Metadata)
+	for range ch {
+	}
+
+	removed, err := list.RemoveByID(context.TODO(), entries[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, meta, removed.Metadata)
+
+	// Values that resemble the envelope are read back unchanged
+	malformed := append(append([]byte{}, metadataTag...), 5, 1)
+	m, value := unwrapMetadata(malformed)
+	assert.Nil(t, m)
+	assert.Equal(t, malformed, value)
+}
+
+func TestListSetIfAbsent(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	list2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	err = list1.AppendAll(context.TODO(), [][]byte{{}, []byte("foo"), {}})
+	assert.NoError(t, err)
+
+	ok, err := list1.SetIfAbsent(context.TODO(), 1, []byte("bar"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = list1.SetIfAbsent(context.TODO(), 3, []byte("bar"))
+	assert.Error(t, err)
+
+	// Writers racing for the same slot set it exactly once
+	for slot := 0; slot < 3; slot += 2 {
+		results := make(chan bool, 10)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int, l List) {
+				defer wg.Done()
+				ok, err := l.SetIfAbsent(context.TODO(), slot, []byte(fmt.Sprintf("writer-%d", i)))
+				assert.NoError(t, err)
+				results <- ok
+			}(i, []List{list1, list2}[i%2])
+		}
+		wg.Wait()
+		close(results)
+
+		set := 0
+		for ok := range results {
+			if ok {
+				set++
+			}
+		}
+		assert.Equal(t, 1, set)
+		value, err := list1.Get(context.TODO(), slot)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(value), "writer-"))
+	}
+
+	slice, err := list2.SliceFrom(context.TODO(), 1)
+	assert.NoError(t, err)
+	err = list1.Set(context.TODO(), 2, nil)
+	assert.NoError(t, err)
+	ok, err = slice.SetIfAbsent(context.TODO(), 1, []byte("baz"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	value, err := list1.Get(context.TODO(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(value))
+}
+
+func TestListAppendIndex(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	list2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+
+	index, err := list1.Append(context.TODO(), []byte("first"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+
+	// Concurrent appends return distinct indexes at which their values can be read
+	const count = 10
+	mu := sync.Mutex{}
+	indexes := make(map[int]string)
+	wg := sync.WaitGroup{}
+	for i, list := range []List{list1, list2, list1, list2} {
+		wg.Add(1)
+		go func(i int, list List) {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				value := fmt.Sprintf("%d-%d", i, j)
+				index, err := list.Append(context.TODO(), []byte(value))
+				assert.NoError(t, err)
+				mu.Lock()
+				_, ok := indexes[index]
+				assert.False(t, ok, "duplicate index %d", index)
+				indexes[index] = value
+				mu.Unlock()
+			}
+		}(i, list)
+	}
+	wg.Wait()
+
+	assert.Len(t, indexes, 4*count)
+	for index, value := range indexes {
+		assert.True(t, index > 0 && index <= 4*count)
+		actual, err := list1.Get(context.TODO(), index)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(actual))
+	}
+}
+
+func TestListIterator(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	list, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	err = list.AppendAll(context.TODO(), [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")})
+	assert.NoError(t, err)
+
+	iterator, err := list.Iterator(context.TODO())
+	assert.NoError(t, err)
+	value, ok, err := iterator.Next()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", string(value))
+	value, ok, err = iterator.Next()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "b", string(value))
+	cursor := iterator.Cursor()
+
+	// The iteration is resumed from the serialized cursor by a new instance of the list
+	assert.NoError(t, list.Close(context.TODO()))
+	list, err = New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	iterator, err = list.IteratorFrom(context.TODO(), cursor)
+	assert.NoError(t, err)
+	values := make([]string, 0)
+	for {
+		value, ok, err := iterator.Next()
+		assert.NoError(t, err)
+		if !ok {
+			break
+		}
+		values = append(values, string(value))
+	}
+	assert.Equal(t, []string{"c", "d"}, values)
+
+	// Values appended once the iterator reaches the end of the list are read by the iterator
+	_, err = list.Append(context.TODO(), []byte("e"))
+	assert.NoError(t, err)
+	value, ok, err = iterator.Next()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "e", string(value))
+	_, ok, err = iterator.Next()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// Slices are iterated relative to the start of the slice
+	slice, err := list.Slice(context.TODO(), 1, 3)
+	assert.NoError(t, err)
+	iterator, err = slice.Iterator(context.TODO())
+	assert.NoError(t, err)
+	values = make([]string, 0)
+	for {
+		value, ok, err := iterator.Next()
+		assert.NoError(t, err)
+		if !ok {
+			break
+		}
+		values = append(values, string(value))
+	}
+	assert.Equal(t, []string{"b", "c"}, values)
+
+	_, err = list.IteratorFrom(context.TODO(), "foo")
+	assert.True(t, errors.IsInvalid(err))
+}
+
+func TestListPopPush(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	queue, err := New(context.TODO(), primitive.NewName("default", "test", "default", "queue"), sessions)
+	assert.NoError(t, err)
+	processing, err := New(context.TODO(), primitive.NewName("default", "test", "default", "processing"), sessions, WithUniqueValues())
+	assert.NoError(t, err)
+
+	// Popping an empty list fails
+	_, err = queue.PopPush(context.TODO(), processing)
+	assert.True(t, errors.IsNotFound(err))
+
+	// The head of the list is moved to the end of the destination with its metadata
+	_, err = queue.AppendWithMeta(context.TODO(), []byte("foo"), map[string]string{"attempt": "1"})
+	assert.NoError(t, err)
+	_, err = queue.Append(context.TODO(), []byte("bar"))
+	assert.NoError(t, err)
+	value, err := queue.PopPush(context.TODO(), processing)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(value))
+	entry, err := processing.GetEntry(context.TODO(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(entry.Value))
+	assert.Equal(t, map[string]string{"attempt": "1"}, entry.Metadata)
+	size, err := queue.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, size)
+
+	// A value that cannot be appended to the destination is restored to the head of the list
+	err = queue.Insert(context.TODO(), 0, []byte("foo"))
+	assert.NoError(t, err)
+	_, err = queue.PopPush(context.TODO(), processing)
+	assert.Error(t, err)
+	values, err := queue.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("foo"), []byte("bar")}, values)
+	assert.NoError(t, queue.Clear(context.TODO()))
+	assert.NoError(t, processing.Clear(context.TODO()))
+
+	// Values moved by concurrent consumers are never lost or duplicated
+	const count = 50
+	const consumers = 4
+	for i := 0; i < count; i++ {
+		_, err := queue.Append(context.TODO(), []byte(fmt.Sprintf("value-%d", i)))
+		assert.NoError(t, err)
+	}
+	moved := make(chan string, count)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				value, err := queue.PopPush(context.TODO(), processing)
+				if errors.IsNotFound(err) {
+					return
+				}
+				assert.NoError(t, err)
+				moved <- string(value)
+			}
+		}()
+	}
+	wg.Wait()
+	close(moved)
+
+	returned := make(map[string]bool)
+	for value := range moved {
+		assert.False(t, returned[value])
+		returned[value] = true
+	}
+	assert.Len(t, returned, count)
+
+	size, err = queue.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+	values, err = processing.ToSlice(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, values, count)
+	for _, value := range values {
+		assert.True(t, returned[string(value)])
+		delete(returned, string(value))
+	}
+	assert.Len(t, returned, 0)
+
+	// Slices cannot be popped
+	slice, err := queue.SliceFrom(context.TODO(), 0)
+	assert.NoError(t, err)
+	_, err = slice.PopPush(context.TODO(), processing)
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// metadataTag is the prefix used to tag values stored with metadata
+var metadataTag = []byte("\x00ameta")
+
+// wrapMetadata prefixes the given value with the given metadata
+// The envelope is the metadata tag followed by the number of metadata entries as a uvarint and, for each entry
+// in key order, the length of the key as a uvarint, the key, the length of the value as a uvarint and the value.
+// The element value follows the envelope. If the metadata is empty, the value is not wrapped. The envelope is
+// wrapped in the element ID envelope if the list has element IDs, and both are encoded with the list's codec.
+func wrapMetadata(meta map[string]string, value []byte) []byte {
+	if len(meta) == 0 {
+		return value
+	}
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	wrapped := make([]byte, 0, len(metadataTag)+len(value))
+	wrapped = append(wrapped, metadataTag...)
+	wrapped = append(wrapped, buf[:binary.PutUvarint(buf, uint64(len(keys)))]...)
+	for _, key := range keys {
+		wrapped = append(wrapped, buf[:binary.PutUvarint(buf, uint64(len(key)))]...)
+		wrapped = append(wrapped, key...)
+		wrapped = append(wrapped, buf[:binary.PutUvarint(buf, uint64(len(meta[key])))]...)
+		wrapped = append(wrapped, meta[key]...)
+	}
+	return append(wrapped, value...)
+}
+
+// unwrapMetadata returns the metadata and value from the given value
+// If the value is not prefixed with a valid metadata envelope, the metadata is nil and the value is returned
+// unchanged.
+func unwrapMetadata(value []byte) (map[string]string, []byte) {
+	if !bytes.HasPrefix(value, metadataTag) {
+		return nil, value
+	}
+	wrapped := value[len(metadataTag):]
+	next := func() (string, bool) {
+		n, size := binary.Uvarint(wrapped)
+		if size <= 0 || uint64(len(wrapped)-size) < n {
+			return "", false
+		}
+		s := string(wrapped[size : size+int(n)])
+		wrapped = wrapped[size+int(n):]
+		return s, true
+	}
+
+	count, size := binary.Uvarint(wrapped)
+	if size <= 0 {
+		return nil, value
+	}
+	wrapped = wrapped[size:]
+	meta := make(map[string]string)
+	for i := uint64(0); i < count; i++ {
+		key, ok := next()
+		if !ok {
+			return nil, value
+		}
+		val, ok := next()
+		if !ok {
+			return nil, value
+		}
+		meta[key] = val
+	}
+	return meta, wrapped
+}
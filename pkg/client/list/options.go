@@ -16,8 +16,69 @@ package list
 
 import (
 	api "github.com/atomix/api/proto/atomix/list"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 )
 
+// Option is an option for a List instance
+type Option interface {
+	apply(options *options)
+}
+
+// options is a set of list options
+type options struct {
+	codec        primitive.Codec
+	elementIDs   bool
+	uniqueValues bool
+}
+
+// WithValueCompression returns an option that compresses list values with the given codec
+// Values are compressed before they're written and decompressed when they're read. Compressed values are
+// tagged, so a list may contain a mix of compressed and uncompressed values.
+func WithValueCompression(codec primitive.Codec) Option {
+	return &compressionOption{
+		codec: codec,
+	}
+}
+
+// compressionOption is a value compression option
+type compressionOption struct {
+	codec primitive.Codec
+}
+
+func (o *compressionOption) apply(options *options) {
+	options.codec = o.codec
+}
+
+// WithElementIDs returns an option that assigns a stable ID to each element written to the list
+// The ID is stored alongside the value, so lists with element IDs should be accessed only by instances with
+// element IDs enabled. See ElementEntry for the lifetime of element IDs.
+func WithElementIDs() Option {
+	return &elementIDsOption{}
+}
+
+// elementIDsOption is an element ID option
+type elementIDsOption struct{}
+
+func (o *elementIDsOption) apply(options *options) {
+	options.elementIDs = true
+}
+
+// WithUniqueValues returns an option that rejects the addition of values that are already present in the list
+// Append, AppendAll and Insert fail with ErrDuplicateValue if a value is already present in the list. The list
+// is scanned for each addition, and only additions by list instances with unique values enabled are checked.
+// Set and SetRange do not check for duplicates.
+func WithUniqueValues() Option {
+	return &uniqueValuesOption{}
+}
+
+// uniqueValuesOption is a unique values option
+type uniqueValuesOption struct{}
+
+func (o *uniqueValuesOption) apply(options *options) {
+	options.uniqueValues = true
+}
+
 // WatchOption is an option for list Watch calls
 type WatchOption interface {
 	beforeWatch(request *api.EventRequest)
@@ -38,3 +99,70 @@ func (o replayOption) beforeWatch(request *api.EventRequest) {
 func (o replayOption) afterWatch(response *api.EventResponse) {
 
 }
+
+// WithIndexRange returns a Watch option that filters events to those with an index in the range [from, to)
+// The range is applied to the absolute index reported by each event at the time the event occurred. Indexes
+// are not adjusted for inserts or removes that shift elements into or out of the range, so the range does not
+// track a fixed set of elements.
+func WithIndexRange(from int, to int) WatchOption {
+	return indexRangeOption{
+		from: from,
+		to:   to,
+	}
+}
+
+type indexRangeOption struct {
+	from int
+	to   int
+}
+
+func (o indexRangeOption) beforeWatch(request *api.EventRequest) {
+
+}
+
+func (o indexRangeOption) afterWatch(response *api.EventResponse) {
+
+}
+
+func (o indexRangeOption) filterEvent(event *Event) bool {
+	return event.Index >= o.from && event.Index < o.to
+}
+
+// WithFromVersion returns a Watch option that resumes watching the list from the given version
+// Only events with a version greater than the given version are delivered. If changes following the given
+// version may have been missed, Watch fails with a NotSupported error.
+func WithFromVersion(version uint64) WatchOption {
+	return fromVersionOption{version: version}
+}
+
+type fromVersionOption struct {
+	version uint64
+}
+
+func (o fromVersionOption) beforeWatch(request *api.EventRequest) {
+
+}
+
+func (o fromVersionOption) afterWatch(response *api.EventResponse) {
+
+}
+
+func (o fromVersionOption) filterEvent(event *Event) bool {
+	return event.Version > o.version
+}
+
+// checkFromVersion returns an error if a watch registered at the given index cannot resume from the version
+// requested in the given options
+func checkFromVersion(opts []WatchOption, index uint64) error {
+	for _, opt := range opts {
+		if o, ok := opt.(fromVersionOption); ok && o.version+1 < index {
+			return errors.New(errors.NotSupported, "cannot watch from version %d: changes prior to version %d cannot be replayed", o.version, index)
+		}
+	}
+	return nil
+}
+
+// eventFilter is implemented by Watch options that filter events on the client side
+type eventFilter interface {
+	filterEvent(event *Event) bool
+}
@@ -25,4 +25,10 @@ func TestOptions(t *testing.T) {
 	assert.False(t, request.Replay)
 	WithReplay().beforeWatch(request)
 	assert.True(t, request.Replay)
+
+	filter := WithIndexRange(1, 3).(eventFilter)
+	assert.False(t, filter.filterEvent(&Event{Index: 0}))
+	assert.True(t, filter.filterEvent(&Event{Index: 1}))
+	assert.True(t, filter.filterEvent(&Event{Index: 2}))
+	assert.False(t, filter.filterEvent(&Event{Index: 3}))
 }
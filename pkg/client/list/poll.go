@@ -0,0 +1,74 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"time"
+)
+
+// BlockingPollFirst removes and returns the value at the head of the list, waiting up to the given timeout
+// for a value to be appended if the list is empty
+func (l *list) BlockingPollFirst(ctx context.Context, timeout time.Duration) ([]byte, error) {
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Watch the list before polling it to ensure a value inserted after the poll is not missed
+	ch := make(chan *Event)
+	if err := l.Watch(pollCtx, ch); err != nil {
+		return nil, err
+	}
+	defer func() {
+		go func() {
+			for range ch {
+			}
+		}()
+	}()
+
+	for {
+		// Removing the head of the list is atomic, so if competing consumers are woken by the same
+		// insertion, only one of them removes the value and the others go back to waiting
+		value, err := l.Remove(pollCtx, 0)
+		if err == nil {
+			return value, nil
+		} else if !errors.IsInvalid(err) {
+			if pollCtx.Err() != nil && ctx.Err() == nil {
+				return nil, errors.NewTimeout("timed out waiting for a value to poll")
+			}
+			return nil, err
+		}
+		if err := awaitInsert(ctx, pollCtx, ch); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// awaitInsert waits for an EventInserted event on the given channel
+// If the poll context expires before a value is inserted, a Timeout error is returned unless the parent
+// context was itself canceled or expired.
+func awaitInsert(ctx context.Context, pollCtx context.Context, ch <-chan *Event) error {
+	for event := range ch {
+		if event.Type == EventInserted {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	} else if pollCtx.Err() != nil {
+		return errors.NewTimeout("timed out waiting for a value to poll")
+	}
+	return errors.New(errors.Unavailable, "watch closed before a value was inserted")
+}
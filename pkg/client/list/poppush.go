@@ -0,0 +1,46 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+)
+
+// PopPush removes the value at the head of the list and appends it to the given list
+func (l *list) PopPush(ctx context.Context, dest List) ([]byte, error) {
+	// Removing the head of the list is atomic, so competing consumers never move the same value
+	encoded, err := l.remove(ctx, 0)
+	if err != nil {
+		if errors.IsInvalid(err) {
+			return nil, errors.NewNotFound("list is empty")
+		}
+		return nil, err
+	}
+
+	_, meta, value, err := l.decodeEntry(encoded)
+	if err == nil {
+		_, err = dest.AppendWithMeta(ctx, value, meta)
+		if err == nil {
+			return value, nil
+		}
+	}
+
+	// The value could not be moved, so restore it to the head of the list
+	if restoreErr := l.insert(ctx, 0, encoded); restoreErr != nil {
+		return value, errors.New(errors.Internal, "failed to restore value after failed move: %s: %s", err, restoreErr)
+	}
+	return nil, err
+}
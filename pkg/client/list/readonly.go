@@ -0,0 +1,148 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+)
+
+// ReadOnlyList is a read-only view of a List
+// A ReadOnlyList does not expose the methods that modify the list, so code that's only given a ReadOnlyList
+// cannot modify the list. The view does not own the list: closing or deleting the list is left to the owner.
+type ReadOnlyList interface {
+	// Name returns the name of the list
+	Name() primitive.Name
+
+	// Get gets the value at the given index
+	Get(ctx context.Context, index int) ([]byte, error)
+
+	// GetEntry gets the element at the given index
+	GetEntry(ctx context.Context, index int) (*ElementEntry, error)
+
+	// GetByID gets the element with the given ID
+	GetByID(ctx context.Context, id string) (*ElementEntry, error)
+
+	// Len gets the length of the list
+	Len(ctx context.Context) (int, error)
+
+	// Slice returns a read-only slice of the list from the given start index to the given end index
+	Slice(ctx context.Context, from int, to int) (ReadOnlyList, error)
+
+	// SliceFrom returns a read-only slice of the list from the given index
+	SliceFrom(ctx context.Context, from int) (ReadOnlyList, error)
+
+	// SliceTo returns a read-only slice of the list to the given index
+	SliceTo(ctx context.Context, to int) (ReadOnlyList, error)
+
+	// Items iterates through the values in the list
+	// This is a non-blocking method. If the method returns without error, values will be pushed on to the
+	// given channel and the channel will be closed once all values have been read from the list.
+	Items(ctx context.Context, ch chan<- []byte) error
+
+	// ItemsFrom iterates through the values in the list starting at the given index
+	ItemsFrom(ctx context.Context, start int, ch chan<- []byte) error
+
+	// Entries iterates through the elements in the list
+	Entries(ctx context.Context, ch chan<- *ElementEntry) error
+
+	// ToSlice reads all the values in the list into a slice
+	// ToSlice is intended for lists of bounded size, since the entire list is read into memory.
+	ToSlice(ctx context.Context) ([][]byte, error)
+
+	// Watch watches the list for changes
+	// This is a non-blocking method. If the method returns without error, list events will be pushed onto
+	// the given channel. If events were missed, an EventGap event is pushed and the channel is closed.
+	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
+}
+
+var _ primitive.Sized = ReadOnlyList(nil)
+
+// newReadOnlyList returns a read-only view of the given List
+func newReadOnlyList(list List) ReadOnlyList {
+	return &readOnlyList{
+		delegate: list,
+	}
+}
+
+// readOnlyList is a ReadOnlyList that delegates reads to an underlying List
+// The underlying List is not embedded so the view cannot be converted back to a List.
+type readOnlyList struct {
+	delegate List
+}
+
+func (l *readOnlyList) Name() primitive.Name {
+	return l.delegate.Name()
+}
+
+func (l *readOnlyList) Get(ctx context.Context, index int) ([]byte, error) {
+	return l.delegate.Get(ctx, index)
+}
+
+func (l *readOnlyList) GetEntry(ctx context.Context, index int) (*ElementEntry, error) {
+	return l.delegate.GetEntry(ctx, index)
+}
+
+func (l *readOnlyList) GetByID(ctx context.Context, id string) (*ElementEntry, error) {
+	return l.delegate.GetByID(ctx, id)
+}
+
+func (l *readOnlyList) Len(ctx context.Context) (int, error) {
+	return l.delegate.Len(ctx)
+}
+
+func (l *readOnlyList) Slice(ctx context.Context, from int, to int) (ReadOnlyList, error) {
+	slice, err := l.delegate.Slice(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return newReadOnlyList(slice), nil
+}
+
+func (l *readOnlyList) SliceFrom(ctx context.Context, from int) (ReadOnlyList, error) {
+	slice, err := l.delegate.SliceFrom(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	return newReadOnlyList(slice), nil
+}
+
+func (l *readOnlyList) SliceTo(ctx context.Context, to int) (ReadOnlyList, error) {
+	slice, err := l.delegate.SliceTo(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	return newReadOnlyList(slice), nil
+}
+
+func (l *readOnlyList) Items(ctx context.Context, ch chan<- []byte) error {
+	return l.delegate.Items(ctx, ch)
+}
+
+func (l *readOnlyList) ItemsFrom(ctx context.Context, start int, ch chan<- []byte) error {
+	return l.delegate.ItemsFrom(ctx, start, ch)
+}
+
+func (l *readOnlyList) Entries(ctx context.Context, ch chan<- *ElementEntry) error {
+	return l.delegate.Entries(ctx, ch)
+}
+
+func (l *readOnlyList) ToSlice(ctx context.Context) ([][]byte, error) {
+	return l.delegate.ToSlice(ctx)
+}
+
+func (l *readOnlyList) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	return l.delegate.Watch(ctx, ch, opts...)
+}
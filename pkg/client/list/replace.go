@@ -0,0 +1,112 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"bytes"
+	"context"
+	"github.com/atomix/api/proto/atomix/headers"
+	api "github.com/atomix/api/proto/atomix/list"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"google.golang.org/grpc"
+)
+
+// replacement is the replacement of the value at an index of the list
+type replacement struct {
+	index   int
+	value   string
+	removed string
+}
+
+func (l *list) ReplaceValue(ctx context.Context, old []byte, new []byte) (int, error) {
+	unlock, err := l.lockUnique(ctx, [][]byte{new})
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	ch := make(chan *ElementEntry)
+	if err := l.entries(ctx, ch); err != nil {
+		return 0, err
+	}
+	replacements := make([]*replacement, 0)
+	for entry := range ch {
+		if bytes.Equal(entry.Value, old) {
+			replacements = append(replacements, &replacement{index: entry.Index})
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(replacements) == 0 {
+		return 0, nil
+	}
+
+	fns := make([]primitive.CommandFunc, 0, len(replacements)*2)
+	for _, r := range replacements {
+		r := r
+		value, err := l.encode(new)
+		if err != nil {
+			return 0, err
+		}
+		r.value = value
+		fns = append(fns, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewListServiceClient(conn)
+			request := &api.InsertRequest{
+				Header: header,
+				Index:  uint32(r.index),
+				Value:  r.value,
+			}
+			response, err := client.Insert(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		}, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewListServiceClient(conn)
+			request := &api.RemoveRequest{
+				Header: header,
+				Index:  uint32(r.index + 1),
+			}
+			response, err := client.Remove(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			r.removed = response.Value
+			return response.Header, response, nil
+		})
+	}
+
+	results, err := l.instance.DoBatch(ctx, fns)
+	completed := replacements[:len(results)/2]
+	if err != nil && len(results)%2 == 1 {
+		// The value was inserted but the value it replaces was not removed, so try to remove it once more
+		r := replacements[len(results)/2]
+		if removed, removeErr := l.remove(ctx, r.index+1); removeErr == nil {
+			r.removed = removed
+			completed = append(completed, r)
+		}
+	}
+
+	replaced := 0
+	for _, r := range completed {
+		if value, decodeErr := l.decode(r.removed); decodeErr == nil && bytes.Equal(value, old) {
+			replaced++
+		} else if restoreErr := l.set(ctx, r.index, r.removed); restoreErr != nil && err == nil {
+			err = restoreErr
+		}
+	}
+	return replaced, err
+}
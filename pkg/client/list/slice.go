@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"time"
 )
 
 // slicedList is a slice of a list
@@ -35,10 +36,26 @@ func (l *slicedList) inRangeIndex(index int) bool {
 	return (l.from == nil || index >= *l.from) && (l.to == nil || index < *l.to)
 }
 
-func (l *slicedList) Append(ctx context.Context, value []byte) error {
+func (l *slicedList) Append(ctx context.Context, value []byte) (int, error) {
+	return 0, errors.New("cannot append to list slice")
+}
+
+func (l *slicedList) AppendWithMeta(ctx context.Context, value []byte, meta map[string]string) (int, error) {
+	return 0, errors.New("cannot append to list slice")
+}
+
+func (l *slicedList) AppendAll(ctx context.Context, values [][]byte) error {
 	return errors.New("cannot append to list slice")
 }
 
+func (l *slicedList) TrimFirst(ctx context.Context, n int) (int, error) {
+	return 0, errors.New("cannot trim list slice")
+}
+
+func (l *slicedList) TrimLast(ctx context.Context, n int) (int, error) {
+	return 0, errors.New("cannot trim list slice")
+}
+
 func (l *slicedList) Insert(ctx context.Context, index int, value []byte) error {
 	if l.from != nil {
 		index += *l.from
@@ -59,6 +76,30 @@ func (l *slicedList) Set(ctx context.Context, index int, value []byte) error {
 	return l.list.Set(ctx, index, value)
 }
 
+func (l *slicedList) SetIfAbsent(ctx context.Context, index int, value []byte) (bool, error) {
+	if l.from != nil {
+		index += *l.from
+	}
+	if !l.inRangeIndex(index) {
+		return false, errors.New("index out of slice range")
+	}
+	return l.list.SetIfAbsent(ctx, index, value)
+}
+
+func (l *slicedList) SetRange(ctx context.Context, from int, values [][]byte) error {
+	if l.from != nil {
+		from += *l.from
+	}
+	if !l.inRangeIndex(from) || (len(values) > 0 && !l.inRangeIndex(from+len(values)-1)) {
+		return errors.New("index out of slice range")
+	}
+	return l.list.SetRange(ctx, from, values)
+}
+
+func (l *slicedList) ReplaceValue(ctx context.Context, old []byte, new []byte) (int, error) {
+	return 0, errors.New("cannot replace values in list slice")
+}
+
 func (l *slicedList) Get(ctx context.Context, index int) ([]byte, error) {
 	if l.from != nil {
 		index += *l.from
@@ -79,6 +120,50 @@ func (l *slicedList) Remove(ctx context.Context, index int) ([]byte, error) {
 	return l.list.Remove(ctx, index)
 }
 
+func (l *slicedList) GetEntry(ctx context.Context, index int) (*ElementEntry, error) {
+	if l.from != nil {
+		index += *l.from
+	}
+	if !l.inRangeIndex(index) {
+		return nil, errors.New("index out of slice range")
+	}
+	entry, err := l.list.GetEntry(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	return l.sliceEntry(entry), nil
+}
+
+func (l *slicedList) GetByID(ctx context.Context, id string) (*ElementEntry, error) {
+	entry, err := l.list.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !l.inRangeIndex(entry.Index) {
+		return nil, errors.New("element out of slice range")
+	}
+	return l.sliceEntry(entry), nil
+}
+
+func (l *slicedList) RemoveByID(ctx context.Context, id string) (*ElementEntry, error) {
+	if _, err := l.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	entry, err := l.list.RemoveByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return l.sliceEntry(entry), nil
+}
+
+// sliceEntry returns the given list entry with its index relative to the slice
+func (l *slicedList) sliceEntry(entry *ElementEntry) *ElementEntry {
+	if l.from != nil {
+		entry.Index -= *l.from
+	}
+	return entry
+}
+
 func (l *slicedList) Len(ctx context.Context) (int, error) {
 	size, err := l.list.Len(ctx)
 	if err != nil {
@@ -148,11 +233,55 @@ func (l *slicedList) Items(ctx context.Context, ch chan<- []byte) error {
 	return l.list.Items(ctx, itemsCh)
 }
 
+func (l *slicedList) Entries(ctx context.Context, ch chan<- *ElementEntry) error {
+	entryCh := make(chan *ElementEntry)
+	go func() {
+		defer close(ch)
+		for entry := range entryCh {
+			if l.inRangeIndex(entry.Index) {
+				ch <- l.sliceEntry(entry)
+			}
+		}
+	}()
+	return l.list.Entries(ctx, entryCh)
+}
+
+func (l *slicedList) ItemsFrom(ctx context.Context, start int, ch chan<- []byte) error {
+	return itemsFrom(ctx, l, start, ch)
+}
+
+func (l *slicedList) Iterator(ctx context.Context) (*ListIterator, error) {
+	return newIterator(ctx, l, 0), nil
+}
+
+func (l *slicedList) IteratorFrom(ctx context.Context, cursor string) (*ListIterator, error) {
+	index, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(ctx, l, index), nil
+}
+
+func (l *slicedList) ToSlice(ctx context.Context) ([][]byte, error) {
+	values, err := l.list.ToSlice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slice := make([][]byte, 0)
+	for i, value := range values {
+		if l.inRangeIndex(i) {
+			slice = append(slice, value)
+		}
+	}
+	return slice, nil
+}
+
 func (l *slicedList) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
 	eventCh := make(chan *Event)
 	go func() {
+		defer close(ch)
 		for event := range eventCh {
-			if (l.from == nil || *l.from >= event.Index) && (l.to == nil || event.Index < *l.to) {
+			if event.Type == EventGap || (l.from == nil || *l.from >= event.Index) && (l.to == nil || event.Index < *l.to) {
 				ch <- event
 			}
 		}
@@ -160,10 +289,26 @@ func (l *slicedList) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchO
 	return l.list.Watch(ctx, eventCh, opts...)
 }
 
+func (l *slicedList) ReadOnly() ReadOnlyList {
+	return newReadOnlyList(l)
+}
+
 func (l *slicedList) Clear(ctx context.Context) error {
 	return errors.New("cannot clear list slice")
 }
 
+func (l *slicedList) BlockingPollFirst(ctx context.Context, timeout time.Duration) ([]byte, error) {
+	return nil, errors.New("cannot poll list slice")
+}
+
+func (l *slicedList) PopPush(ctx context.Context, dest List) ([]byte, error) {
+	return nil, errors.New("cannot pop list slice")
+}
+
+func (l *slicedList) ClearIf(ctx context.Context, condition ClearCondition) (bool, error) {
+	return false, errors.New("cannot clear list slice")
+}
+
 func (l *slicedList) Close(ctx context.Context) error {
 	return l.list.Close(ctx)
 }
@@ -0,0 +1,72 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/lock"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+)
+
+// newSlotLock creates the lock that serializes conditional sets in the list with the given name
+func newSlotLock(ctx context.Context, name primitive.Name, partition *primitive.Session) (lock.Lock, error) {
+	lockName := primitive.NewName(name.Namespace, name.Database, name.Scope, fmt.Sprintf("%s.locks.slots", name.Name))
+	return lock.New(ctx, lockName, []*primitive.Session{partition})
+}
+
+// getSlotLock returns the lock that serializes conditional sets in the list, creating it on first use
+func (l *list) getSlotLock(ctx context.Context) (lock.Lock, error) {
+	l.slotMu.Lock()
+	defer l.slotMu.Unlock()
+	if l.slotLock == nil {
+		slotLock, err := newSlotLock(ctx, l.name, l.instance.Session)
+		if err != nil {
+			return nil, err
+		}
+		l.slotLock = slotLock
+	}
+	return l.slotLock, nil
+}
+
+func (l *list) SetIfAbsent(ctx context.Context, index int, value []byte) (bool, error) {
+	slotLock, err := l.getSlotLock(ctx)
+	if err != nil {
+		return false, err
+	}
+	version, err := slotLock.Lock(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_, _ = slotLock.Unlock(context.Background(), lock.IfVersion(version))
+	}()
+
+	current, err := l.Get(ctx, index)
+	if err != nil {
+		return false, err
+	} else if len(current) > 0 {
+		return false, nil
+	}
+
+	encoded, err := l.encode(value)
+	if err != nil {
+		return false, err
+	}
+	if err := l.set(ctx, index, encoded); err != nil {
+		return false, err
+	}
+	return true, nil
+}
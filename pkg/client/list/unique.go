@@ -0,0 +1,80 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/lock"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+)
+
+// ErrDuplicateValue is returned when a value is added to a list with unique values that already contains it
+var ErrDuplicateValue = errors.NewAlreadyExists("value already exists in list")
+
+// newUniqueLock creates the lock that serializes additions to the list with the given name
+func newUniqueLock(ctx context.Context, name primitive.Name, partition *primitive.Session) (lock.Lock, error) {
+	lockName := primitive.NewName(name.Namespace, name.Database, name.Scope, fmt.Sprintf("%s.locks.unique", name.Name))
+	return lock.New(ctx, lockName, []*primitive.Session{partition})
+}
+
+// lockUnique acquires the unique values lock and checks that none of the given values are present in the list
+// If the list does not have unique values, no lock is acquired. Otherwise, the returned function must be called
+// to release the lock once the values have been added.
+func (l *list) lockUnique(ctx context.Context, values [][]byte) (func(), error) {
+	if l.uniqueLock == nil {
+		return func() {}, nil
+	}
+
+	version, err := l.uniqueLock.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unlock := func() {
+		_, _ = l.uniqueLock.Unlock(context.Background(), lock.IfVersion(version))
+	}
+	if err := l.checkUnique(ctx, values); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// checkUnique returns ErrDuplicateValue if any of the given values is present in the list or is repeated
+func (l *list) checkUnique(ctx context.Context, values [][]byte) error {
+	added := make(map[string]bool)
+	for _, value := range values {
+		if added[string(value)] {
+			return ErrDuplicateValue
+		}
+		added[string(value)] = true
+	}
+
+	ch := make(chan []byte)
+	if err := l.Items(ctx, ch); err != nil {
+		return err
+	}
+	duplicate := false
+	for item := range ch {
+		if added[string(item)] {
+			duplicate = true
+		}
+	}
+	if duplicate {
+		return ErrDuplicateValue
+	}
+	return nil
+}
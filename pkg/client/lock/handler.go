@@ -18,10 +18,20 @@ import (
 	"context"
 	"github.com/atomix/api/proto/atomix/headers"
 	api "github.com/atomix/api/proto/atomix/lock"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"google.golang.org/grpc"
 )
 
+func init() {
+	primitive.Register(Type, func(ctx context.Context, name primitive.Name, sessions []*primitive.Session, opts ...interface{}) (primitive.Primitive, error) {
+		if len(opts) > 0 {
+			return nil, errors.New(errors.Invalid, "%s does not support options", Type)
+		}
+		return New(ctx, name, sessions)
+	})
+}
+
 type primitiveHandler struct{}
 
 func (h *primitiveHandler) Create(ctx context.Context, s *primitive.Instance) error {
@@ -80,6 +80,10 @@ type Log interface {
 	// Size returns the number of entries in the log
 	Size(ctx context.Context) (int, error)
 
+	// Len returns the number of entries in the log
+	// Len is equivalent to Size and implements primitive.Sized.
+	Len(ctx context.Context) (int, error)
+
 	// Clear removes all entries from the log
 	Clear(ctx context.Context) error
 
@@ -94,6 +98,8 @@ type Log interface {
 	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
 }
 
+var _ primitive.Sized = Log(nil)
+
 // Entry is an indexed key/value pair
 type Entry struct {
 	// Index is the unique, monotonically increasing, globally unique index of the entry. The index is static
@@ -513,6 +519,10 @@ func (l *log) Size(ctx context.Context) (int, error) {
 	return int(response.(*api.SizeResponse).Size_), nil
 }
 
+func (l *log) Len(ctx context.Context) (int, error) {
+	return l.Size(ctx)
+}
+
 func (l *log) Clear(ctx context.Context) error {
 	_, err := l.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewLogServiceClient(conn)
@@ -536,7 +546,7 @@ func (l *log) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption)
 		}
 		requestObject, _ := json.Marshal(request)
 		fmt.Printf("GO_CLIENT:BEFORE_EVENT_REQUEST %s\n", requestObject)
-		fmt.Printf("GO_CLIENT:STREAM_REQUEST_ID %s\n", request.Header.RequestID)
+		fmt.Printf("GO_CLIENT:STREAM_REQUEST_ID %d\n", request.Header.RequestID)
 		for _, opt := range opts {
 			opt.beforeWatch(request)
 		}
@@ -560,7 +570,7 @@ func (l *log) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption)
 		return err
 	}
 
-	fmt.Printf("GO_CLIENT:STREAM_OBJECT %s\n", stream)
+	fmt.Printf("GO_CLIENT:STREAM_OBJECT %v\n", stream)
 
 	go func() {
 		defer close(ch)
@@ -620,11 +630,16 @@ func (l *log) Entries(ctx context.Context, ch chan<- *Entry) error {
 		defer close(ch)
 		for event := range stream {
 			response := event.(*api.EntriesResponse)
-			ch <- &Entry{
+			entry := &Entry{
 				Index:     Index(response.Index),
 				Value:     response.Value,
 				Timestamp: response.Timestamp,
 			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return nil
@@ -0,0 +1,406 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"bytes"
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+)
+
+// AtomicMap is a Map with conditional updates
+// The conditional updates are implemented with optimistic concurrency control: the entry is read and then
+// updated on the condition that its version has not changed, and the update is retried if the entry was
+// concurrently modified. AtomicMap may wrap a map created with WithCache, in which case entries are read from
+// the cache and updates based on stale cached entries are retried once the cache has been updated. By default,
+// updates are retried until they succeed or their context is done. WithMaxAttempts bounds the number of attempts.
+type AtomicMap interface {
+	Map
+
+	// PutIfAbsent sets the value of the given key if the key is not present in the map
+	// The entry of the key is returned along with a bool indicating whether the value was set. If the key is
+	// already present, the existing entry is returned.
+	PutIfAbsent(ctx context.Context, key string, value []byte) (*Entry, bool, error)
+
+	// Replace sets the value of the given key if its current value equals the given value
+	// A bool indicating whether the value was replaced is returned. If the key is not present, the value is
+	// not replaced.
+	Replace(ctx context.Context, key string, oldValue []byte, newValue []byte) (bool, error)
+
+	// Update sets the value of the given key to the value returned by the given function
+	// The function is called with the current value of the key, or nil if the key is not present, and may be
+	// called more than once if the key is concurrently modified. If the function returns an error, the key is
+	// not updated and the error is returned.
+	Update(ctx context.Context, key string, f func(value []byte) ([]byte, error)) (*Entry, error)
+
+	// ComputeIfAbsent sets the value of the given key to the value returned by the given function if the key is
+	// not present in the map
+	// The entry of the key is returned: if the key is already present, the existing entry is returned and the
+	// function is not called. Otherwise, the function is called once and its value is put on the condition that
+	// the key is still not present. If the key is concurrently set, the entry that was set is returned instead,
+	// and if it's removed again before it can be read, the put is retried with the same value. If the function
+	// returns an error, the key is not updated and the error is returned.
+	ComputeIfAbsent(ctx context.Context, key string, f func() ([]byte, error)) (*Entry, error)
+
+	// ComputeIfPresent sets the value of the given key to the value returned by the given function if the key
+	// is present in the map
+	// The function is called with the current value of the key, and its value is put on the condition that the
+	// entry's version has not changed. If the key is concurrently modified, the function is called again with
+	// the new value. The updated entry is returned, or nil if the key is not present, in which case the function
+	// is not called. If the function returns an error, the key is not updated and the error is returned. Unlike
+	// Java's computeIfPresent, returning a nil value does not remove the key; use CompareAndRemove instead.
+	ComputeIfPresent(ctx context.Context, key string, f func(value []byte) ([]byte, error)) (*Entry, error)
+
+	// CompareAndRemove removes the given key if its current value equals the given value
+	// A bool indicating whether the key was removed is returned.
+	CompareAndRemove(ctx context.Context, key string, value []byte) (bool, error)
+
+	// Rename moves the value of the old key to the new key
+	// A bool indicating whether the key was renamed is returned. The key is not renamed if the old key is not
+	// present or, if IfNewKeyAbsent is passed, if the new key is present. Otherwise, the new key is overwritten.
+	Rename(ctx context.Context, oldKey string, newKey string, opts ...RenameOption) (bool, error)
+
+	// AppendToValue appends the given element to the list stored as the value of the given key
+	// The current value is decoded as a list with the given codec, the element is appended, and the list is
+	// encoded and written back on the condition that the value has not been modified. If the key is not present,
+	// the value is set to a list containing only the element. If the codec is nil, values are encoded as JSON
+	// arrays with the codec returned by NewJSONListCodec. If the current value cannot be decoded, the error
+	// returned by the codec is returned and the key is not updated.
+	AppendToValue(ctx context.Context, key string, element []byte, codec ListCodec) error
+
+	// Merge merges the given delta into the value of the given key with the given merger
+	// The merger is called with the current value of the key, or nil if the key is not present, and the merged
+	// value is put on the condition that the entry has not been modified. If the key is concurrently modified, the
+	// delta is merged into the new value, so concurrent merges are all applied. The merged entry is returned. If
+	// the merger returns an error, the key is not updated and the error is returned. MergeJSONObjects and
+	// MergeInt64Sum are mergers for common value types.
+	Merge(ctx context.Context, key string, delta []byte, merger Merger) (*Entry, error)
+}
+
+// RenameOption is an option for the Rename method
+type RenameOption interface {
+	applyRename(options *renameOptions)
+}
+
+type renameOptions struct {
+	newKeyAbsent bool
+}
+
+// IfNewKeyAbsent returns a RenameOption that renames the key only if the new key is not present in the map
+func IfNewKeyAbsent() RenameOption {
+	return newKeyAbsentOption{}
+}
+
+type newKeyAbsentOption struct{}
+
+func (o newKeyAbsentOption) applyRename(options *renameOptions) {
+	options.newKeyAbsent = true
+}
+
+// ErrTooManyConflicts is returned when a conditional update fails on every attempt allowed by WithMaxAttempts
+// because the entry was concurrently modified
+var ErrTooManyConflicts = errors.NewConflict("too many conflicts")
+
+// AtomicOption is an option for an AtomicMap
+type AtomicOption interface {
+	applyAtomic(options *atomicOptions)
+}
+
+type atomicOptions struct {
+	maxAttempts int
+}
+
+// WithMaxAttempts returns an AtomicOption that bounds the number of attempts of each conditional update
+// If the entry is concurrently modified on each of the n attempts, the update fails with ErrTooManyConflicts.
+func WithMaxAttempts(n int) AtomicOption {
+	if n <= 0 {
+		panic("max attempts must be positive")
+	}
+	return maxAttemptsOption{attempts: n}
+}
+
+type maxAttemptsOption struct {
+	attempts int
+}
+
+func (o maxAttemptsOption) applyAtomic(options *atomicOptions) {
+	options.maxAttempts = o.attempts
+}
+
+// NewAtomicMap returns an AtomicMap that updates entries in the given map
+func NewAtomicMap(m Map, opts ...AtomicOption) AtomicMap {
+	options := &atomicOptions{}
+	for _, opt := range opts {
+		opt.applyAtomic(options)
+	}
+	return &atomicMap{
+		Map:         m,
+		maxAttempts: options.maxAttempts,
+	}
+}
+
+// atomicMap is the implementation of AtomicMap
+type atomicMap struct {
+	Map
+	maxAttempts int
+}
+
+// canAttempt returns whether an update may be attempted after the given number of attempts
+func (m *atomicMap) canAttempt(attempts int) bool {
+	return m.maxAttempts == 0 || attempts < m.maxAttempts
+}
+
+// getEntry gets the entry of the given key or nil if the key is not present
+func (m *atomicMap) getEntry(ctx context.Context, key string) (*Entry, error) {
+	entry, err := m.Map.Get(ctx, key)
+	if errors.IsNotFound(err) || (err == nil && entry.Version == 0) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// isModified returns whether the given error indicates a conditional update failed because the entry was
+// concurrently modified
+// An update conditioned on the entry's version fails with a Conflict error if the entry was updated, and with
+// a NotFound or AlreadyExists error if the entry was removed.
+func isModified(err error) bool {
+	return errors.IsConflict(err) || errors.IsNotFound(err) || errors.IsAlreadyExists(err)
+}
+
+func (m *atomicMap) PutIfAbsent(ctx context.Context, key string, value []byte) (*Entry, bool, error) {
+	for attempts := 0; m.canAttempt(attempts); attempts++ {
+		entry, err := m.Map.Put(ctx, key, value, IfNotSet())
+		if err == nil {
+			return entry, true, nil
+		} else if !errors.IsAlreadyExists(err) {
+			return nil, false, err
+		}
+
+		// If the key was removed before the existing entry could be read, retry the put
+		entry, err = m.getEntry(ctx, key)
+		if err != nil {
+			return nil, false, err
+		} else if entry != nil {
+			return entry, false, nil
+		}
+	}
+	return nil, false, ErrTooManyConflicts
+}
+
+func (m *atomicMap) Replace(ctx context.Context, key string, oldValue []byte, newValue []byte) (bool, error) {
+	for attempts := 0; m.canAttempt(attempts); attempts++ {
+		entry, err := m.getEntry(ctx, key)
+		if err != nil {
+			return false, err
+		} else if entry == nil || !bytes.Equal(entry.Value, oldValue) {
+			return false, nil
+		}
+
+		_, err = m.Map.Put(ctx, key, newValue, IfVersion(entry.Version))
+		if err == nil {
+			return true, nil
+		} else if !isModified(err) {
+			return false, err
+		}
+	}
+	return false, ErrTooManyConflicts
+}
+
+func (m *atomicMap) Update(ctx context.Context, key string, f func(value []byte) ([]byte, error)) (*Entry, error) {
+	for attempts := 0; m.canAttempt(attempts); attempts++ {
+		entry, err := m.getEntry(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		var value []byte
+		var opt PutOption
+		if entry != nil {
+			value = entry.Value
+			opt = IfVersion(entry.Version)
+		} else {
+			opt = IfNotSet()
+		}
+
+		value, err = f(value)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err = m.Map.Put(ctx, key, value, opt)
+		if err == nil {
+			return entry, nil
+		} else if !isModified(err) {
+			return nil, err
+		}
+	}
+	return nil, ErrTooManyConflicts
+}
+
+func (m *atomicMap) ComputeIfAbsent(ctx context.Context, key string, f func() ([]byte, error)) (*Entry, error) {
+	var value []byte
+	computed := false
+	for attempts := 0; m.canAttempt(attempts); attempts++ {
+		entry, err := m.getEntry(ctx, key)
+		if err != nil {
+			return nil, err
+		} else if entry != nil {
+			return entry, nil
+		}
+
+		if !computed {
+			value, err = f()
+			if err != nil {
+				return nil, err
+			}
+			computed = true
+		}
+
+		entry, err = m.Map.Put(ctx, key, value, IfNotSet())
+		if err == nil {
+			return entry, nil
+		} else if !isModified(err) {
+			return nil, err
+		}
+	}
+	return nil, ErrTooManyConflicts
+}
+
+func (m *atomicMap) ComputeIfPresent(ctx context.Context, key string, f func(value []byte) ([]byte, error)) (*Entry, error) {
+	for attempts := 0; m.canAttempt(attempts); attempts++ {
+		entry, err := m.getEntry(ctx, key)
+		if err != nil || entry == nil {
+			return nil, err
+		}
+
+		value, err := f(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err = m.Map.Put(ctx, key, value, IfVersion(entry.Version))
+		if err == nil {
+			return entry, nil
+		} else if !isModified(err) {
+			return nil, err
+		}
+	}
+	return nil, ErrTooManyConflicts
+}
+
+func (m *atomicMap) CompareAndRemove(ctx context.Context, key string, value []byte) (bool, error) {
+	for attempts := 0; m.canAttempt(attempts); attempts++ {
+		entry, err := m.getEntry(ctx, key)
+		if err != nil {
+			return false, err
+		} else if entry == nil || !bytes.Equal(entry.Value, value) {
+			return false, nil
+		}
+
+		_, err = m.Map.Remove(ctx, key, IfVersion(entry.Version))
+		if err == nil {
+			return true, nil
+		} else if !isModified(err) {
+			return false, err
+		}
+	}
+	return false, ErrTooManyConflicts
+}
+
+func (m *atomicMap) Rename(ctx context.Context, oldKey string, newKey string, opts ...RenameOption) (bool, error) {
+	options := &renameOptions{}
+	for _, opt := range opts {
+		opt.applyRename(options)
+	}
+	if oldKey == newKey {
+		return false, errors.NewInvalid("cannot rename a key to itself")
+	}
+
+	for attempts := 0; m.canAttempt(attempts); attempts++ {
+		entry, err := m.getEntry(ctx, oldKey)
+		if err != nil {
+			return false, err
+		} else if entry == nil {
+			return false, nil
+		}
+
+		target, err := m.getEntry(ctx, newKey)
+		if err != nil {
+			return false, err
+		}
+		var opt PutOption = IfNotSet()
+		if target != nil {
+			if options.newKeyAbsent {
+				return false, nil
+			}
+			opt = IfVersion(target.Version)
+		}
+
+		renamed, err := m.Map.Put(ctx, newKey, entry.Value, opt)
+		if isModified(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+
+		_, err = m.Map.Remove(ctx, oldKey, IfVersion(entry.Version))
+		if err == nil {
+			return true, nil
+		}
+		if revertErr := m.revertRename(ctx, newKey, target, renamed); revertErr != nil {
+			return false, errors.New(errors.Internal, "failed to revert rename of %s to %s after error '%s': %s", oldKey, newKey, err, revertErr)
+		}
+		if !isModified(err) {
+			return false, err
+		}
+	}
+	return false, ErrTooManyConflicts
+}
+
+// revertRename restores the given key to the entry it had before it was renamed to, or removes it if the key
+// was not present
+// If the key was modified since it was renamed to, it's left unchanged.
+func (m *atomicMap) revertRename(ctx context.Context, key string, target *Entry, renamed *Entry) error {
+	var err error
+	if target != nil {
+		_, err = m.Map.Put(ctx, key, target.Value, IfVersion(renamed.Version))
+	} else {
+		_, err = m.Map.Remove(ctx, key, IfVersion(renamed.Version))
+	}
+	if err != nil && !isModified(err) {
+		return err
+	}
+	return nil
+}
+
+func (m *atomicMap) AppendToValue(ctx context.Context, key string, element []byte, codec ListCodec) error {
+	if codec == nil {
+		codec = NewJSONListCodec()
+	}
+	_, err := m.Update(ctx, key, func(value []byte) ([]byte, error) {
+		elements, err := codec.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		return codec.Encode(append(elements, element))
+	})
+	return err
+}
+
+func (m *atomicMap) Merge(ctx context.Context, key string, delta []byte, merger Merger) (*Entry, error) {
+	return m.Update(ctx, key, func(value []byte) ([]byte, error) {
+		return merger(value, delta)
+	})
+}
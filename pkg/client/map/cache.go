@@ -59,7 +59,7 @@ func (m *cachingMap) open() error {
 	}
 	go func() {
 		for event := range ch {
-			m.cacheUpdate(event.Entry, event.Type == EventRemoved)
+			m.cacheUpdate(event.Entry, event.Type.IsRemoval())
 		}
 	}()
 	return nil
@@ -142,6 +142,14 @@ func (m *cachingMap) getCache(key string) (*Entry, bool) {
 func (m *cachingMap) Get(ctx context.Context, key string, opts ...GetOption) (*Entry, error) {
 	// If the entry is already in the cache, return it
 	if entry, ok := m.getCache(key); ok {
+		if isNotModified(entry, opts) {
+			return &Entry{
+				Key:     entry.Key,
+				Version: entry.Version,
+				Created: entry.Created,
+				Updated: entry.Updated,
+			}, nil
+		}
 		return entry, nil
 	}
 
@@ -151,6 +159,11 @@ func (m *cachingMap) Get(ctx context.Context, key string, opts ...GetOption) (*E
 		return nil, err
 	}
 
+	// Entries without their value cannot be cached
+	if isNotModified(entry, opts) {
+		return entry, nil
+	}
+
 	// Update the cache if necessary
 	if err != nil {
 		return nil, err
@@ -159,17 +172,19 @@ func (m *cachingMap) Get(ctx context.Context, key string, opts ...GetOption) (*E
 	return entry, nil
 }
 
+func (m *cachingMap) GetAll(ctx context.Context, keys []string) ([]*Entry, error) {
+	return getAll(ctx, m, keys)
+}
+
 func (m *cachingMap) Put(ctx context.Context, key string, value []byte, opts ...PutOption) (*Entry, error) {
 	// Put the entry in the map using the underlying map delegate
 	entry, err := m.delegatingMap.Put(ctx, key, value, opts...)
 	if err != nil {
-		return nil, err
+		// The current entry may be returned with the error by WithReturnCurrentOnConflict
+		return entry, err
 	}
 
 	// Update the cache if necessary
-	if err != nil {
-		return nil, err
-	}
 	m.cacheRead(entry, false)
 	return entry, nil
 }
@@ -189,6 +204,22 @@ func (m *cachingMap) Remove(ctx context.Context, key string, opts ...RemoveOptio
 	return entry, nil
 }
 
+func (m *cachingMap) RemoveAll(ctx context.Context, keys []string) (int, error) {
+	// Removed entries are not returned, so the keys are invalidated rather than cached as tombstones
+	removed, err := m.delegatingMap.RemoveAll(ctx, keys)
+	m.mu.Lock()
+	for _, key := range keys {
+		delete(m.pending, key)
+		m.cache.Remove(key)
+	}
+	m.mu.Unlock()
+	return removed, err
+}
+
+func (m *cachingMap) ReadOnly() ReadOnlyMap {
+	return newReadOnlyMap(m)
+}
+
 func (m *cachingMap) Close(ctx context.Context) error {
 	m.mu.Lock()
 	if m.cancel != nil {
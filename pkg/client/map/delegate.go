@@ -43,22 +43,62 @@ func (m *delegatingMap) Get(ctx context.Context, key string, opts ...GetOption)
 	return m.delegate.Get(ctx, key, opts...)
 }
 
+func (m *delegatingMap) GetAll(ctx context.Context, keys []string) ([]*Entry, error) {
+	return m.delegate.GetAll(ctx, keys)
+}
+
+func (m *delegatingMap) AwaitKey(ctx context.Context, key string) (*Entry, error) {
+	return m.delegate.AwaitKey(ctx, key)
+}
+
 func (m *delegatingMap) Remove(ctx context.Context, key string, opts ...RemoveOption) (*Entry, error) {
 	return m.delegate.Remove(ctx, key, opts...)
 }
 
+func (m *delegatingMap) RemoveAll(ctx context.Context, keys []string) (int, error) {
+	return m.delegate.RemoveAll(ctx, keys)
+}
+
+func (m *delegatingMap) MultiCAS(ctx context.Context, conditions map[string]Version, updates map[string][]byte) (bool, error) {
+	return m.delegate.MultiCAS(ctx, conditions, updates)
+}
+
 func (m *delegatingMap) Len(ctx context.Context) (int, error) {
 	return m.delegate.Len(ctx)
 }
 
-func (m *delegatingMap) Clear(ctx context.Context) error {
-	return m.delegate.Clear(ctx)
+func (m *delegatingMap) LockKey(ctx context.Context, key string) (KeyLock, error) {
+	return m.delegate.LockKey(ctx, key)
+}
+
+func (m *delegatingMap) Clear(ctx context.Context, opts ...ClearOption) error {
+	return m.delegate.Clear(ctx, opts...)
+}
+
+func (m *delegatingMap) ReplaceAll(ctx context.Context, entries map[string][]byte) error {
+	return m.delegate.ReplaceAll(ctx, entries)
 }
 
 func (m *delegatingMap) Entries(ctx context.Context, ch chan<- *Entry) error {
 	return m.delegate.Entries(ctx, ch)
 }
 
+func (m *delegatingMap) EntriesInVersionRange(ctx context.Context, min Version, max Version, ch chan<- *Entry) error {
+	return m.delegate.EntriesInVersionRange(ctx, min, max, ch)
+}
+
+func (m *delegatingMap) Diff(ctx context.Context, fromVersion Version, ch chan<- *Event) error {
+	return m.delegate.Diff(ctx, fromVersion, ch)
+}
+
+func (m *delegatingMap) ReadOnly() ReadOnlyMap {
+	return newReadOnlyMap(m)
+}
+
+func (m *delegatingMap) Snapshot(ctx context.Context) (Version, <-chan *Entry, error) {
+	return m.delegate.Snapshot(ctx)
+}
+
 func (m *delegatingMap) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
 	return m.delegate.Watch(ctx, ch, opts...)
 }
@@ -0,0 +1,106 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"context"
+	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"sync"
+)
+
+// maxSnapshots is the number of snapshots recorded for diffs
+const maxSnapshots = 4
+
+// newSnapshotHistory returns a new history of snapshots
+func newSnapshotHistory() *snapshotHistory {
+	return &snapshotHistory{
+		snapshots: make(map[Version]map[string]Version),
+	}
+}
+
+// snapshotHistory records the versions of the keys in the most recent snapshots of a partition
+type snapshotHistory struct {
+	snapshots map[Version]map[string]Version
+	versions  []Version
+	mu        sync.Mutex
+}
+
+// record records the key versions of the snapshot at the given version
+func (h *snapshotHistory) record(version Version, keys map[string]Version) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.snapshots[version]; !ok {
+		h.versions = append(h.versions, version)
+		if len(h.versions) > maxSnapshots {
+			delete(h.snapshots, h.versions[0])
+			h.versions = h.versions[1:]
+		}
+	}
+	h.snapshots[version] = keys
+}
+
+// get returns the key versions of the snapshot at the given version
+func (h *snapshotHistory) get(version Version) (map[string]Version, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys, ok := h.snapshots[version]
+	return keys, ok
+}
+
+func (m *mapPartition) Diff(ctx context.Context, fromVersion Version, ch chan<- *Event) error {
+	keys, ok := m.snapshots.get(fromVersion)
+	if !ok {
+		return errors.NewNotSupported(fmt.Sprintf("no snapshot at version %d", fromVersion))
+	}
+
+	_, entries, err := m.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(ch)
+		removed := make(map[string]Version, len(keys))
+		for key, version := range keys {
+			removed[key] = version
+		}
+		for entry := range entries {
+			version, ok := keys[entry.Key]
+			delete(removed, entry.Key)
+			if !ok {
+				ch <- &Event{
+					Type:  EventInserted,
+					Entry: entry,
+				}
+			} else if entry.Version != version {
+				ch <- &Event{
+					Type:  EventUpdated,
+					Entry: entry,
+				}
+			}
+		}
+		for key, version := range removed {
+			ch <- &Event{
+				Type: EventRemoved,
+				Entry: &Entry{
+					Key:     key,
+					Version: version,
+				},
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"context"
+	"github.com/cenkalti/backoff"
+	"sync"
+)
+
+// IndexHandler maintains an index derived from the entries of a map, e.g. an inverted index of values to keys
+// The handler's methods are called sequentially, never concurrently.
+type IndexHandler interface {
+	// OnRebuild is called before the index is rebuilt from a snapshot of the map
+	// The handler must discard its index, since OnPut is then called for every entry of the snapshot, and changes
+	// that occurred while the map was not watched are not otherwise signaled. OnRebuild is called before the index
+	// is first built, and again each time the map is watched again after the watch stream was closed.
+	OnRebuild()
+
+	// OnPut is called when the given key is set to the given value
+	OnPut(key string, value []byte)
+
+	// OnRemove is called when the given key is removed from the map with the value it held before it was removed
+	OnRemove(key string, oldValue []byte)
+}
+
+// BuildIndex builds an index of the given map with the given handler and keeps it up to date until the context
+// is canceled
+// The map is watched before a snapshot of its entries is read, and changes received while the snapshot is read
+// are applied to it, so the index is built from a consistent snapshot and no change is missed. BuildIndex
+// returns once the index has been built from the snapshot; changes are then passed to the handler as they're
+// received. If the watch stream is closed before the context is canceled, e.g. because the client reconnected,
+// the map is watched and read again with a backoff, and the handler is signaled to rebuild the index from the
+// new snapshot. The entries of the map are held in memory, so the value removed from a key can be passed to the
+// handler. Clearing the map publishes no events, so entries removed by Clear remain in the index until it's
+// rebuilt.
+func BuildIndex(ctx context.Context, m ReadOnlyMap, handler IndexHandler) error {
+	builder := &indexBuilder{
+		m:       m,
+		handler: handler,
+	}
+	done, err := builder.sync(ctx)
+	if err != nil {
+		return err
+	}
+	go builder.run(ctx, done)
+	return nil
+}
+
+// indexBuilder maintains an index of a map
+type indexBuilder struct {
+	m       ReadOnlyMap
+	handler IndexHandler
+	entries map[string]*Entry
+	pending []*Event
+	synced  bool
+	mu      sync.Mutex
+}
+
+// run rebuilds the index each time the watch stream is closed until the context is canceled
+func (b *indexBuilder) run(ctx context.Context, done <-chan struct{}) {
+	backOff := backoff.NewExponentialBackOff()
+	backOff.MaxElapsedTime = 0
+	for {
+		<-done
+		if ctx.Err() != nil {
+			return
+		}
+		_ = backoff.Retry(func() error {
+			next, err := b.sync(ctx)
+			if err != nil {
+				return err
+			}
+			done = next
+			return nil
+		}, backoff.WithContext(backOff, ctx))
+	}
+}
+
+// sync watches the map and rebuilds the index from a snapshot of the map
+// The returned channel is closed once the watch stream is closed.
+func (b *indexBuilder) sync(ctx context.Context) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	b.mu.Lock()
+	b.pending = nil
+	b.synced = false
+	b.mu.Unlock()
+
+	events := make(chan *Event)
+	if err := b.m.Watch(ctx, events); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		for event := range events {
+			b.apply(event)
+		}
+	}()
+
+	entries := make(map[string]*Entry)
+	snapshot := make(chan *Entry)
+	if err := b.m.Entries(ctx, snapshot); err != nil {
+		cancel()
+		<-done
+		return nil, err
+	}
+	for entry := range snapshot {
+		entries[entry.Key] = entry
+	}
+	if err := ctx.Err(); err != nil {
+		cancel()
+		<-done
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, event := range b.pending {
+		applyIndexEvent(entries, event)
+	}
+	b.entries = entries
+	b.pending = nil
+	b.synced = true
+	b.handler.OnRebuild()
+	for key, entry := range entries {
+		b.handler.OnPut(key, entry.Value)
+	}
+	return done, nil
+}
+
+// apply passes the given event to the handler, or buffers it if the index is being rebuilt
+func (b *indexBuilder) apply(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.synced {
+		b.pending = append(b.pending, event)
+		return
+	}
+	if event.Entry == nil {
+		return
+	}
+	key := event.Entry.Key
+	prev := b.entries[key]
+	if !applyIndexEvent(b.entries, event) {
+		return
+	}
+	if event.Type == EventRemoved {
+		oldValue := event.Entry.Value
+		if prev != nil {
+			oldValue = prev.Value
+		}
+		b.handler.OnRemove(key, oldValue)
+	} else {
+		b.handler.OnPut(key, event.Entry.Value)
+	}
+}
+
+// applyIndexEvent applies the given event to the given entries and returns whether the entries were changed
+// Events for changes already reflected in the entries, i.e. with a version no greater than the version of the
+// key's entry, are ignored, so events received while a snapshot is read may be applied to it.
+func applyIndexEvent(entries map[string]*Entry, event *Event) bool {
+	if event.Entry == nil {
+		return false
+	}
+	key := event.Entry.Key
+	current, ok := entries[key]
+	if ok && current.Version >= event.Entry.Version {
+		return false
+	}
+	switch event.Type {
+	case EventRemoved:
+		if !ok {
+			return false
+		}
+		delete(entries, key)
+	default:
+		entries[key] = event.Entry
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build go1.23
+
+package _map //nolint:golint
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMapAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	const count = 500
+	for i := 0; i < count; i++ {
+		_, err = _map.Put(context.TODO(), strconv.Itoa(i), []byte(strconv.Itoa(i)))
+		assert.NoError(t, err)
+	}
+
+	keys := make(map[string]bool)
+	for entry, err := range All(context.TODO(), _map) {
+		assert.NoError(t, err)
+		assert.Equal(t, entry.Key, string(entry.Value))
+		keys[entry.Key] = true
+	}
+	assert.Len(t, keys, count)
+
+	// Breaking out of the loop cancels the streams, and the iteration goroutines exit
+	goroutines := runtime.NumGoroutine()
+	read := 0
+	for _, err := range All(context.TODO(), _map.ReadOnly()) {
+		assert.NoError(t, err)
+		read++
+		if read == 10 {
+			break
+		}
+	}
+	assert.Equal(t, 10, read)
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+}
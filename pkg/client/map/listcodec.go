@@ -0,0 +1,66 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"encoding/json"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+)
+
+// ListCodec encodes lists of elements as map values
+type ListCodec interface {
+	// Encode encodes the given elements as a map value
+	Encode(elements [][]byte) ([]byte, error)
+
+	// Decode decodes the given map value into its elements
+	// Decode is called with a nil value if the key is not present in the map and must return an empty list.
+	Decode(value []byte) ([][]byte, error)
+}
+
+// NewJSONListCodec returns a ListCodec that encodes lists as JSON arrays
+// Each element must itself be a JSON encoded value, e.g. the element "foo" is encoded as the string `"foo"`
+// and the list ["foo", 1] is encoded as `["foo",1]`.
+func NewJSONListCodec() ListCodec {
+	return jsonListCodec{}
+}
+
+// jsonListCodec is a ListCodec that encodes lists as JSON arrays
+type jsonListCodec struct{}
+
+func (c jsonListCodec) Encode(elements [][]byte) ([]byte, error) {
+	array := make([]json.RawMessage, len(elements))
+	for i, element := range elements {
+		if !json.Valid(element) {
+			return nil, errors.NewInvalid("list element is not valid JSON")
+		}
+		array[i] = element
+	}
+	return json.Marshal(array)
+}
+
+func (c jsonListCodec) Decode(value []byte) ([][]byte, error) {
+	if len(value) == 0 {
+		return [][]byte{}, nil
+	}
+	var array []json.RawMessage
+	if err := json.Unmarshal(value, &array); err != nil {
+		return nil, errors.NewInvalid("malformed list: " + err.Error())
+	}
+	elements := make([][]byte, len(array))
+	for i, element := range array {
+		elements[i] = element
+	}
+	return elements, nil
+}
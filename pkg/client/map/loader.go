@@ -0,0 +1,92 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"context"
+	"sync"
+)
+
+// newLoader returns a loader for the given function, or nil if no function is given
+func newLoader(f func(ctx context.Context, key string) ([]byte, error)) *loader {
+	if f == nil {
+		return nil
+	}
+	return &loader{
+		f:     f,
+		loads: make(map[string]*load),
+	}
+}
+
+// loader loads the values of keys that are not present in a map
+// Concurrent loads of the same key are combined, so the load function is called once for each key until the
+// value has been stored.
+type loader struct {
+	f     func(ctx context.Context, key string) ([]byte, error)
+	loads map[string]*load
+	mu    sync.Mutex
+}
+
+// load is an in-flight load of a key
+type load struct {
+	done  chan struct{}
+	entry *Entry
+	err   error
+}
+
+// load loads the value of the given key and stores it in the given map if the key is not yet set
+// If a load of the key is already in flight, its result is returned rather than loading the key again. If no
+// value is loaded, the given error with which the key was not found is returned.
+func (l *loader) load(ctx context.Context, m Map, key string, notFound error) (*Entry, error) {
+	l.mu.Lock()
+	if current, ok := l.loads[key]; ok {
+		l.mu.Unlock()
+		select {
+		case <-current.done:
+			return current.entry, current.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	current := &load{
+		done: make(chan struct{}),
+	}
+	l.loads[key] = current
+	l.mu.Unlock()
+
+	current.entry, current.err = l.store(ctx, m, key, notFound)
+
+	l.mu.Lock()
+	delete(l.loads, key)
+	l.mu.Unlock()
+	close(current.done)
+	return current.entry, current.err
+}
+
+// store calls the load function for the given key and puts the loaded value if the key is not yet set
+// If the key has been set concurrently, the current entry is returned.
+func (l *loader) store(ctx context.Context, m Map, key string, notFound error) (*Entry, error) {
+	value, err := l.f(ctx, key)
+	if err != nil {
+		return nil, err
+	} else if value == nil {
+		return nil, notFound
+	}
+	entry, err := m.Put(ctx, key, value, IfNotSet(), WithReturnCurrentOnConflict())
+	if err != nil && entry == nil {
+		return nil, err
+	}
+	return entry, nil
+}
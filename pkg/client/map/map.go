@@ -12,11 +12,28 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package _map provides a distributed map primitive
+//
+// The map service applies each command to a single key and keeps no history, so operations that span keys
+// or versions are composed by the client:
+//
+// RemoveAll, MultiCAS and ReplaceAll apply their updates in a single batch per partition, so no other command
+// from the same session is interleaved with them, but they are not transactional: a failed or conflicting
+// update leaves the preceding updates applied, and other clients may observe them partially applied. Rename
+// writes the new key before removing the old one, so other clients may briefly observe the value under both.
+// Key locks are backed by a lock primitive and only serialize clients that lock the key before updating it,
+// and a key lock is released if the session holding it expires.
+//
+// Conditional reads, version ranges and diffs are evaluated by the client from the entries it reads. Diff
+// compares the map to a snapshot previously taken by the same map instance, and fails with a NotSupported
+// error once the snapshot is no longer retained. Watches cannot replay changes that occurred before they were
+// registered, clearing the map publishes no events, and expired keys are currently published as EventRemoved.
 package _map //nolint:golint
 
 import (
 	"context"
 	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/util"
 	"math"
@@ -43,26 +60,87 @@ type Map interface {
 	// Get gets the value of the given key
 	Get(ctx context.Context, key string, opts ...GetOption) (*Entry, error)
 
+	// GetAll gets the values of the given keys
+	// The entries are returned in the order of the given keys. Keys that are not present in the map are omitted.
+	GetAll(ctx context.Context, keys []string) ([]*Entry, error)
+
+	// AwaitKey waits for the given key to be present in the map and returns its entry
+	// If the key is present, its entry is returned immediately. Otherwise, AwaitKey blocks until the key is set
+	// or the context is done. The map is watched before the key is read, so a concurrent write is not missed.
+	AwaitKey(ctx context.Context, key string) (*Entry, error)
+
 	// Remove removes a key from the map
 	Remove(ctx context.Context, key string, opts ...RemoveOption) (*Entry, error)
 
+	// RemoveAll removes the given keys from the map and returns the number of keys that were removed
+	// If a key cannot be removed, a *RemoveAllError identifying the key is returned with the number removed.
+	RemoveAll(ctx context.Context, keys []string) (int, error)
+
 	// Len returns the number of entries in the map
 	Len(ctx context.Context) (int, error)
 
+	// LockKey acquires an advisory lock on the given key, blocking until the lock is acquired
+	LockKey(ctx context.Context, key string) (KeyLock, error)
+
 	// Clear removes all entries from the map
-	Clear(ctx context.Context) error
+	Clear(ctx context.Context, opts ...ClearOption) error
+
+	// MultiCAS sets the given entries if the versions of all the given keys match the given versions
+	// A condition version of zero requires the key to be absent. If any condition does not match, false is
+	// returned without updating any entry.
+	MultiCAS(ctx context.Context, conditions map[string]Version, updates map[string][]byte) (bool, error)
+
+	// ReplaceAll replaces the contents of the map with the given entries
+	ReplaceAll(ctx context.Context, entries map[string][]byte) error
 
 	// Entries lists the entries in the map
 	// This is a non-blocking method. If the method returns without error, key/value paids will be pushed on to the
-	// given channel and the channel will be closed once all entries have been read from the map.
+	// given channel and the channel will be closed once all entries have been read from the map. Canceling the
+	// context stops the iteration on the partitions and closes the channel, even if entries are not being read.
 	Entries(ctx context.Context, ch chan<- *Entry) error
 
+	// EntriesInVersionRange lists the entries in the map with a version in the given inclusive range
+	// This is a non-blocking method. If the method returns without error, the matching entries will be pushed
+	// onto the given channel and the channel will be closed once all entries have been read from the map.
+	EntriesInVersionRange(ctx context.Context, min Version, max Version, ch chan<- *Entry) error
+
+	// Snapshot lists the entries in the map as of a consistent version
+	// This is a non-blocking method. If the method returns without error, the returned channel will be closed
+	// once all entries in the snapshot have been read. The returned version is the partition index at which the
+	// snapshot was taken: changes with a version greater than the snapshot version are not reflected in the
+	// snapshot, so a watcher can tail the map from that version without gaps or overlap. Because versions are
+	// assigned per partition, snapshots are only supported for maps stored in a single partition.
+	Snapshot(ctx context.Context) (Version, <-chan *Entry, error)
+
+	// Diff lists the changes to the map since the snapshot at the given version
+	// This is a non-blocking method. If the method returns without error, the net change to each key since the
+	// snapshot will be pushed onto the given channel and the channel will be closed once all changes have been
+	// listed.
+	Diff(ctx context.Context, fromVersion Version, ch chan<- *Event) error
+
 	// Watch watches the map for changes
 	// This is a non-blocking method. If the method returns without error, map events will be pushed onto
 	// the given channel in the order in which they occur.
 	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
+
+	// ReadOnly returns a read-only view of the map
+	ReadOnly() ReadOnlyMap
+}
+
+var _ primitive.Sized = Map(nil)
+
+// KeyLock is a lock held on a map key
+type KeyLock interface {
+	// Key returns the locked key
+	Key() string
+
+	// Unlock releases the lock on the key
+	Unlock(ctx context.Context) error
 }
 
+// ErrValueTooLarge is returned when a value exceeds the maximum value size of the map
+var ErrValueTooLarge = errors.NewInvalid("value too large")
+
 // Version is an entry version
 type Version uint64
 
@@ -104,8 +182,34 @@ const (
 
 	// EventRemoved indicates a key was removed from the map
 	EventRemoved EventType = "removed"
+
+	// EventExpired indicates a key was removed from the map because its TTL expired
+	// Consumers should treat EventExpired as a removal.
+	EventExpired EventType = "expired"
 )
 
+// IsRemoval returns whether the event type indicates the key was removed from the map
+func (t EventType) IsRemoval() bool {
+	return t == EventRemoved || t == EventExpired
+}
+
+// RemoveAllError is returned by RemoveAll when a key cannot be removed
+type RemoveAllError struct {
+	// Key is the key at which the removal stopped
+	Key string
+	// Err is the error with which the key could not be removed
+	Err error
+}
+
+func (e *RemoveAllError) Error() string {
+	return fmt.Sprintf("failed to remove key %s: %s", e.Key, e.Err)
+}
+
+// Unwrap returns the error with which the key could not be removed
+func (e *RemoveAllError) Unwrap() error {
+	return e.Err
+}
+
 // Event is a map change event
 type Event struct {
 	// Type indicates the change event type
@@ -123,10 +227,14 @@ func New(ctx context.Context, name primitive.Name, sessions []*primitive.Session
 	}
 
 	results, err := util.ExecuteOrderedAsync(len(sessions), func(i int) (interface{}, error) {
+		var partitionOpts []Option
 		if options.cached {
-			return newPartition(ctx, name, sessions[i], WithCache(int(math.Max(float64(options.cacheSize/len(sessions)), 1))))
+			partitionOpts = append(partitionOpts, WithCache(int(math.Max(float64(options.cacheSize/len(sessions)), 1))))
 		}
-		return newPartition(ctx, name, sessions[i])
+		if options.codec != nil {
+			partitionOpts = append(partitionOpts, WithValueCompression(options.codec))
+		}
+		return newPartition(ctx, name, sessions[i], partitionOpts...)
 	})
 	if err != nil {
 		return nil, err
@@ -138,15 +246,27 @@ func New(ctx context.Context, name primitive.Name, sessions []*primitive.Session
 	}
 
 	return &_map{
-		name:       name,
-		partitions: maps,
+		name:          name,
+		partitions:    maps,
+		maxValueSize:  options.maxValueSize,
+		warnValueSize: options.warnValueSize,
+		warnFunc:      options.warnFunc,
+		partial:       options.partial,
+		streamBuffer:  options.streamBuffer,
+		loader:        newLoader(options.loader),
 	}, nil
 }
 
 // _map is the default single-partition implementation of Map
 type _map struct {
-	name       primitive.Name
-	partitions []Map
+	name          primitive.Name
+	partitions    []Map
+	maxValueSize  int
+	warnValueSize int
+	warnFunc      func(key string, size int)
+	partial       bool
+	streamBuffer  int
+	loader        *loader
 }
 
 func (m *_map) Name() primitive.Name {
@@ -161,7 +281,21 @@ func (m *_map) getPartition(key string) (Map, error) {
 	return m.partitions[i], nil
 }
 
+// checkValueSize checks the size of the given value against the configured limits
+func (m *_map) checkValueSize(key string, value []byte) error {
+	if m.maxValueSize > 0 && len(value) > m.maxValueSize {
+		return ErrValueTooLarge
+	}
+	if m.warnFunc != nil && len(value) > m.warnValueSize {
+		m.warnFunc(key, len(value))
+	}
+	return nil
+}
+
 func (m *_map) Put(ctx context.Context, key string, value []byte, opts ...PutOption) (*Entry, error) {
+	if err := m.checkValueSize(key, value); err != nil {
+		return nil, err
+	}
 	session, err := m.getPartition(key)
 	if err != nil {
 		return nil, err
@@ -176,13 +310,60 @@ func (m *_map) Get(ctx context.Context, key string, opts ...GetOption) (*Entry,
 	}
 	entry, err := session.Get(ctx, key, opts...)
 	if err != nil {
+		if errors.IsNotFound(err) && m.loader != nil {
+			return m.loader.load(ctx, m, key, err)
+		}
 		return nil, err
-	} else if entry.Value == nil {
+	} else if entry.Value == nil && !isNotModified(entry, opts) {
 		return nil, nil
 	}
 	return entry, nil
 }
 
+func (m *_map) GetAll(ctx context.Context, keys []string) ([]*Entry, error) {
+	partitionKeys := make([][]string, len(m.partitions))
+	for _, key := range keys {
+		i, err := util.GetPartitionIndex(key, len(m.partitions))
+		if err != nil {
+			return nil, err
+		}
+		partitionKeys[i] = append(partitionKeys[i], key)
+	}
+
+	results, err := util.ExecuteAsync(len(m.partitions), func(i int) (interface{}, error) {
+		if len(partitionKeys[i]) == 0 {
+			return []*Entry{}, nil
+		}
+		return m.partitions[i].GetAll(ctx, partitionKeys[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*Entry)
+	for _, result := range results {
+		for _, entry := range result.([]*Entry) {
+			entries[entry.Key] = entry
+		}
+	}
+
+	ordered := make([]*Entry, 0, len(entries))
+	for _, key := range keys {
+		if entry, ok := entries[key]; ok {
+			ordered = append(ordered, entry)
+		}
+	}
+	return ordered, nil
+}
+
+func (m *_map) AwaitKey(ctx context.Context, key string) (*Entry, error) {
+	partition, err := m.getPartition(key)
+	if err != nil {
+		return nil, err
+	}
+	return partition.AwaitKey(ctx, key)
+}
+
 func (m *_map) Remove(ctx context.Context, key string, opts ...RemoveOption) (*Entry, error) {
 	session, err := m.getPartition(key)
 	if err != nil {
@@ -191,7 +372,52 @@ func (m *_map) Remove(ctx context.Context, key string, opts ...RemoveOption) (*E
 	return session.Remove(ctx, key, opts...)
 }
 
+func (m *_map) RemoveAll(ctx context.Context, keys []string) (int, error) {
+	partitionKeys := make([][]string, len(m.partitions))
+	for _, key := range keys {
+		i, err := util.GetPartitionIndex(key, len(m.partitions))
+		if err != nil {
+			return 0, err
+		}
+		partitionKeys[i] = append(partitionKeys[i], key)
+	}
+
+	removed := make([]int, len(m.partitions))
+	_, errs := util.ExecuteAllAsync(len(m.partitions), func(i int) (interface{}, error) {
+		if len(partitionKeys[i]) == 0 {
+			return nil, nil
+		}
+		n, err := m.partitions[i].RemoveAll(ctx, partitionKeys[i])
+		removed[i] = n
+		return nil, err
+	})
+
+	total := 0
+	for _, n := range removed {
+		total += n
+	}
+	if len(errs) == 1 {
+		for _, err := range errs {
+			return total, err
+		}
+	} else if len(errs) > 1 {
+		return total, &errors.PartialError{Errors: errs}
+	}
+	return total, nil
+}
+
+func (m *_map) LockKey(ctx context.Context, key string) (KeyLock, error) {
+	session, err := m.getPartition(key)
+	if err != nil {
+		return nil, err
+	}
+	return session.LockKey(ctx, key)
+}
+
 func (m *_map) Len(ctx context.Context) (int, error) {
+	if m.partial {
+		return m.lenPartial(ctx)
+	}
 	results, err := util.ExecuteAsync(len(m.partitions), func(i int) (interface{}, error) {
 		return m.partitions[i].Len(ctx)
 	})
@@ -207,6 +433,12 @@ func (m *_map) Len(ctx context.Context) (int, error) {
 }
 
 func (m *_map) Entries(ctx context.Context, ch chan<- *Entry) error {
+	if m.streamBuffer > 0 {
+		ch = bufferEntries(ctx, ch, m.streamBuffer)
+	}
+	if m.partial {
+		return m.entriesPartial(ctx, ch)
+	}
 	n := len(m.partitions)
 	wg := sync.WaitGroup{}
 	wg.Add(n)
@@ -220,7 +452,10 @@ func (m *_map) Entries(ctx context.Context, ch chan<- *Entry) error {
 		partitionCh := make(chan *Entry)
 		go func() {
 			for kv := range partitionCh {
-				ch <- kv
+				select {
+				case ch <- kv:
+				case <-ctx.Done():
+				}
 			}
 			wg.Done()
 		}()
@@ -228,13 +463,309 @@ func (m *_map) Entries(ctx context.Context, ch chan<- *Entry) error {
 	})
 }
 
-func (m *_map) Clear(ctx context.Context) error {
+// bufferEntries returns a channel that buffers up to the given number of entries before they're pushed onto
+// the given channel
+// The given channel is closed once the returned channel has been closed and the buffered entries have been
+// pushed. If the context is canceled, the remaining entries are dropped.
+func bufferEntries(ctx context.Context, ch chan<- *Entry, size int) chan<- *Entry {
+	buffer := make(chan *Entry, size)
+	go func() {
+		defer close(ch)
+		for entry := range buffer {
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return buffer
+}
+
+// bufferEvents returns a channel that buffers up to the given number of events before they're pushed onto
+// the given channel
+// The given channel is closed once the returned channel has been closed and the buffered events have been
+// pushed.
+func bufferEvents(ch chan<- *Event, size int) chan<- *Event {
+	buffer := make(chan *Event, size)
+	go func() {
+		defer close(ch)
+		for event := range buffer {
+			ch <- event
+		}
+	}()
+	return buffer
+}
+
+// coalesceEvents returns a channel that delivers the most recent event for each key received within the given
+// window of the key's first pending event to the given channel
+// Keys are queued in the order in which their first pending event is received, so since every key is held for
+// the same window, the queue is also ordered by the time at which the keys' windows elapse.
+func coalesceEvents(ch chan<- *Event, window time.Duration) chan<- *Event {
+	events := make(chan *Event)
+	go func() {
+		defer close(ch)
+		pending := make(map[string]*Event)
+		var keys []string
+		var deadlines []time.Time
+		timer := time.NewTimer(window)
+		timer.Stop()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					timer.Stop()
+					for _, key := range keys {
+						ch <- pending[key]
+					}
+					return
+				}
+				if event.Entry == nil {
+					ch <- event
+					continue
+				}
+				key := event.Entry.Key
+				if _, ok := pending[key]; !ok {
+					if len(keys) == 0 {
+						timer.Reset(window)
+					}
+					keys = append(keys, key)
+					deadlines = append(deadlines, time.Now().Add(window))
+				}
+				pending[key] = event
+			case <-timer.C:
+				now := time.Now()
+				for len(keys) > 0 && !deadlines[0].After(now) {
+					ch <- pending[keys[0]]
+					delete(pending, keys[0])
+					keys = keys[1:]
+					deadlines = deadlines[1:]
+				}
+				if len(keys) > 0 {
+					timer.Reset(time.Until(deadlines[0]))
+				}
+			}
+		}
+	}()
+	return events
+}
+
+// lenPartial returns the number of entries in the partitions that can be read
+// If any partition cannot be read, a PartialError is returned along with the number of entries in the other
+// partitions.
+func (m *_map) lenPartial(ctx context.Context) (int, error) {
+	results, errs := util.ExecuteAllAsync(len(m.partitions), func(i int) (interface{}, error) {
+		return m.partitions[i].Len(ctx)
+	})
+	total := 0
+	for _, result := range results {
+		if result != nil {
+			total += result.(int)
+		}
+	}
+	if errs != nil {
+		return total, &errors.PartialError{Errors: errs}
+	}
+	return total, nil
+}
+
+// entriesPartial lists the entries in the partitions that can be read
+// If the entries of any partition cannot be listed, a PartialError is returned, and the entries of the other
+// partitions are still pushed onto the given channel.
+func (m *_map) entriesPartial(ctx context.Context, ch chan<- *Entry) error {
+	n := len(m.partitions)
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	_, errs := util.ExecuteAllAsync(n, func(i int) (interface{}, error) {
+		partitionCh := make(chan *Entry)
+		go func() {
+			for kv := range partitionCh {
+				select {
+				case ch <- kv:
+				case <-ctx.Done():
+				}
+			}
+			wg.Done()
+		}()
+		if err := m.partitions[i].Entries(ctx, partitionCh); err != nil {
+			close(partitionCh)
+			return nil, err
+		}
+		return nil, nil
+	})
+	if errs != nil {
+		return &errors.PartialError{Errors: errs}
+	}
+	return nil
+}
+
+func (m *_map) ReadOnly() ReadOnlyMap {
+	return newReadOnlyMap(m)
+}
+
+func (m *_map) Snapshot(ctx context.Context) (Version, <-chan *Entry, error) {
+	if len(m.partitions) != 1 {
+		return 0, nil, errors.NewNotSupported("snapshots are not supported for maps stored in multiple partitions")
+	}
+	return m.partitions[0].Snapshot(ctx)
+}
+
+func (m *_map) EntriesInVersionRange(ctx context.Context, min Version, max Version, ch chan<- *Entry) error {
+	if len(m.partitions) != 1 {
+		return errors.NewNotSupported("version ranges are not supported for maps stored in multiple partitions")
+	}
+	return entriesInVersionRange(ctx, m.Entries, min, max, ch)
+}
+
+// entriesInVersionRange pushes the entries listed by the given function with a version in the given inclusive
+// range onto the given channel
+func entriesInVersionRange(ctx context.Context, entries func(context.Context, chan<- *Entry) error, min Version, max Version, ch chan<- *Entry) error {
+	if min > max {
+		return errors.NewInvalid(fmt.Sprintf("invalid version range [%d, %d]", min, max))
+	}
+	all := make(chan *Entry)
+	if err := entries(ctx, all); err != nil {
+		return err
+	}
+	go func() {
+		defer close(ch)
+		for entry := range all {
+			if entry.Version < min || entry.Version > max {
+				continue
+			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *_map) Diff(ctx context.Context, fromVersion Version, ch chan<- *Event) error {
+	if len(m.partitions) != 1 {
+		return errors.NewNotSupported("diffs are not supported for maps stored in multiple partitions")
+	}
+	return m.partitions[0].Diff(ctx, fromVersion, ch)
+}
+
+func (m *_map) Clear(ctx context.Context, opts ...ClearOption) error {
+	if err := checkClear(ctx, m, opts); err != nil {
+		return err
+	}
 	return util.IterAsync(len(m.partitions), func(i int) error {
 		return m.partitions[i].Clear(ctx)
 	})
 }
 
+func (m *_map) MultiCAS(ctx context.Context, conditions map[string]Version, updates map[string][]byte) (bool, error) {
+	partitionConditions := make([]map[string]Version, len(m.partitions))
+	partitionUpdates := make([]map[string][]byte, len(m.partitions))
+	for i := range m.partitions {
+		partitionConditions[i] = make(map[string]Version)
+		partitionUpdates[i] = make(map[string][]byte)
+	}
+	for key, version := range conditions {
+		i, err := util.GetPartitionIndex(key, len(m.partitions))
+		if err != nil {
+			return false, err
+		}
+		partitionConditions[i][key] = version
+	}
+	for key, value := range updates {
+		if err := m.checkValueSize(key, value); err != nil {
+			return false, err
+		}
+		i, err := util.GetPartitionIndex(key, len(m.partitions))
+		if err != nil {
+			return false, err
+		}
+		partitionUpdates[i][key] = value
+	}
+
+	var partitions []int
+	for i := range m.partitions {
+		if len(partitionConditions[i]) > 0 || len(partitionUpdates[i]) > 0 {
+			partitions = append(partitions, i)
+		}
+	}
+	if len(partitions) == 0 {
+		return true, nil
+	} else if len(partitions) == 1 {
+		i := partitions[0]
+		return m.partitions[i].MultiCAS(ctx, partitionConditions[i], partitionUpdates[i])
+	}
+
+	// Check the conditions in all partitions before updating any partition
+	results, err := util.ExecuteAsync(len(partitions), func(i int) (interface{}, error) {
+		return checkVersions(ctx, m.partitions[partitions[i]], partitionConditions[partitions[i]])
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, result := range results {
+		if !result.(bool) {
+			return false, nil
+		}
+	}
+
+	results, err = util.ExecuteAsync(len(partitions), func(i int) (interface{}, error) {
+		return m.partitions[partitions[i]].MultiCAS(ctx, partitionConditions[partitions[i]], partitionUpdates[partitions[i]])
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, result := range results {
+		if !result.(bool) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m *_map) ReplaceAll(ctx context.Context, entries map[string][]byte) error {
+	partitionEntries := make([]map[string][]byte, len(m.partitions))
+	for i := range partitionEntries {
+		partitionEntries[i] = make(map[string][]byte)
+	}
+	for key, value := range entries {
+		if err := m.checkValueSize(key, value); err != nil {
+			return err
+		}
+		i, err := util.GetPartitionIndex(key, len(m.partitions))
+		if err != nil {
+			return err
+		}
+		partitionEntries[i][key] = value
+	}
+
+	return util.IterAsync(len(m.partitions), func(i int) error {
+		return m.partitions[i].ReplaceAll(ctx, partitionEntries[i])
+	})
+}
+
 func (m *_map) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	if _, ok := getFromVersion(opts); ok && len(m.partitions) != 1 {
+		return errors.NewNotSupported("watching from a version is not supported for maps stored in multiple partitions")
+	}
+
+	if m.streamBuffer > 0 {
+		ch = bufferEvents(ch, m.streamBuffer)
+	}
+	if window, ok := getCoalesceWindow(opts); ok {
+		ch = coalesceEvents(ch, window)
+	}
+	var values *prevValues
+	if isIncludePrevValue(opts) {
+		values = newPrevValues()
+		ch = values.fill(ch)
+	}
+
 	n := len(m.partitions)
 	wg := &sync.WaitGroup{}
 	wg.Add(n)
@@ -244,7 +775,7 @@ func (m *_map) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption)
 		close(ch)
 	}()
 
-	return util.IterAsync(n, func(i int) error {
+	err := util.IterAsync(n, func(i int) error {
 		partitionCh := make(chan *Event)
 		go func() {
 			for event := range partitionCh {
@@ -254,6 +785,116 @@ func (m *_map) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption)
 		}()
 		return m.partitions[i].Watch(ctx, partitionCh, opts...)
 	})
+	if err != nil || values == nil {
+		return err
+	}
+
+	// The entries are read once the watch has been registered, so no change is missed between the two
+	entries := make(chan *Entry)
+	if err := m.Entries(ctx, entries); err != nil {
+		return err
+	}
+	for entry := range entries {
+		values.seed(entry)
+	}
+	values.seeded()
+	return nil
+}
+
+// newPrevValues returns a new tracker of the last values of the keys observed by a watch
+func newPrevValues() *prevValues {
+	return &prevValues{
+		entries: make(map[string]*prevValue),
+	}
+}
+
+// prevValues tracks the last value of each key observed by a watch
+// The values are seeded from the entries of the map, which are read concurrently with the events, so values
+// are only replaced by values with a greater version. Removed keys are recorded with the version at which they
+// were removed until seeding has completed, so that an entry read before a removal is not restored.
+type prevValues struct {
+	entries map[string]*prevValue
+	seeding bool
+	mu      sync.Mutex
+}
+
+// prevValue is the last value of a key
+type prevValue struct {
+	value   []byte
+	version Version
+	removed bool
+}
+
+// fill returns a channel that fills in the value of removal events pushed onto it from the last value of the
+// key before pushing them onto the given channel
+func (v *prevValues) fill(ch chan<- *Event) chan<- *Event {
+	v.seeding = true
+	events := make(chan *Event)
+	go func() {
+		defer close(ch)
+		for event := range events {
+			if event.Entry != nil {
+				v.update(event)
+			}
+			ch <- event
+		}
+	}()
+	return events
+}
+
+// update records the value of the key of the given event and fills in the value of removal events
+func (v *prevValues) update(event *Event) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key := event.Entry.Key
+	version := event.Entry.Version
+	current := v.entries[key]
+	if !event.Type.IsRemoval() {
+		if current == nil || current.version < version {
+			v.entries[key] = &prevValue{
+				value:   event.Entry.Value,
+				version: version,
+			}
+		}
+		return
+	}
+
+	if len(event.Entry.Value) == 0 && current != nil && !current.removed && current.version < version {
+		event.Entry.Value = current.value
+	}
+	if !v.seeding {
+		delete(v.entries, key)
+	} else if current == nil || current.version < version {
+		v.entries[key] = &prevValue{
+			version: version,
+			removed: true,
+		}
+	}
+}
+
+// seed records the value of the given entry unless a later value of the key has been observed
+func (v *prevValues) seed(entry *Entry) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	current := v.entries[entry.Key]
+	if current == nil || current.version < entry.Version {
+		v.entries[entry.Key] = &prevValue{
+			value:   entry.Value,
+			version: entry.Version,
+		}
+	}
+}
+
+// seeded discards the removed keys once seeding has completed
+func (v *prevValues) seeded() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.seeding = false
+	for key, value := range v.entries {
+		if value.removed {
+			delete(v.entries, key)
+		}
+	}
 }
 
 func (m *_map) Close(ctx context.Context) error {
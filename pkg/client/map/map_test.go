@@ -15,12 +15,30 @@
 package _map //nolint:golint
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/atomix/api/proto/atomix/headers"
+	api "github.com/atomix/api/proto/atomix/map"
+	sessionapi "github.com/atomix/api/proto/atomix/session"
 	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/lucasbfernandes/go-client/pkg/client/util"
+	netutil "github.com/lucasbfernandes/go-client/pkg/client/util/net"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"net"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestMapOperations(t *testing.T) {
@@ -56,6 +74,11 @@ func TestMapOperations(t *testing.T) {
 	assert.Equal(t, "bar", string(kv.Value))
 	version := kv.Version
 
+	kv, err = _map.Get(context.Background(), "foo", WithConsistency(Eventual))
+	assert.NoError(t, err)
+	assert.NotNil(t, kv)
+	assert.Equal(t, version, kv.Version)
+
 	size, err = _map.Len(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 1, size)
@@ -229,3 +252,2004 @@ func TestMapStreams(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, size)
 }
+
+func TestMapGetAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("a"))
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "bar", []byte("b"))
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "baz", []byte("c"))
+	assert.NoError(t, err)
+
+	entries, err := _map.GetAll(context.TODO(), []string{"baz", "none", "foo", "bar"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "baz", entries[0].Key)
+	assert.Equal(t, "c", string(entries[0].Value))
+	assert.Equal(t, "foo", entries[1].Key)
+	assert.Equal(t, "a", string(entries[1].Value))
+	assert.Equal(t, "bar", entries[2].Key)
+	assert.Equal(t, "b", string(entries[2].Value))
+}
+
+func TestMapReplaceAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("a"))
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "bar", []byte("b"))
+	assert.NoError(t, err)
+
+	err = _map.ReplaceAll(context.TODO(), map[string][]byte{
+		"bar": []byte("c"),
+		"baz": []byte("d"),
+		"qux": []byte("e"),
+	})
+	assert.NoError(t, err)
+
+	size, err := _map.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+
+	_, err = _map.Get(context.TODO(), "foo")
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	entries, err := _map.GetAll(context.TODO(), []string{"bar", "baz", "qux"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "c", string(entries[0].Value))
+	assert.Equal(t, "d", string(entries[1].Value))
+	assert.Equal(t, "e", string(entries[2].Value))
+
+	err = _map.ReplaceAll(context.TODO(), map[string][]byte{})
+	assert.NoError(t, err)
+
+	size, err = _map.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+}
+
+func TestMapClearExpectedSize(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("a"))
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "bar", []byte("b"))
+	assert.NoError(t, err)
+
+	err = _map.Clear(context.TODO(), WithExpectedSize(3))
+	assert.Error(t, err)
+	assert.True(t, errors.IsConflict(err))
+
+	size, err := _map.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+
+	err = _map.Clear(context.TODO(), WithExpectedSize(2))
+	assert.NoError(t, err)
+
+	size, err = _map.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+}
+
+func TestMapValueCompression(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	compressed, err := New(context.TODO(), name, sessions, WithValueCompression(primitive.NewGzipCodec()))
+	assert.NoError(t, err)
+
+	plain, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	compressible := bytes.Repeat([]byte("foo"), 1000)
+	incompressible := make([]byte, 1000)
+	_, err = rand.Read(incompressible)
+	assert.NoError(t, err)
+
+	_, err = compressed.Put(context.TODO(), "compressible", compressible)
+	assert.NoError(t, err)
+	_, err = compressed.Put(context.TODO(), "incompressible", incompressible)
+	assert.NoError(t, err)
+	_, err = plain.Put(context.TODO(), "plain", []byte("bar"))
+	assert.NoError(t, err)
+
+	entry, err := plain.Get(context.TODO(), "compressible")
+	assert.NoError(t, err)
+	assert.True(t, len(entry.Value) < len(compressible))
+
+	entry, err = compressed.Get(context.TODO(), "compressible")
+	assert.NoError(t, err)
+	assert.Equal(t, compressible, entry.Value)
+	entry, err = compressed.Get(context.TODO(), "incompressible")
+	assert.NoError(t, err)
+	assert.Equal(t, incompressible, entry.Value)
+	entry, err = compressed.Get(context.TODO(), "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	ch := make(chan *Entry)
+	err = compressed.Entries(context.TODO(), ch)
+	assert.NoError(t, err)
+	values := make(map[string][]byte)
+	for entry := range ch {
+		values[entry.Key] = entry.Value
+	}
+	assert.Equal(t, compressible, values["compressible"])
+	assert.Equal(t, incompressible, values["incompressible"])
+	assert.Equal(t, "bar", string(values["plain"]))
+
+	entry, err = compressed.Remove(context.TODO(), "compressible")
+	assert.NoError(t, err)
+	assert.Equal(t, compressible, entry.Value)
+}
+
+func TestMapValueSizeLimits(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	limited, err := New(context.TODO(), name, sessions, WithMaxValueSize(8))
+	assert.NoError(t, err)
+
+	_, err = limited.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+
+	_, err = limited.Put(context.TODO(), "foo", []byte("too large"))
+	assert.Equal(t, ErrValueTooLarge, err)
+	assert.True(t, errors.IsInvalid(err))
+
+	err = limited.ReplaceAll(context.TODO(), map[string][]byte{"foo": []byte("bar"), "baz": []byte("too large")})
+	assert.Equal(t, ErrValueTooLarge, err)
+
+	entry, err := limited.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	var warnings []string
+	warned, err := New(context.TODO(), name, sessions, WithValueSizeWarn(8, func(key string, size int) {
+		warnings = append(warnings, fmt.Sprintf("%s:%d", key, size))
+	}))
+	assert.NoError(t, err)
+
+	_, err = warned.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 0)
+
+	_, err = warned.Put(context.TODO(), "foo", []byte("too large"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo:9"}, warnings)
+
+	entry, err = warned.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "too large", string(entry.Value))
+}
+
+func TestMapLockKey(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	name := primitive.NewName("default", "test", "default", "test")
+
+	n := 10
+	held := int32(0)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		sessions, err := test.OpenSessions(partitions)
+		assert.NoError(t, err)
+		defer test.CloseSessions(sessions)
+
+		_map, err := New(context.TODO(), name, sessions)
+		assert.NoError(t, err)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				lock, err := _map.LockKey(context.TODO(), "foo")
+				assert.NoError(t, err)
+				assert.Equal(t, "foo", lock.Key())
+				assert.Equal(t, int32(1), atomic.AddInt32(&held, 1))
+
+				value := 0
+				entry, err := _map.Get(context.TODO(), "foo")
+				if err == nil {
+					value, err = strconv.Atoi(string(entry.Value))
+					assert.NoError(t, err)
+				} else {
+					assert.True(t, errors.IsNotFound(err))
+				}
+				_, err = _map.Put(context.TODO(), "foo", []byte(strconv.Itoa(value+1)))
+				assert.NoError(t, err)
+
+				atomic.AddInt32(&held, -1)
+				assert.NoError(t, lock.Unlock(context.TODO()))
+			}
+		}()
+	}
+	wg.Wait()
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	entry, err := _map.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(n*2), string(entry.Value))
+}
+
+func TestMapSnapshot(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("1"))
+	assert.NoError(t, err)
+
+	events := make(chan *Event)
+	err = _map.Watch(context.TODO(), events)
+	assert.NoError(t, err)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("2"))
+	assert.NoError(t, err)
+
+	version, entries, err := _map.Snapshot(context.TODO())
+	assert.NoError(t, err)
+	assert.NotEqual(t, Version(0), version)
+
+	// Update the key while the snapshot is being read
+	_, err = _map.Put(context.TODO(), "foo", []byte("3"))
+	assert.NoError(t, err)
+
+	seen := make(map[Version]bool)
+	values := make(map[string]string)
+	for entry := range entries {
+		assert.True(t, entry.Version <= version)
+		assert.False(t, seen[entry.Version])
+		seen[entry.Version] = true
+		values[entry.Key] = string(entry.Value)
+	}
+	assert.Equal(t, "2", values["foo"])
+
+	_, err = _map.Put(context.TODO(), "bar", []byte("1"))
+	assert.NoError(t, err)
+
+	for len(values) < 2 || values["foo"] != "3" {
+		event := <-events
+		if event.Entry.Version <= version {
+			continue
+		}
+		assert.False(t, seen[event.Entry.Version])
+		seen[event.Entry.Version] = true
+		values[event.Entry.Key] = string(event.Entry.Value)
+	}
+	assert.Equal(t, "3", values["foo"])
+	assert.Equal(t, "1", values["bar"])
+	assert.Len(t, seen, 3)
+
+	partitions3, closers3 := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers3)
+
+	sessions3, err := test.OpenSessions(partitions3)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions3)
+
+	partitioned, err := New(context.TODO(), name, sessions3)
+	assert.NoError(t, err)
+	_, _, err = partitioned.Snapshot(context.TODO())
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotSupported(err))
+}
+
+func TestMapWatchFromVersion(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	entry, err := _map.Put(context.TODO(), "foo", []byte("0"))
+	assert.NoError(t, err)
+
+	version := entry.Version + 2
+	ch := make(chan *Event)
+	err = _map.Watch(context.TODO(), ch, WithFromVersion(version))
+	assert.NoError(t, err)
+
+	expected := make([]*Entry, 0)
+	for i := 1; i <= 5; i++ {
+		entry, err := _map.Put(context.TODO(), "foo", []byte(strconv.Itoa(i)))
+		assert.NoError(t, err)
+		if entry.Version > version {
+			expected = append(expected, entry)
+		}
+	}
+	assert.True(t, len(expected) > 0 && len(expected) < 5)
+
+	for _, entry := range expected {
+		event := <-ch
+		assert.Equal(t, entry.Version, event.Entry.Version)
+		assert.Equal(t, entry.Value, event.Entry.Value)
+	}
+
+	err = _map.Watch(context.TODO(), make(chan *Event), WithFromVersion(entry.Version))
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotSupported(err))
+}
+
+func TestMapGetIfVersionNot(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	for _, opts := range [][]Option{{}, {WithCache(10)}} {
+		name := primitive.NewName("default", "test", "default", "test")
+		_map, err := New(context.TODO(), name, sessions, opts...)
+		assert.NoError(t, err)
+
+		entry, err := _map.Put(context.TODO(), "foo", []byte("bar"))
+		assert.NoError(t, err)
+
+		notModified, err := _map.Get(context.TODO(), "foo", WithIfVersionNot(entry.Version))
+		assert.NoError(t, err)
+		assert.Equal(t, entry.Version, notModified.Version)
+		assert.Nil(t, notModified.Value)
+
+		updated, err := _map.Put(context.TODO(), "foo", []byte("baz"))
+		assert.NoError(t, err)
+
+		modified, err := _map.Get(context.TODO(), "foo", WithIfVersionNot(entry.Version))
+		assert.NoError(t, err)
+		assert.Equal(t, updated.Version, modified.Version)
+		assert.Equal(t, "baz", string(modified.Value))
+
+		current, err := _map.Get(context.TODO(), "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "baz", string(current.Value))
+
+		assert.NoError(t, _map.Delete(context.TODO()))
+	}
+}
+
+func TestMapReturnCurrentOnConflict(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	for _, opts := range [][]Option{{}, {WithCache(10)}} {
+		name := primitive.NewName("default", "test", "default", "test")
+		map1, err := New(context.TODO(), name, sessions1, opts...)
+		assert.NoError(t, err)
+		map2, err := New(context.TODO(), name, sessions2, opts...)
+		assert.NoError(t, err)
+
+		entry, err := map1.Put(context.TODO(), "foo", []byte("bar"))
+		assert.NoError(t, err)
+
+		// A put conflicting with another writer returns the other writer's entry
+		current, err := map2.Put(context.TODO(), "foo", []byte("baz"))
+		assert.NoError(t, err)
+		conflict, err := map1.Put(context.TODO(), "foo", []byte("qux"), IfVersion(entry.Version), WithReturnCurrentOnConflict())
+		assert.True(t, errors.IsConflict(err))
+		assert.NotNil(t, conflict)
+		assert.Equal(t, "baz", string(conflict.Value))
+		assert.Equal(t, current.Version, conflict.Version)
+
+		// The returned entry can be used to retry the put
+		entry, err = map1.Put(context.TODO(), "foo", []byte("qux"), IfVersion(conflict.Version), WithReturnCurrentOnConflict())
+		assert.NoError(t, err)
+		assert.Equal(t, "qux", string(entry.Value))
+
+		conflict, err = map2.Put(context.TODO(), "foo", []byte("baz"), IfNotSet(), WithReturnCurrentOnConflict())
+		assert.True(t, errors.IsAlreadyExists(err))
+		assert.NotNil(t, conflict)
+		assert.Equal(t, "qux", string(conflict.Value))
+		assert.Equal(t, entry.Version, conflict.Version)
+
+		// The current entry is not returned unless the option is passed
+		conflict, err = map2.Put(context.TODO(), "foo", []byte("baz"), IfVersion(current.Version))
+		assert.True(t, errors.IsConflict(err))
+		assert.Nil(t, conflict)
+
+		assert.NoError(t, map1.Delete(context.TODO()))
+	}
+}
+
+func TestMapPartialResults(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers[:2])
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions[:2])
+
+	name := primitive.NewName("default", "test", "default", "test")
+	strict, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	partial, err := New(context.TODO(), name, sessions, WithPartialResults())
+	assert.NoError(t, err)
+
+	available := 0
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		_, err := strict.Put(context.TODO(), key, []byte(key))
+		assert.NoError(t, err)
+		if i, err := util.GetPartitionIndex(key, len(partitions)); err == nil && i != 2 {
+			available++
+		}
+	}
+
+	size, err := partial.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 30, size)
+
+	// Take the last partition offline
+	close(closers[2])
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = strict.Len(ctx)
+	assert.Error(t, err)
+	assert.False(t, errors.IsPartial(err))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	size, err = partial.Len(ctx)
+	assert.True(t, errors.IsPartial(err))
+	assert.Len(t, err.(*errors.PartialError).Errors, 1)
+	assert.Contains(t, err.(*errors.PartialError).Errors, 2)
+	assert.Equal(t, available, size)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch := make(chan *Entry)
+	err = partial.Entries(ctx, ch)
+	assert.True(t, errors.IsPartial(err))
+	entries := 0
+	for entry := range ch {
+		assert.Equal(t, entry.Key, string(entry.Value))
+		entries++
+	}
+	assert.Equal(t, available, entries)
+}
+
+func TestMapMultiCAS(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	foo, err := _map.Put(context.TODO(), "foo", []byte("foo1"))
+	assert.NoError(t, err)
+	bar, err := _map.Put(context.TODO(), "bar", []byte("bar1"))
+	assert.NoError(t, err)
+
+	// A single mismatched condition aborts all updates
+	ok, err := _map.MultiCAS(context.TODO(),
+		map[string]Version{"foo": foo.Version, "bar": bar.Version + 1, "baz": 0},
+		map[string][]byte{"foo": []byte("foo2"), "bar": []byte("bar2"), "baz": []byte("baz2")})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = _map.MultiCAS(context.TODO(),
+		map[string]Version{"foo": foo.Version, "bar": bar.Version, "baz": foo.Version},
+		map[string][]byte{"foo": []byte("foo2")})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	for key, value := range map[string]string{"foo": "foo1", "bar": "bar1"} {
+		entry, err := _map.Get(context.TODO(), key)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(entry.Value))
+	}
+	_, err = _map.Get(context.TODO(), "baz")
+	assert.True(t, errors.IsNotFound(err))
+
+	// All updates are applied if all conditions match
+	ok, err = _map.MultiCAS(context.TODO(),
+		map[string]Version{"foo": foo.Version, "bar": bar.Version, "baz": 0},
+		map[string][]byte{"foo": []byte("foo2"), "bar": []byte("bar2"), "baz": []byte("baz2")})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	for key, value := range map[string]string{"foo": "foo2", "bar": "bar2", "baz": "baz2"} {
+		entry, err := _map.Get(context.TODO(), key)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(entry.Value))
+	}
+
+	// Keys without conditions are updated unconditionally
+	ok, err = _map.MultiCAS(context.TODO(), nil, map[string][]byte{"foo": []byte("foo3")})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	entry, err := _map.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo3", string(entry.Value))
+}
+
+func TestMapAwaitKey(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+
+	entry, err := _map.AwaitKey(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	_, err = _map.AwaitKey(ctx, "baz")
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Write keys concurrently with the calls awaiting them
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+			defer cancel()
+			entry, err := _map.AwaitKey(ctx, key)
+			assert.NoError(t, err)
+			if assert.NotNil(t, entry) {
+				assert.Equal(t, key, string(entry.Value))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := _map.Put(context.TODO(), key, []byte(key))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMapReadOnly(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	view := _map.ReadOnly()
+	assert.Equal(t, name, view.Name())
+	_, ok := view.(Map)
+	assert.False(t, ok)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+
+	entry, err := view.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	size, err := view.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, size)
+
+	ch := make(chan *Entry)
+	err = view.Entries(context.TODO(), ch)
+	assert.NoError(t, err)
+	entry = <-ch
+	assert.Equal(t, "foo", entry.Key)
+	_, ok = <-ch
+	assert.False(t, ok)
+
+	events := make(chan *Event)
+	err = view.Watch(context.TODO(), events)
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "baz", []byte("qux"))
+	assert.NoError(t, err)
+	event := <-events
+	assert.Equal(t, EventInserted, event.Type)
+	assert.Equal(t, "baz", event.Entry.Key)
+
+	// The view of a cached map reads through the cache
+	cached, err := New(context.TODO(), name, sessions, WithCache(10))
+	assert.NoError(t, err)
+	entry, err = cached.ReadOnly().Get(context.TODO(), "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "qux", string(entry.Value))
+}
+
+func TestLengthPrefixedKeyEncoder(t *testing.T) {
+	encoder := NewLengthPrefixedKeyEncoder()
+	keys := [][]string{
+		{"a", "bc"},
+		{"ab", "c"},
+		{"abc"},
+		{"a", "b", "c"},
+		{"1:a", ""},
+		{"", "1:a"},
+		{"a:b", "c"},
+		{},
+	}
+	encoded := make(map[string]bool)
+	for _, key := range keys {
+		k := encoder.Encode(key)
+		assert.False(t, encoded[k], k)
+		encoded[k] = true
+
+		fields, err := encoder.Decode(k)
+		assert.NoError(t, err)
+		assert.Equal(t, key, fields)
+	}
+
+	_, err := encoder.Decode("a")
+	assert.True(t, errors.IsInvalid(err))
+	_, err = encoder.Decode("3:ab")
+	assert.True(t, errors.IsInvalid(err))
+}
+
+func TestStructuredMap(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	structured := NewStructuredMap(_map, nil)
+
+	// Ambiguous concatenations are stored under distinct keys
+	_, err = structured.Put(context.TODO(), []string{"a", "bc"}, []byte("foo"))
+	assert.NoError(t, err)
+	entry, err := structured.Put(context.TODO(), []string{"ab", "c"}, []byte("bar"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ab", "c"}, entry.Fields)
+
+	size, err := structured.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+
+	entry, err = structured.Get(context.TODO(), []string{"a", "bc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(entry.Value))
+	entry, err = structured.Get(context.TODO(), []string{"ab", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	// Keys that cannot be decoded are skipped
+	_, err = _map.Put(context.TODO(), "abc", []byte("baz"))
+	assert.NoError(t, err)
+
+	ch := make(chan *StructuredEntry)
+	err = structured.Entries(context.TODO(), ch)
+	assert.NoError(t, err)
+	values := make(map[string]string)
+	for entry := range ch {
+		values[strings.Join(entry.Fields, "/")] = string(entry.Value)
+	}
+	assert.Equal(t, map[string]string{"a/bc": "foo", "ab/c": "bar"}, values)
+
+	entry, err = structured.Remove(context.TODO(), []string{"a", "bc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(entry.Value))
+	entry, err = structured.Get(context.TODO(), []string{"ab", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+}
+
+func TestAtomicMap(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	atomicMap := NewAtomicMap(_map)
+
+	entry, ok, err := atomicMap.PutIfAbsent(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	entry, ok, err = atomicMap.PutIfAbsent(context.TODO(), "foo", []byte("baz"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	ok, err = atomicMap.Replace(context.TODO(), "foo", []byte("baz"), []byte("qux"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = atomicMap.Replace(context.TODO(), "foo", []byte("bar"), []byte("qux"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = atomicMap.Replace(context.TODO(), "none", nil, []byte("qux"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = atomicMap.CompareAndRemove(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = atomicMap.CompareAndRemove(context.TODO(), "foo", []byte("qux"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = atomicMap.Get(context.TODO(), "foo")
+	assert.True(t, errors.IsNotFound(err))
+
+	failure := errors.NewInvalid("failure")
+	_, err = atomicMap.Update(context.TODO(), "count", func(value []byte) ([]byte, error) {
+		return nil, failure
+	})
+	assert.Equal(t, failure, err)
+
+	// Concurrent updates from cached maps in different sessions are all applied
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+	cached1, err := New(context.TODO(), name, sessions, WithCache(10))
+	assert.NoError(t, err)
+	cached2, err := New(context.TODO(), name, sessions2, WithCache(10))
+	assert.NoError(t, err)
+	maps := []AtomicMap{NewAtomicMap(cached1), NewAtomicMap(cached2)}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(m AtomicMap) {
+			defer wg.Done()
+			_, err := m.Update(context.TODO(), "count", func(value []byte) ([]byte, error) {
+				count := 0
+				if value != nil {
+					count, _ = strconv.Atoi(string(value))
+				}
+				return []byte(strconv.Itoa(count + 1)), nil
+			})
+			assert.NoError(t, err)
+		}(maps[i%2])
+	}
+	wg.Wait()
+
+	entry, err = _map.Get(context.TODO(), "count")
+	assert.NoError(t, err)
+	assert.Equal(t, "10", string(entry.Value))
+}
+
+func TestAtomicMapCompute(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	map1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	map2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+	maps := []AtomicMap{NewAtomicMap(map1), NewAtomicMap(map2)}
+
+	// An error from the function aborts the computation
+	failure := errors.NewInvalid("failure")
+	_, err = maps[0].ComputeIfAbsent(context.TODO(), "foo", func() ([]byte, error) {
+		return nil, failure
+	})
+	assert.Equal(t, failure, err)
+	_, err = maps[0].Get(context.TODO(), "foo")
+	assert.True(t, errors.IsNotFound(err))
+
+	// A key that's not present is not computed by ComputeIfPresent
+	entry, err := maps[0].ComputeIfPresent(context.TODO(), "foo", func(value []byte) ([]byte, error) {
+		t.Fail()
+		return value, nil
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+
+	// Concurrent computations of an absent key all return the value that was set
+	const n = 10
+	entries := make([]*Entry, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry, err := maps[i%2].ComputeIfAbsent(context.TODO(), "foo", func() ([]byte, error) {
+				return []byte(strconv.Itoa(i)), nil
+			})
+			assert.NoError(t, err)
+			entries[i] = entry
+		}(i)
+	}
+	wg.Wait()
+
+	entry, err = maps[0].Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	for _, computed := range entries {
+		assert.Equal(t, string(entry.Value), string(computed.Value))
+		assert.Equal(t, entry.Version, computed.Version)
+	}
+
+	// Concurrent computations of a present key are all applied
+	_, err = maps[0].Put(context.TODO(), "count", []byte("0"))
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(m AtomicMap) {
+			defer wg.Done()
+			_, err := m.ComputeIfPresent(context.TODO(), "count", func(value []byte) ([]byte, error) {
+				count, err := strconv.Atoi(string(value))
+				if err != nil {
+					return nil, err
+				}
+				return []byte(strconv.Itoa(count + 1)), nil
+			})
+			assert.NoError(t, err)
+		}(maps[i%2])
+	}
+	wg.Wait()
+
+	entry, err = maps[0].Get(context.TODO(), "count")
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(n), string(entry.Value))
+
+	_, err = maps[1].ComputeIfPresent(context.TODO(), "count", func(value []byte) ([]byte, error) {
+		return nil, failure
+	})
+	assert.Equal(t, failure, err)
+	entry, err = maps[0].Get(context.TODO(), "count")
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(n), string(entry.Value))
+}
+
+func TestAtomicMapMaxAttempts(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	atomicMap := NewAtomicMap(_map, WithMaxAttempts(3))
+
+	_, err = _map.Put(context.TODO(), "hot", []byte("0"))
+	assert.NoError(t, err)
+
+	// The hot key is modified by another writer between each read and conditional write
+	attempts := 0
+	_, err = atomicMap.Update(context.TODO(), "hot", func(value []byte) ([]byte, error) {
+		attempts++
+		_, err := _map.Put(context.TODO(), "hot", []byte(strconv.Itoa(attempts)))
+		assert.NoError(t, err)
+		return []byte("update"), nil
+	})
+	assert.Equal(t, ErrTooManyConflicts, err)
+	assert.True(t, errors.IsConflict(err))
+	assert.Equal(t, 3, attempts)
+
+	entry, err := _map.Get(context.TODO(), "hot")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", string(entry.Value))
+
+	// Updates that succeed within the budget are applied
+	attempts = 0
+	entry, err = atomicMap.Update(context.TODO(), "hot", func(value []byte) ([]byte, error) {
+		attempts++
+		if attempts < 3 {
+			_, err := _map.Put(context.TODO(), "hot", []byte(strconv.Itoa(attempts)))
+			assert.NoError(t, err)
+		}
+		return []byte("update"), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "update", string(entry.Value))
+	assert.Equal(t, 3, attempts)
+}
+
+func TestAtomicMapRename(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	atomicMap := NewAtomicMap(_map)
+
+	// A key that is not present is not renamed
+	renamed, err := atomicMap.Rename(context.TODO(), "foo", "bar")
+	assert.NoError(t, err)
+	assert.False(t, renamed)
+
+	_, err = atomicMap.Put(context.TODO(), "foo", []byte("foo"))
+	assert.NoError(t, err)
+	renamed, err = atomicMap.Rename(context.TODO(), "foo", "bar")
+	assert.NoError(t, err)
+	assert.True(t, renamed)
+	_, err = atomicMap.Get(context.TODO(), "foo")
+	assert.True(t, errors.IsNotFound(err))
+	entry, err := atomicMap.Get(context.TODO(), "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(entry.Value))
+
+	// A key is not renamed to a key that is present if IfNewKeyAbsent is passed
+	_, err = atomicMap.Put(context.TODO(), "baz", []byte("baz"))
+	assert.NoError(t, err)
+	renamed, err = atomicMap.Rename(context.TODO(), "baz", "bar", IfNewKeyAbsent())
+	assert.NoError(t, err)
+	assert.False(t, renamed)
+	entry, err = atomicMap.Get(context.TODO(), "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(entry.Value))
+	entry, err = atomicMap.Get(context.TODO(), "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(entry.Value))
+
+	// Otherwise the new key is overwritten
+	renamed, err = atomicMap.Rename(context.TODO(), "baz", "bar")
+	assert.NoError(t, err)
+	assert.True(t, renamed)
+	entry, err = atomicMap.Get(context.TODO(), "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(entry.Value))
+
+	_, err = atomicMap.Rename(context.TODO(), "bar", "bar")
+	assert.True(t, errors.IsInvalid(err))
+
+	// Concurrent renames of a key from different sessions rename the key exactly once
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+	_map2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+	maps := []AtomicMap{atomicMap, NewAtomicMap(_map2)}
+
+	var count int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(m AtomicMap, key string) {
+			defer wg.Done()
+			renamed, err := m.Rename(context.TODO(), "bar", key)
+			assert.NoError(t, err)
+			if renamed {
+				atomic.AddInt32(&count, 1)
+			}
+		}(maps[i%2], fmt.Sprintf("bar-%d", i))
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), count)
+
+	_, err = atomicMap.Get(context.TODO(), "bar")
+	assert.True(t, errors.IsNotFound(err))
+	found := 0
+	for i := 0; i < 10; i++ {
+		entry, err := atomicMap.Get(context.TODO(), fmt.Sprintf("bar-%d", i))
+		if err == nil {
+			assert.Equal(t, "baz", string(entry.Value))
+			found++
+		} else {
+			assert.True(t, errors.IsNotFound(err))
+		}
+	}
+	assert.Equal(t, 1, found)
+}
+
+func TestAtomicMapAppendToValue(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	map1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	map2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+	maps := []AtomicMap{NewAtomicMap(map1), NewAtomicMap(map2)}
+
+	// A key that is not present is set to a list containing only the element
+	err = maps[0].AppendToValue(context.TODO(), "foo", []byte(`"bar"`), nil)
+	assert.NoError(t, err)
+	entry, err := maps[0].Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, `["bar"]`, string(entry.Value))
+
+	// A value that cannot be decoded is not updated
+	_, err = maps[0].Put(context.TODO(), "baz", []byte("baz"))
+	assert.NoError(t, err)
+	err = maps[0].AppendToValue(context.TODO(), "baz", []byte(`"bar"`), NewJSONListCodec())
+	assert.True(t, errors.IsInvalid(err))
+	entry, err = maps[0].Get(context.TODO(), "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(entry.Value))
+
+	// Concurrent appends do not lose elements
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(m AtomicMap, i int) {
+			defer wg.Done()
+			assert.NoError(t, m.AppendToValue(context.TODO(), "bar", []byte(strconv.Itoa(i)), nil))
+		}(maps[i%2], i)
+	}
+	wg.Wait()
+
+	entry, err = maps[0].Get(context.TODO(), "bar")
+	assert.NoError(t, err)
+	elements, err := NewJSONListCodec().Decode(entry.Value)
+	assert.NoError(t, err)
+	assert.Len(t, elements, 20)
+	appended := make(map[string]bool)
+	for _, element := range elements {
+		appended[string(element)] = true
+	}
+	for i := 0; i < 20; i++ {
+		assert.True(t, appended[strconv.Itoa(i)])
+	}
+}
+
+func TestAtomicMapMerge(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions1, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions1)
+
+	sessions2, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions2)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	map1, err := New(context.TODO(), name, sessions1)
+	assert.NoError(t, err)
+	map2, err := New(context.TODO(), name, sessions2)
+	assert.NoError(t, err)
+	maps := []AtomicMap{NewAtomicMap(map1), NewAtomicMap(map2)}
+
+	// Concurrent merges are all applied
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(m AtomicMap, i int) {
+			defer wg.Done()
+			_, err := m.Merge(context.TODO(), "sum", []byte(strconv.Itoa(i)), MergeInt64Sum)
+			assert.NoError(t, err)
+			_, err = m.Merge(context.TODO(), "doc", []byte(fmt.Sprintf(`{"field-%d":%d}`, i, i)), MergeJSONObjects)
+			assert.NoError(t, err)
+		}(maps[i%2], i)
+	}
+	wg.Wait()
+
+	entry, err := maps[0].Get(context.TODO(), "sum")
+	assert.NoError(t, err)
+	assert.Equal(t, "190", string(entry.Value))
+
+	entry, err = maps[1].Get(context.TODO(), "doc")
+	assert.NoError(t, err)
+	fields := make(map[string]int)
+	assert.NoError(t, json.Unmarshal(entry.Value, &fields))
+	assert.Len(t, fields, 20)
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, i, fields[fmt.Sprintf("field-%d", i)])
+	}
+
+	// Fields of the delta replace the fields of the value
+	entry, err = maps[0].Merge(context.TODO(), "doc", []byte(`{"field-0":"zero"}`), MergeJSONObjects)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(entry.Value, &map[string]interface{}{}))
+	assert.Contains(t, string(entry.Value), `"field-0":"zero"`)
+	assert.Contains(t, string(entry.Value), `"field-1":1`)
+
+	// A value that cannot be merged is not updated
+	_, err = maps[0].Merge(context.TODO(), "sum", []byte("foo"), MergeInt64Sum)
+	assert.True(t, errors.IsInvalid(err))
+	_, err = maps[0].Merge(context.TODO(), "sum", []byte(`{"foo":1}`), MergeJSONObjects)
+	assert.True(t, errors.IsInvalid(err))
+	entry, err = maps[0].Get(context.TODO(), "sum")
+	assert.NoError(t, err)
+	assert.Equal(t, "190", string(entry.Value))
+}
+
+func TestMapDiff(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	for _, key := range []string{"foo", "bar", "baz"} {
+		_, err = _map.Put(context.TODO(), key, []byte(key))
+		assert.NoError(t, err)
+	}
+
+	version, entries, err := _map.Snapshot(context.TODO())
+	assert.NoError(t, err)
+	for range entries {
+	}
+
+	err = _map.Diff(context.TODO(), version+1, make(chan *Event))
+	assert.True(t, errors.IsNotSupported(err))
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("foo1"))
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "foo", []byte("foo2"))
+	assert.NoError(t, err)
+	_, err = _map.Remove(context.TODO(), "bar")
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "qux", []byte("qux"))
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "quux", []byte("quux"))
+	assert.NoError(t, err)
+	_, err = _map.Remove(context.TODO(), "quux")
+	assert.NoError(t, err)
+
+	ch := make(chan *Event)
+	err = _map.Diff(context.TODO(), version, ch)
+	assert.NoError(t, err)
+	events := make(map[string]*Event)
+	for event := range ch {
+		assert.Nil(t, events[event.Entry.Key])
+		events[event.Entry.Key] = event
+	}
+	assert.Len(t, events, 3)
+	assert.Equal(t, EventUpdated, events["foo"].Type)
+	assert.Equal(t, "foo2", string(events["foo"].Entry.Value))
+	assert.Equal(t, EventRemoved, events["bar"].Type)
+	assert.Equal(t, EventInserted, events["qux"].Type)
+	assert.Equal(t, "qux", string(events["qux"].Entry.Value))
+}
+
+func TestMapEntriesCancel(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	const count = 500
+	for i := 0; i < count; i++ {
+		_, err = _map.Put(context.TODO(), strconv.Itoa(i), []byte(strconv.Itoa(i)))
+		assert.NoError(t, err)
+	}
+
+	// Stop reading the entries and cancel the iteration part of the way through
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan *Entry)
+	assert.NoError(t, _map.Entries(ctx, ch))
+	for i := 0; i < 10; i++ {
+		<-ch
+	}
+	cancel()
+
+	// The iteration goroutines exit and close the channel without the remaining entries being read
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+	remaining := 0
+	for range ch {
+		remaining++
+	}
+	assert.Equal(t, 0, remaining)
+}
+
+func TestMapRemovalEvents(t *testing.T) {
+	assert.True(t, EventRemoved.IsRemoval())
+	assert.True(t, EventExpired.IsRemoval())
+	assert.False(t, EventInserted.IsRemoval())
+	assert.False(t, EventUpdated.IsRemoval())
+	assert.False(t, EventNone.IsRemoval())
+
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions, WithCache(10))
+	assert.NoError(t, err)
+
+	ch := make(chan *Event)
+	assert.NoError(t, _map.Watch(context.TODO(), ch))
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+	event := <-ch
+	assert.Equal(t, EventInserted, event.Type)
+
+	// An explicit removal is published as EventRemoved and the key is eventually removed from the cache
+	_, err = _map.Remove(context.TODO(), "foo")
+	assert.NoError(t, err)
+	event = <-ch
+	assert.Equal(t, EventRemoved, event.Type)
+	assert.Equal(t, "foo", event.Entry.Key)
+	deadline := time.Now().Add(time.Second)
+	_, err = _map.Get(context.TODO(), "foo")
+	for !errors.IsNotFound(err) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		_, err = _map.Get(context.TODO(), "foo")
+	}
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestMapStreamBuffer(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions, WithStreamBuffer(10))
+	assert.NoError(t, err)
+
+	events := make(chan *Event)
+	err = _map.Watch(context.TODO(), events)
+	assert.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = _map.Put(context.TODO(), fmt.Sprintf("key-%d", i), []byte("value"))
+		assert.NoError(t, err)
+	}
+
+	for i := 0; i < 100; i++ {
+		select {
+		case event := <-events:
+			assert.Equal(t, EventInserted, event.Type)
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing event")
+		}
+	}
+
+	ch := make(chan *Entry)
+	err = _map.Entries(context.TODO(), ch)
+	assert.NoError(t, err)
+	keys := make(map[string]bool)
+	for entry := range ch {
+		keys[entry.Key] = true
+	}
+	assert.Len(t, keys, 100)
+}
+
+// BenchmarkMapEntries compares listing entries with and without a stream buffer with a consumer that pauses
+// periodically
+// Each op lists all the entries in the map.
+func BenchmarkMapEntries(b *testing.B) {
+	const entries = 1000
+
+	benchmark := func(b *testing.B, opts ...Option) {
+		partitions, closers := test.StartTestPartitions(3)
+		defer test.StopTestPartitions(closers)
+
+		sessions, err := test.OpenSessions(partitions)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer test.CloseSessions(sessions)
+
+		name := primitive.NewName("default", "test", "default", "test")
+		_map, err := New(context.TODO(), name, sessions, opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < entries; i++ {
+			if _, err := _map.Put(context.TODO(), fmt.Sprintf("key-%d", i), []byte("value")); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ch := make(chan *Entry)
+			if err := _map.Entries(context.TODO(), ch); err != nil {
+				b.Fatal(err)
+			}
+			n := 0
+			for range ch {
+				n++
+				if n%100 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}
+		b.StopTimer()
+	}
+
+	b.Run("Unbuffered", func(b *testing.B) {
+		benchmark(b)
+	})
+	b.Run("Buffered", func(b *testing.B) {
+		benchmark(b, WithStreamBuffer(100))
+	})
+}
+
+func TestMapRemoveAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = _map.Put(context.TODO(), fmt.Sprintf("key-%d", i), []byte("value"))
+		assert.NoError(t, err)
+	}
+
+	keys := []string{"key-0", "missing-0", "key-1", "key-2", "missing-1", "missing-2", "key-3", "key-0"}
+	removed, err := _map.RemoveAll(context.TODO(), keys)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, removed)
+
+	size, err := _map.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 6, size)
+
+	_, err = _map.Get(context.TODO(), "key-1")
+	assert.True(t, errors.IsNotFound(err))
+	entry, err := _map.Get(context.TODO(), "key-4")
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+
+	removed, err = _map.RemoveAll(context.TODO(), []string{"missing-0", "missing-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	cached, err := New(context.TODO(), name, sessions, WithCache(10))
+	assert.NoError(t, err)
+	entry, err = cached.Get(context.TODO(), "key-4")
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+	removed, err = cached.RemoveAll(context.TODO(), []string{"key-4", "key-5", "missing-0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	_, err = cached.Get(context.TODO(), "key-4")
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestMapEntriesInVersionRange(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	versions := make(map[string]Version)
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		entry, err := _map.Put(context.TODO(), key, []byte("value"))
+		assert.NoError(t, err)
+		versions[key] = entry.Version
+	}
+
+	_, err = _map.Remove(context.TODO(), "key-2")
+	assert.NoError(t, err)
+
+	listKeys := func(min, max Version) []string {
+		ch := make(chan *Entry)
+		err := _map.EntriesInVersionRange(context.TODO(), min, max, ch)
+		assert.NoError(t, err)
+		keys := make([]string, 0)
+		for entry := range ch {
+			assert.True(t, entry.Version >= min && entry.Version <= max)
+			keys = append(keys, entry.Key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	assert.Equal(t, []string{"key-1", "key-3"}, listKeys(versions["key-1"], versions["key-3"]))
+	assert.Equal(t, []string{"key-1"}, listKeys(versions["key-1"], versions["key-1"]))
+	assert.Equal(t, []string{"key-0", "key-1"}, listKeys(0, versions["key-2"]))
+	assert.Equal(t, []string{"key-3", "key-4"}, listKeys(versions["key-3"]-1, versions["key-4"]+1))
+	assert.Equal(t, []string{}, listKeys(versions["key-4"]+1, versions["key-4"]+10))
+
+	err = _map.EntriesInVersionRange(context.TODO(), versions["key-3"], versions["key-1"], make(chan *Entry))
+	assert.True(t, errors.IsInvalid(err))
+
+	ch := make(chan *Entry)
+	err = _map.ReadOnly().EntriesInVersionRange(context.TODO(), versions["key-4"], versions["key-4"], ch)
+	assert.NoError(t, err)
+	entry := <-ch
+	assert.Equal(t, "key-4", entry.Key)
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestMapWatchCoalesce(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan *Event)
+	err = _map.Watch(ctx, events, WithCoalesce(500*time.Millisecond))
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = _map.Put(context.TODO(), "foo", []byte(fmt.Sprintf("foo-%d", i)))
+		assert.NoError(t, err)
+	}
+	_, err = _map.Put(context.TODO(), "bar", []byte("bar"))
+	assert.NoError(t, err)
+	_, err = _map.Remove(context.TODO(), "bar")
+	assert.NoError(t, err)
+
+	event := <-events
+	assert.Equal(t, EventUpdated, event.Type)
+	assert.Equal(t, "foo", event.Entry.Key)
+	assert.Equal(t, "foo-9", string(event.Entry.Value))
+	event = <-events
+	assert.Equal(t, EventRemoved, event.Type)
+	assert.Equal(t, "bar", event.Entry.Key)
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(time.Second):
+	}
+
+	// Events pending when the watch ends are delivered
+	_, err = _map.Put(context.TODO(), "baz", []byte("baz"))
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	event = <-events
+	assert.Equal(t, EventInserted, event.Type)
+	assert.Equal(t, "baz", event.Entry.Key)
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestMapWatchIncludePrevValue(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("foo"))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan *Event)
+	err = _map.Watch(ctx, events, WithIncludePrevValue())
+	assert.NoError(t, err)
+
+	// Removal events carry the value that was present before the key was removed
+	_, err = _map.Remove(context.TODO(), "foo")
+	assert.NoError(t, err)
+	event := <-events
+	assert.Equal(t, EventRemoved, event.Type)
+	assert.Equal(t, "foo", event.Entry.Key)
+	assert.Equal(t, "foo", string(event.Entry.Value))
+
+	_, err = _map.Put(context.TODO(), "bar", []byte("bar-1"))
+	assert.NoError(t, err)
+	_, err = _map.Put(context.TODO(), "bar", []byte("bar-2"))
+	assert.NoError(t, err)
+	_, err = _map.Remove(context.TODO(), "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, EventInserted, (<-events).Type)
+	assert.Equal(t, EventUpdated, (<-events).Type)
+	event = <-events
+	assert.Equal(t, EventRemoved, event.Type)
+	assert.Equal(t, "bar-2", string(event.Entry.Value))
+}
+
+func TestMapPrevValues(t *testing.T) {
+	values := newPrevValues()
+	ch := make(chan *Event)
+	events := values.fill(ch)
+	send := func(event *Event) *Event {
+		go func() {
+			events <- event
+		}()
+		return <-ch
+	}
+
+	// The value of a removed key is filled in from the entries if the key has not been modified since
+	values.seed(&Entry{Key: "foo", Value: []byte("foo"), Version: 1})
+	event := send(&Event{Type: EventRemoved, Entry: &Entry{Key: "foo", Version: 5}})
+	assert.Equal(t, "foo", string(event.Entry.Value))
+
+	// An entry read before the key was removed does not restore the key
+	values.seed(&Entry{Key: "foo", Value: []byte("foo"), Version: 1})
+	send(&Event{Type: EventInserted, Entry: &Entry{Key: "foo", Value: []byte("bar"), Version: 6}})
+	event = send(&Event{Type: EventRemoved, Entry: &Entry{Key: "foo", Version: 7}})
+	assert.Equal(t, "bar", string(event.Entry.Value))
+
+	// An entry read after the key was updated does not replace the later value
+	send(&Event{Type: EventInserted, Entry: &Entry{Key: "bar", Value: []byte("bar-2"), Version: 9}})
+	values.seed(&Entry{Key: "bar", Value: []byte("bar-1"), Version: 8})
+	values.seeded()
+	event = send(&Event{Type: EventRemoved, Entry: &Entry{Key: "bar", Version: 10}})
+	assert.Equal(t, "bar-2", string(event.Entry.Value))
+
+	// Once seeding has completed, removed keys are not remembered
+	event = send(&Event{Type: EventRemoved, Entry: &Entry{Key: "bar", Version: 11}})
+	assert.Empty(t, event.Entry.Value)
+	assert.Len(t, values.entries, 0)
+
+	// Expired keys are filled in and forgotten like removed keys
+	send(&Event{Type: EventInserted, Entry: &Entry{Key: "baz", Value: []byte("baz"), Version: 12}})
+	event = send(&Event{Type: EventExpired, Entry: &Entry{Key: "baz", Version: 13}})
+	assert.Equal(t, "baz", string(event.Entry.Value))
+	assert.Len(t, values.entries, 0)
+	close(events)
+}
+
+// invertedIndex is an IndexHandler that indexes the keys of a map by value
+type invertedIndex struct {
+	keys     map[string]map[string]bool
+	rebuilds int
+	mu       sync.Mutex
+}
+
+func (i *invertedIndex) OnRebuild() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = make(map[string]map[string]bool)
+	i.rebuilds++
+}
+
+func (i *invertedIndex) OnPut(key string, value []byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, keys := range i.keys {
+		delete(keys, key)
+	}
+	if i.keys[string(value)] == nil {
+		i.keys[string(value)] = make(map[string]bool)
+	}
+	i.keys[string(value)][key] = true
+}
+
+func (i *invertedIndex) OnRemove(key string, oldValue []byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.keys[string(oldValue)], key)
+}
+
+func (i *invertedIndex) get() map[string][]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	index := make(map[string][]string)
+	for value, keys := range i.keys {
+		for key := range keys {
+			index[value] = append(index[value], key)
+		}
+		sort.Strings(index[value])
+	}
+	return index
+}
+
+func TestMapBuildIndex(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		_, err = _map.Put(context.TODO(), fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d", i%3)))
+		assert.NoError(t, err)
+	}
+
+	// Mutate the map while the index is built
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := fmt.Sprintf("key-%d", (i*7+w)%30)
+				if i%4 == 3 {
+					_, err := _map.Remove(context.TODO(), key)
+					assert.True(t, err == nil || errors.IsNotFound(err))
+				} else {
+					_, err := _map.Put(context.TODO(), key, []byte(fmt.Sprintf("value-%d", (i+w)%3)))
+					assert.NoError(t, err)
+				}
+			}
+		}(w)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	index := &invertedIndex{}
+	assert.NoError(t, BuildIndex(ctx, _map, index))
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	// Once the mutations have been applied, the index reflects the entries of the map
+	expected := make(map[string][]string)
+	entries := make(chan *Entry)
+	assert.NoError(t, _map.Entries(context.TODO(), entries))
+	for entry := range entries {
+		expected[string(entry.Value)] = append(expected[string(entry.Value)], entry.Key)
+	}
+	for value := range expected {
+		sort.Strings(expected[value])
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	actual := index.get()
+	for !assert.ObjectsAreEqual(expected, withoutEmpty(actual)) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		actual = index.get()
+	}
+	assert.Equal(t, expected, withoutEmpty(actual))
+	assert.Equal(t, 1, index.rebuilds)
+}
+
+// withoutEmpty returns the given index without the values that index no keys
+func withoutEmpty(index map[string][]string) map[string][]string {
+	result := make(map[string][]string)
+	for value, keys := range index {
+		if len(keys) > 0 {
+			result[value] = keys
+		}
+	}
+	return result
+}
+
+func TestMapLoader(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	writer, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	var loads int32
+	release := make(chan struct{})
+	_map, err := New(context.TODO(), name, sessions, WithCache(10), WithLoader(func(ctx context.Context, key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		switch key {
+		case "foo":
+			<-release
+			return []byte("bar"), nil
+		case "baz":
+			// The key is set by another client while its value is being loaded
+			_, err := writer.Put(context.TODO(), key, []byte("written"))
+			assert.NoError(t, err)
+			return []byte("loaded"), nil
+		}
+		return nil, nil
+	}))
+	assert.NoError(t, err)
+
+	// Concurrent misses for the same key are loaded once
+	wg := sync.WaitGroup{}
+	entries := make([]*Entry, 10)
+	for i := 0; i < len(entries); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry, err := _map.Get(context.TODO(), "foo")
+			assert.NoError(t, err)
+			entries[i] = entry
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	for _, entry := range entries {
+		assert.NotNil(t, entry)
+		assert.Equal(t, "bar", string(entry.Value))
+	}
+
+	// The loaded value is stored in the map
+	entry, err := writer.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+	entry, err = _map.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// A value written concurrently is not clobbered by the loaded value
+	entry, err = _map.Get(context.TODO(), "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "written", string(entry.Value))
+	entry, err = writer.Get(context.TODO(), "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "written", string(entry.Value))
+
+	// Keys the loader cannot load remain absent
+	_, err = _map.Get(context.TODO(), "qux")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = writer.Get(context.TODO(), "qux")
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestMapErrorOnNotFound(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	// The map service fails with a NotFound error for absent keys in both modes
+	_, err = _map.Get(context.TODO(), "foo")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = _map.Get(context.TODO(), "foo", WithErrorOnNotFound())
+	assert.True(t, errors.IsNotFound(err))
+
+	_, err = _map.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+	entry, err := _map.Get(context.TODO(), "foo", WithErrorOnNotFound())
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	// Services that return an empty entry for absent keys fail only with the option
+	address, stop := startEmptyEntryServer(t)
+	defer stop()
+	session, err := primitive.NewSession(context.TODO(), primitive.Partition{ID: 1, Address: netutil.Address(address)})
+	assert.NoError(t, err)
+	defer session.Close()
+	empty, err := New(context.TODO(), name, []*primitive.Session{session})
+	assert.NoError(t, err)
+
+	entry, err = empty.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+	_, err = empty.Get(context.TODO(), "foo", WithErrorOnNotFound())
+	assert.True(t, errors.IsNotFound(err))
+	_, err = empty.Get(context.TODO(), "foo", WithDefault([]byte("bar")), WithErrorOnNotFound())
+	assert.True(t, errors.IsNotFound(err))
+}
+
+// startEmptyEntryServer starts a partition whose map service returns an empty entry for every key and returns
+// its address and a function to stop the partition
+func startEmptyEntryServer(t *testing.T) (string, func()) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	s := grpc.NewServer()
+	server := &emptyEntryServer{}
+	sessionapi.RegisterSessionServiceServer(s, server)
+	api.RegisterMapServiceServer(s, server)
+	go s.Serve(lis)
+	return lis.Addr().String(), s.Stop
+}
+
+// emptyEntryServer is a partition whose map service represents absent keys as entries with the zero version
+type emptyEntryServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+	api.UnimplementedMapServiceServer
+}
+
+func (s *emptyEntryServer) header() *headers.ResponseHeader {
+	return &headers.ResponseHeader{
+		SessionID: 1,
+		Index:     1,
+	}
+}
+
+func (s *emptyEntryServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	return &sessionapi.OpenSessionResponse{Header: s.header()}, nil
+}
+
+func (s *emptyEntryServer) KeepAlive(ctx context.Context, request *sessionapi.KeepAliveRequest) (*sessionapi.KeepAliveResponse, error) {
+	return &sessionapi.KeepAliveResponse{Header: s.header()}, nil
+}
+
+func (s *emptyEntryServer) CloseSession(ctx context.Context, request *sessionapi.CloseSessionRequest) (*sessionapi.CloseSessionResponse, error) {
+	return &sessionapi.CloseSessionResponse{Header: s.header()}, nil
+}
+
+func (s *emptyEntryServer) Create(ctx context.Context, request *api.CreateRequest) (*api.CreateResponse, error) {
+	return &api.CreateResponse{Header: s.header()}, nil
+}
+
+func (s *emptyEntryServer) Close(ctx context.Context, request *api.CloseRequest) (*api.CloseResponse, error) {
+	return &api.CloseResponse{Header: s.header()}, nil
+}
+
+func (s *emptyEntryServer) Get(ctx context.Context, request *api.GetRequest) (*api.GetResponse, error) {
+	return &api.GetResponse{Header: s.header()}, nil
+}
+
+func TestMapPartitionConsistency(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	writer, err := primitive.NewSession(context.TODO(), partitions[0])
+	assert.NoError(t, err)
+	defer writer.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	writes, err := newPartition(context.TODO(), name, writer)
+	assert.NoError(t, err)
+	_, err = writes.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+
+	// The reader's session has observed an index the partition has not reached yet
+	reader, err := primitive.NewSession(context.TODO(), partitions[0], primitive.WithInitialIndex(writer.LastIndex()+100))
+	assert.NoError(t, err)
+	defer reader.Close()
+	reads, err := newPartition(context.TODO(), name, reader)
+	assert.NoError(t, err)
+
+	// A sequential read waits for the partition to reach the session's index
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = reads.Get(ctx, "foo")
+	assert.True(t, errors.IsTimeout(err))
+
+	// An eventual read does not wait for the session's index
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	entry, err := reads.Get(ctx, "foo", WithConsistency(Eventual))
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+
+	// A read with bounded staleness does not wait for the session's index either
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	entry, err = reads.Get(ctx, "foo", WithConsistency(BoundedStaleness(10)))
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(entry.Value))
+}
@@ -0,0 +1,72 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"encoding/json"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"math"
+	"strconv"
+)
+
+// Merger merges a delta into the value of a key
+// The merger is called with a nil value if the key is not present in the map. Mergers may be called more than
+// once for the same delta if the key is concurrently modified, so they must not have side effects.
+type Merger func(value []byte, delta []byte) ([]byte, error)
+
+// MergeJSONObjects is a Merger that shallow merges JSON objects
+// The value and the delta must be JSON objects. The fields of the delta are set in the value, replacing the
+// fields of the value with the same names, and the other fields of the value are retained.
+func MergeJSONObjects(value []byte, delta []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, &fields); err != nil || fields == nil {
+			return nil, errors.NewInvalid("value is not a JSON object")
+		}
+	} else {
+		fields = make(map[string]json.RawMessage)
+	}
+
+	var deltaFields map[string]json.RawMessage
+	if err := json.Unmarshal(delta, &deltaFields); err != nil || deltaFields == nil {
+		return nil, errors.NewInvalid("delta is not a JSON object")
+	}
+	for name, field := range deltaFields {
+		fields[name] = field
+	}
+	return json.Marshal(fields)
+}
+
+// MergeInt64Sum is a Merger that adds integers
+// The value and the delta must be decimal integers, e.g. "42", and the value is set to their sum. If the key is
+// not present, the value is set to the delta.
+func MergeInt64Sum(value []byte, delta []byte) ([]byte, error) {
+	var sum int64
+	if len(value) > 0 {
+		i, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return nil, errors.NewInvalid("value is not an integer")
+		}
+		sum = i
+	}
+	i, err := strconv.ParseInt(string(delta), 10, 64)
+	if err != nil {
+		return nil, errors.NewInvalid("delta is not an integer")
+	}
+	if (i > 0 && sum > math.MaxInt64-i) || (i < 0 && sum < math.MinInt64-i) {
+		return nil, errors.NewInvalid("sum overflows int64")
+	}
+	return []byte(strconv.FormatInt(sum+i, 10)), nil
+}
@@ -15,7 +15,10 @@
 package _map //nolint:golint
 
 import (
+	"context"
 	api "github.com/atomix/api/proto/atomix/map"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"time"
 )
 
 // Option is an option for a Map instance
@@ -25,8 +28,15 @@ type Option interface {
 
 // options is a set of map options
 type options struct {
-	cached    bool
-	cacheSize int
+	cached        bool
+	cacheSize     int
+	codec         primitive.Codec
+	maxValueSize  int
+	warnValueSize int
+	warnFunc      func(key string, size int)
+	partial       bool
+	streamBuffer  int
+	loader        func(ctx context.Context, key string) ([]byte, error)
 }
 
 // WithCache returns an option that enables caching for a Map
@@ -51,6 +61,134 @@ func (o *cacheOption) apply(options *options) {
 	options.cacheSize = o.size
 }
 
+// WithValueCompression returns an option that compresses map values with the given codec
+// Values are compressed before they're written and decompressed when they're read. Compressed values are
+// tagged, so a map may contain a mix of compressed and uncompressed values.
+func WithValueCompression(codec primitive.Codec) Option {
+	return &compressionOption{
+		codec: codec,
+	}
+}
+
+// compressionOption is a value compression option
+type compressionOption struct {
+	codec primitive.Codec
+}
+
+func (o *compressionOption) apply(options *options) {
+	options.codec = o.codec
+}
+
+// WithMaxValueSize returns an option that rejects writes of values larger than the given size in bytes
+// Writes of values exceeding the limit fail with ErrValueTooLarge before any request is sent. If values are
+// compressed, the limit applies to the value before it's compressed.
+func WithMaxValueSize(size int) Option {
+	if size <= 0 {
+		panic("max value size must be positive")
+	}
+	return &maxValueSizeOption{
+		size: size,
+	}
+}
+
+// maxValueSizeOption is a maximum value size option
+type maxValueSizeOption struct {
+	size int
+}
+
+func (o *maxValueSizeOption) apply(options *options) {
+	options.maxValueSize = o.size
+}
+
+// WithValueSizeWarn returns an option that calls the given function when a value larger than the given size
+// in bytes is written
+// Unlike WithMaxValueSize, the write is not rejected. The function is called with the key and the size of the
+// value before the request is sent. If values are compressed, the size is that of the value before it's compressed.
+func WithValueSizeWarn(size int, f func(key string, size int)) Option {
+	if size <= 0 {
+		panic("warn value size must be positive")
+	}
+	return &valueSizeWarnOption{
+		size: size,
+		f:    f,
+	}
+}
+
+// valueSizeWarnOption is a value size warning option
+type valueSizeWarnOption struct {
+	size int
+	f    func(key string, size int)
+}
+
+func (o *valueSizeWarnOption) apply(options *options) {
+	options.warnValueSize = o.size
+	options.warnFunc = o.f
+}
+
+// WithPartialResults returns an option that returns partial results from reads of all partitions
+// By default, Len and Entries fail if any partition cannot be read. With partial results, the partitions
+// that can be read are still read, and an *errors.PartialError listing the partitions that failed is returned
+// along with the results of the available partitions. Len returns the number of entries in the available
+// partitions, and Entries pushes the entries of the available partitions onto the channel and closes it
+// once they have been read. Writes, and reads of individual keys, still fail if their partition is unavailable.
+func WithPartialResults() Option {
+	return &partialResultsOption{}
+}
+
+// partialResultsOption is a partial results option
+type partialResultsOption struct{}
+
+func (o *partialResultsOption) apply(options *options) {
+	options.partial = true
+}
+
+// WithStreamBuffer returns an option that buffers up to the given number of entries or events read by
+// Entries and Watch
+// By default, entries and events are pushed onto the caller's channel as they're read from the partitions,
+// so a consumer that falls behind stops the partitions' streams from being read until it catches up. With a
+// stream buffer, the streams are read ahead of the consumer until the buffer is full, so a bursty consumer
+// does not stall the streams, at the cost of holding up to the given number of entries or events in memory.
+// Once the buffer is full, reading from the streams is blocked until the consumer catches up, so memory
+// remains bounded. The buffer is shared by all the partitions of the map.
+func WithStreamBuffer(size int) Option {
+	if size <= 0 {
+		panic("stream buffer size must be positive")
+	}
+	return &streamBufferOption{
+		size: size,
+	}
+}
+
+// streamBufferOption is a stream buffer option
+type streamBufferOption struct {
+	size int
+}
+
+func (o *streamBufferOption) apply(options *options) {
+	options.streamBuffer = o.size
+}
+
+// WithLoader returns an option that loads the values of keys that are not present in the map
+// When Get does not find a key, the loader is called to load the value, which is stored in the map if the key
+// is still not set and then returned, making the map a read-through cache. If another client sets the key while
+// the value is being loaded, its value is returned rather than the loaded value. Concurrent loads of the same key
+// by the map are combined into a single call to the loader. If the loader returns a nil value, nothing is
+// stored and Get fails with a NotFound error. The loader is typically combined with WithCache.
+func WithLoader(loader func(ctx context.Context, key string) ([]byte, error)) Option {
+	return &loaderOption{
+		loader: loader,
+	}
+}
+
+// loaderOption is a read-through loader option
+type loaderOption struct {
+	loader func(ctx context.Context, key string) ([]byte, error)
+}
+
+func (o *loaderOption) apply(options *options) {
+	options.loader = o.loader
+}
+
 // PutOption is an option for the Put method
 type PutOption interface {
 	beforePut(request *api.PutRequest)
@@ -108,6 +246,35 @@ func (o NotSetOption) afterPut(response *api.PutResponse) {
 
 }
 
+// WithReturnCurrentOnConflict returns a Put option that returns the current entry when a conditional put fails
+// If the put fails because the entry's version does not match the required version or the key is already set,
+// the current entry of the key is returned along with the error, so the caller can retry the put without
+// reading the entry first. The entry is read once the put has failed, and if the key is not present, no
+// entry is returned.
+func WithReturnCurrentOnConflict() PutOption {
+	return returnCurrentOption{}
+}
+
+type returnCurrentOption struct{}
+
+func (o returnCurrentOption) beforePut(request *api.PutRequest) {
+
+}
+
+func (o returnCurrentOption) afterPut(response *api.PutResponse) {
+
+}
+
+// isReturnCurrent returns whether the given options include WithReturnCurrentOnConflict
+func isReturnCurrent(opts []PutOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(returnCurrentOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // GetOption is an option for the Get method
 type GetOption interface {
 	beforeGet(request *api.GetRequest)
@@ -132,6 +299,135 @@ func (o defaultOption) afterGet(response *api.GetResponse) {
 	}
 }
 
+// WithErrorOnNotFound returns a Get option that fails with a NotFound error if the key is not present
+// Some map services represent an absent key as an entry with no value and the zero version, for which Get
+// returns a nil entry without an error. With this option, Get fails with an error that can be classified with
+// errors.IsNotFound instead. Map services that already fail with a NotFound error are unaffected. The option
+// takes precedence over WithDefault.
+func WithErrorOnNotFound() GetOption {
+	return errorOnNotFoundOption{}
+}
+
+type errorOnNotFoundOption struct{}
+
+func (o errorOnNotFoundOption) beforeGet(request *api.GetRequest) {
+}
+
+func (o errorOnNotFoundOption) afterGet(response *api.GetResponse) {
+}
+
+// isErrorOnNotFound returns whether the given options include WithErrorOnNotFound
+func isErrorOnNotFound(opts []GetOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(errorOnNotFoundOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WithIfVersionNot returns a Get option that omits the value if the entry's version matches the given version
+// If the version of the stored entry equals the given version, Get returns the entry with its current version
+// and no value, indicating the value held by the caller has not been modified. The zero version never matches,
+// since it's the version of entries that are not present in the map.
+func WithIfVersionNot(version Version) GetOption {
+	return ifVersionNotOption{version: version}
+}
+
+type ifVersionNotOption struct {
+	version Version
+}
+
+func (o ifVersionNotOption) beforeGet(request *api.GetRequest) {
+}
+
+func (o ifVersionNotOption) afterGet(response *api.GetResponse) {
+	if o.version != 0 && Version(response.Version) == o.version {
+		response.Value = nil
+	}
+}
+
+// Consistency is the consistency level of a read
+type Consistency = primitive.Consistency
+
+var (
+	// Linearizable reads observe all writes completed before the read
+	Linearizable = primitive.Linearizable
+
+	// Sequential reads observe all writes previously observed by the session
+	// Sequential is the default consistency level for reads.
+	Sequential = primitive.Sequential
+
+	// Eventual reads are executed as soon as they're received by the partition and may be stale
+	Eventual = primitive.Eventual
+)
+
+// BoundedStaleness returns the consistency level of reads that may lag the session by up to maxLag indexes
+func BoundedStaleness(maxLag uint64) Consistency {
+	return primitive.BoundedStaleness(maxLag)
+}
+
+// WithConsistency returns a Get option that sets the consistency level of the read
+// The levels are described by primitive.WithConsistency.
+func WithConsistency(consistency Consistency) GetOption {
+	return consistencyOption{consistency: consistency}
+}
+
+type consistencyOption struct {
+	consistency Consistency
+}
+
+func (o consistencyOption) beforeGet(request *api.GetRequest) {
+}
+
+func (o consistencyOption) afterGet(response *api.GetResponse) {
+}
+
+// getQueryOptions returns the session query options for a read with the given options
+func getQueryOptions(opts []GetOption) []primitive.QueryOption {
+	for _, opt := range opts {
+		if o, ok := opt.(consistencyOption); ok {
+			return []primitive.QueryOption{primitive.WithConsistency(o.consistency)}
+		}
+	}
+	return nil
+}
+
+// isNotModified returns whether the given entry is not modified according to the given options
+func isNotModified(entry *Entry, opts []GetOption) bool {
+	for _, opt := range opts {
+		if o, ok := opt.(ifVersionNotOption); ok && o.version != 0 && entry.Version == o.version {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearOption is an option for the Clear method
+type ClearOption interface {
+	applyClear(options *clearOptions)
+}
+
+type clearOptions struct {
+	expectedSize *int
+}
+
+// WithExpectedSize returns a Clear option that clears the map only if its size is the given size
+// The size of the map is queried before the map is cleared, and a Conflict error is returned if the sizes
+// differ. Because the size query and the clear are separate operations, entries added or removed between
+// the query and the clear are not detected and will still be cleared.
+func WithExpectedSize(size int) ClearOption {
+	return expectedSizeOption{size: size}
+}
+
+type expectedSizeOption struct {
+	size int
+}
+
+func (o expectedSizeOption) applyClear(options *clearOptions) {
+	options.expectedSize = &o.size
+}
+
 // WatchOption is an option for the Watch method
 type WatchOption interface {
 	beforeWatch(request *api.EventRequest)
@@ -153,6 +449,103 @@ func (o replayOption) afterWatch(response *api.EventResponse) {
 
 }
 
+// WithFromVersion returns a watch option that resumes watching the map from the given version
+// Only changes with a version greater than the given version are delivered. If changes following the given
+// version may have been missed, Watch fails with a NotSupported error. The option is only supported for maps
+// stored in a single partition.
+func WithFromVersion(version Version) WatchOption {
+	return fromVersionOption{version: version}
+}
+
+type fromVersionOption struct {
+	version Version
+}
+
+func (o fromVersionOption) beforeWatch(request *api.EventRequest) {
+
+}
+
+func (o fromVersionOption) afterWatch(response *api.EventResponse) {
+
+}
+
+// getFromVersion returns the version from which a watch with the given options resumes, if any
+func getFromVersion(opts []WatchOption) (Version, bool) {
+	for _, opt := range opts {
+		if o, ok := opt.(fromVersionOption); ok {
+			return o.version, true
+		}
+	}
+	return 0, false
+}
+
+// WithCoalesce returns a watch option that coalesces the events for each key within the given window
+// When an event is received for a key with no pending event, the event is held for the window, and events for
+// the key received within the window replace it, so only the most recent event for the key is delivered once
+// the window has elapsed. Intermediate events are dropped, so an entry that's inserted and then updated within
+// the window is delivered as a single EventUpdated event, and an entry that's inserted and then removed is
+// delivered as a single EventRemoved event. Events for different keys are delivered in the order in which their
+// windows elapse. Pending events are delivered when the watch ends.
+func WithCoalesce(window time.Duration) WatchOption {
+	if window <= 0 {
+		panic("coalesce window must be positive")
+	}
+	return coalesceOption{window: window}
+}
+
+type coalesceOption struct {
+	window time.Duration
+}
+
+func (o coalesceOption) beforeWatch(request *api.EventRequest) {
+
+}
+
+func (o coalesceOption) afterWatch(response *api.EventResponse) {
+
+}
+
+// getCoalesceWindow returns the window within which a watch with the given options coalesces events, if any
+func getCoalesceWindow(opts []WatchOption) (time.Duration, bool) {
+	for _, opt := range opts {
+		if o, ok := opt.(coalesceOption); ok {
+			return o.window, true
+		}
+	}
+	return 0, false
+}
+
+// WithIncludePrevValue returns a watch option that ensures removal events carry the last value of the key
+// The map service includes the removed value in most removal events, but when it does not, the value is filled
+// in from the last value of the key observed by the watch. To know the values of keys that are not modified
+// after the watch is opened, the entries of the map are read once the watch has been registered, and Watch
+// does not return until all the entries have been read, so opening the watch blocks for a full scan of the
+// map. The watch also holds the last value of every key in memory for as long as it's open, so the option
+// should be used with care on large maps.
+func WithIncludePrevValue() WatchOption {
+	return includePrevValueOption{}
+}
+
+type includePrevValueOption struct{}
+
+func (o includePrevValueOption) beforeWatch(request *api.EventRequest) {
+
+}
+
+func (o includePrevValueOption) afterWatch(response *api.EventResponse) {
+
+}
+
+// isIncludePrevValue returns whether the given options include WithIncludePrevValue
+func isIncludePrevValue(opts []WatchOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(includePrevValueOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
 type filterOption struct {
 	filter Filter
 }
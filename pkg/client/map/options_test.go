@@ -36,6 +36,9 @@ func TestOptions(t *testing.T) {
 	WithDefault([]byte("foo")).afterGet(getResponse)
 	assert.Equal(t, "foo", string(getResponse.Value))
 
+	assert.Empty(t, getQueryOptions(nil))
+	assert.Len(t, getQueryOptions([]GetOption{WithConsistency(Eventual)}), 1)
+
 	eventRequest := &api.EventRequest{}
 	assert.False(t, eventRequest.Replay)
 	WithReplay().beforeWatch(eventRequest)
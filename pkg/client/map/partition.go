@@ -16,8 +16,11 @@ package _map //nolint:golint
 
 import (
 	"context"
+	"fmt"
 	"github.com/atomix/api/proto/atomix/headers"
 	api "github.com/atomix/api/proto/atomix/map"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/lock"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"google.golang.org/grpc"
 )
@@ -33,8 +36,10 @@ func newPartition(ctx context.Context, name primitive.Name, session *primitive.S
 		return nil, err
 	}
 	var partition Map = &mapPartition{
-		name:     name,
-		instance: instance,
+		name:      name,
+		instance:  instance,
+		codec:     options.codec,
+		snapshots: newSnapshotHistory(),
 	}
 	if options.cached {
 		cached, err := newCachingMap(partition, options.cacheSize)
@@ -47,8 +52,10 @@ func newPartition(ctx context.Context, name primitive.Name, session *primitive.S
 }
 
 type mapPartition struct {
-	name     primitive.Name
-	instance *primitive.Instance
+	name      primitive.Name
+	instance  *primitive.Instance
+	codec     primitive.Codec
+	snapshots *snapshotHistory
 }
 
 func (m *mapPartition) Name() primitive.Name {
@@ -56,12 +63,16 @@ func (m *mapPartition) Name() primitive.Name {
 }
 
 func (m *mapPartition) Put(ctx context.Context, key string, value []byte, opts ...PutOption) (*Entry, error) {
+	encoded, err := primitive.EncodeValue(m.codec, value)
+	if err != nil {
+		return nil, err
+	}
 	r, err := m.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewMapServiceClient(conn)
 		request := &api.PutRequest{
 			Header: header,
 			Key:    key,
-			Value:  value,
+			Value:  encoded,
 		}
 		for i := range opts {
 			opts[i].beforePut(request)
@@ -76,6 +87,9 @@ func (m *mapPartition) Put(ctx context.Context, key string, value []byte, opts .
 		return response.Header, response, nil
 	})
 	if err != nil {
+		if (errors.IsConflict(err) || errors.IsAlreadyExists(err)) && isReturnCurrent(opts) {
+			return m.getCurrent(ctx, key, err)
+		}
 		return nil, err
 	}
 
@@ -89,6 +103,16 @@ func (m *mapPartition) Put(ctx context.Context, key string, value []byte, opts .
 	}, nil
 }
 
+// getCurrent returns the current entry of the given key along with the error with which a put of the key failed
+// If the key is not present or the entry cannot be read, only the error is returned.
+func (m *mapPartition) getCurrent(ctx context.Context, key string, err error) (*Entry, error) {
+	entry, getErr := m.Get(ctx, key)
+	if getErr != nil || entry.Version == 0 {
+		return nil, err
+	}
+	return entry, err
+}
+
 func (m *mapPartition) Get(ctx context.Context, key string, opts ...GetOption) (*Entry, error) {
 	r, err := m.instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewMapServiceClient(conn)
@@ -107,21 +131,32 @@ func (m *mapPartition) Get(ctx context.Context, key string, opts ...GetOption) (
 			opts[i].afterGet(response)
 		}
 		return response.Header, response, nil
-	})
+	}, getQueryOptions(opts)...)
 	if err != nil {
 		return nil, err
 	}
 
 	response := r.(*api.GetResponse)
+	if response.Version == 0 && isErrorOnNotFound(opts) {
+		return nil, errors.NewNotFound(fmt.Sprintf("key %s not found", key))
+	}
+	value, err := primitive.DecodeValue(m.codec, response.Value)
+	if err != nil {
+		return nil, err
+	}
 	return &Entry{
 		Key:     key,
-		Value:   response.Value,
+		Value:   value,
 		Version: Version(response.Version),
 		Created: response.Created,
 		Updated: response.Updated,
 	}, nil
 }
 
+func (m *mapPartition) GetAll(ctx context.Context, keys []string) ([]*Entry, error) {
+	return getAll(ctx, m, keys)
+}
+
 func (m *mapPartition) Remove(ctx context.Context, key string, opts ...RemoveOption) (*Entry, error) {
 	r, err := m.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewMapServiceClient(conn)
@@ -146,13 +181,87 @@ func (m *mapPartition) Remove(ctx context.Context, key string, opts ...RemoveOpt
 	}
 
 	response := r.(*api.RemoveResponse)
+	value, err := primitive.DecodeValue(m.codec, response.PreviousValue)
+	if err != nil {
+		return nil, err
+	}
 	return &Entry{
 		Key:     key,
-		Value:   response.PreviousValue,
+		Value:   value,
 		Version: Version(response.PreviousVersion),
 	}, nil
 }
 
+func (m *mapPartition) RemoveAll(ctx context.Context, keys []string) (int, error) {
+	removed := 0
+	for len(keys) > 0 {
+		fns := make([]primitive.CommandFunc, len(keys))
+		for i, key := range keys {
+			key := key
+			fns[i] = func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+				client := api.NewMapServiceClient(conn)
+				request := &api.RemoveRequest{
+					Header: header,
+					Key:    key,
+				}
+				response, err := client.Remove(ctx, request)
+				if err != nil {
+					return nil, nil, err
+				}
+				return response.Header, response, nil
+			}
+		}
+
+		// The batch stops at a key that's not present, so the remaining keys are removed in a new batch
+		results, err := m.instance.DoBatch(ctx, fns)
+		removed += len(results)
+		if err == nil {
+			break
+		}
+		if !errors.IsNotFound(err) {
+			return removed, &RemoveAllError{Key: keys[len(results)], Err: err}
+		}
+		keys = keys[len(results)+1:]
+	}
+	return removed, nil
+}
+
+func (m *mapPartition) LockKey(ctx context.Context, key string) (KeyLock, error) {
+	name := primitive.NewName(m.name.Namespace, m.name.Database, m.name.Scope, fmt.Sprintf("%s.locks.%s", m.name.Name, key))
+	l, err := lock.New(ctx, name, []*primitive.Session{m.instance.Session})
+	if err != nil {
+		return nil, err
+	}
+	version, err := l.Lock(ctx)
+	if err != nil {
+		_ = l.Close(context.Background())
+		return nil, err
+	}
+	return &keyLock{
+		key:     key,
+		lock:    l,
+		version: version,
+	}, nil
+}
+
+// keyLock is a KeyLock backed by a lock primitive
+type keyLock struct {
+	key     string
+	lock    lock.Lock
+	version uint64
+}
+
+func (l *keyLock) Key() string {
+	return l.key
+}
+
+func (l *keyLock) Unlock(ctx context.Context) error {
+	if _, err := l.lock.Unlock(ctx, lock.IfVersion(l.version)); err != nil {
+		return err
+	}
+	return l.lock.Close(ctx)
+}
+
 func (m *mapPartition) Len(ctx context.Context) (int, error) {
 	r, err := m.instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewMapServiceClient(conn)
@@ -171,7 +280,10 @@ func (m *mapPartition) Len(ctx context.Context) (int, error) {
 	return int(r.(*api.SizeResponse).Size_), nil
 }
 
-func (m *mapPartition) Clear(ctx context.Context) error {
+func (m *mapPartition) Clear(ctx context.Context, opts ...ClearOption) error {
+	if err := checkClear(ctx, m, opts); err != nil {
+		return err
+	}
 	_, err := m.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewMapServiceClient(conn)
 		request := &api.ClearRequest{
@@ -186,6 +298,109 @@ func (m *mapPartition) Clear(ctx context.Context) error {
 	return err
 }
 
+// checkClear checks the preconditions of the given Clear options against the given map
+func checkClear(ctx context.Context, m Map, opts []ClearOption) error {
+	options := &clearOptions{}
+	for _, opt := range opts {
+		opt.applyClear(options)
+	}
+	if options.expectedSize == nil {
+		return nil
+	}
+	size, err := m.Len(ctx)
+	if err != nil {
+		return err
+	}
+	if size != *options.expectedSize {
+		return errors.NewConflict(fmt.Sprintf("expected map size %d, but size is %d", *options.expectedSize, size))
+	}
+	return nil
+}
+
+func (m *mapPartition) MultiCAS(ctx context.Context, conditions map[string]Version, updates map[string][]byte) (bool, error) {
+	if ok, err := checkVersions(ctx, m, conditions); err != nil || !ok {
+		return false, err
+	}
+	if len(updates) == 0 {
+		return true, nil
+	}
+
+	fns := make([]primitive.CommandFunc, 0, len(updates))
+	for key, value := range updates {
+		key := key
+		value, err := primitive.EncodeValue(m.codec, value)
+		if err != nil {
+			return false, err
+		}
+		version, conditional := conditions[key]
+		fns = append(fns, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewMapServiceClient(conn)
+			request := &api.PutRequest{
+				Header: header,
+				Key:    key,
+				Value:  value,
+			}
+			if conditional {
+				if version == 0 {
+					request.IfEmpty = true
+				} else {
+					request.Version = uint64(version)
+				}
+			}
+			response, err := client.Put(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		})
+	}
+	if _, err := m.instance.DoBatch(ctx, fns); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *mapPartition) ReplaceAll(ctx context.Context, entries map[string][]byte) error {
+	fns := make([]primitive.CommandFunc, 0, len(entries)+1)
+	fns = append(fns, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		client := api.NewMapServiceClient(conn)
+		request := &api.ClearRequest{
+			Header: header,
+		}
+		response, err := client.Clear(ctx, request)
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	})
+	for key, value := range entries {
+		key := key
+		value, err := primitive.EncodeValue(m.codec, value)
+		if err != nil {
+			return err
+		}
+		fns = append(fns, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewMapServiceClient(conn)
+			request := &api.PutRequest{
+				Header: header,
+				Key:    key,
+				Value:  value,
+			}
+			response, err := client.Put(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		})
+	}
+	_, err := m.instance.DoBatch(ctx, fns)
+	return err
+}
+
+func (m *mapPartition) EntriesInVersionRange(ctx context.Context, min Version, max Version, ch chan<- *Entry) error {
+	return entriesInVersionRange(ctx, m.Entries, min, max, ch)
+}
+
 func (m *mapPartition) Entries(ctx context.Context, ch chan<- *Entry) error {
 	stream, err := m.instance.DoQueryStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewMapServiceClient(conn)
@@ -208,19 +423,117 @@ func (m *mapPartition) Entries(ctx context.Context, ch chan<- *Entry) error {
 		defer close(ch)
 		for event := range stream {
 			response := event.(*api.EntriesResponse)
-			ch <- &Entry{
+			value, err := primitive.DecodeValue(m.codec, response.Value)
+			if err != nil {
+				continue
+			}
+			entry := &Entry{
 				Key:     response.Key,
-				Value:   response.Value,
+				Value:   value,
 				Version: Version(response.Version),
 				Created: response.Created,
 				Updated: response.Updated,
 			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return nil
 }
 
+func (m *mapPartition) ReadOnly() ReadOnlyMap {
+	return newReadOnlyMap(m)
+}
+
+func (m *mapPartition) Snapshot(ctx context.Context) (Version, <-chan *Entry, error) {
+	version, entries, err := m.snapshot(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Record the versions of the keys in the snapshot so changes since the snapshot can be diffed
+	ch := make(chan *Entry)
+	go func() {
+		defer close(ch)
+		keys := make(map[string]Version)
+		for entry := range entries {
+			keys[entry.Key] = entry.Version
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// If the snapshot was canceled, the entries may be incomplete
+		if ctx.Err() != nil {
+			return
+		}
+		m.snapshots.record(version, keys)
+	}()
+	return version, ch, nil
+}
+
+// snapshot lists the entries in the partition as of a consistent version
+func (m *mapPartition) snapshot(ctx context.Context) (Version, <-chan *Entry, error) {
+	// The stream handshake is sent at the index at which the query is evaluated. The handshake is
+	// received before DoQueryStream returns, so the index can be read once the stream is open.
+	var index uint64
+	stream, err := m.instance.DoQueryStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
+		client := api.NewMapServiceClient(conn)
+		request := &api.EntriesRequest{
+			Header: header,
+		}
+		return client.Entries(ctx, request)
+	}, func(responses interface{}) (*headers.ResponseHeader, interface{}, error) {
+		response, err := responses.(api.MapService_EntriesClient).Recv()
+		if err != nil {
+			return nil, nil, err
+		}
+		if response.Header.Type == headers.ResponseType_OPEN_STREAM {
+			index = response.Header.Index
+		}
+		return response.Header, response, nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ch := make(chan *Entry)
+	go func() {
+		defer close(ch)
+		for event := range stream {
+			response := event.(*api.EntriesResponse)
+			value, err := primitive.DecodeValue(m.codec, response.Value)
+			if err != nil {
+				continue
+			}
+			entry := &Entry{
+				Key:     response.Key,
+				Value:   value,
+				Version: Version(response.Version),
+				Created: response.Created,
+				Updated: response.Updated,
+			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return Version(index), ch, nil
+}
+
 func (m *mapPartition) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	fromVersion, resume := getFromVersion(opts)
+
+	// The stream handshake is sent at the index at which the listener is registered
+	var index uint64
+	ctx, cancel := context.WithCancel(ctx)
 	stream, err := m.instance.DoCommandStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewMapServiceClient(conn)
 		request := &api.EventRequest{
@@ -235,16 +548,27 @@ func (m *mapPartition) Watch(ctx context.Context, ch chan<- *Event, opts ...Watc
 		if err != nil {
 			return nil, nil, err
 		}
+		if response.Header.Type == headers.ResponseType_OPEN_STREAM && index == 0 {
+			index = response.Header.Index
+		}
 		for _, opt := range opts {
 			opt.afterWatch(response)
 		}
 		return response.Header, response, nil
 	})
 	if err != nil {
+		cancel()
 		return err
 	}
 
+	// Changes between the requested version and the registration of the listener cannot be replayed
+	if resume && uint64(fromVersion)+1 < index {
+		cancel()
+		return errors.New(errors.NotSupported, "cannot watch from version %d: changes prior to version %d cannot be replayed", fromVersion, index)
+	}
+
 	go func() {
+		defer cancel()
 		defer close(ch)
 		for event := range stream {
 			response := event.(*api.EventResponse)
@@ -261,14 +585,22 @@ func (m *mapPartition) Watch(ctx context.Context, ch chan<- *Event, opts ...Watc
 				t = EventUpdated
 				version = Version(response.Version)
 			case api.EventResponse_REMOVED:
+				// The event does not indicate whether the key expired, so expirations are published as removals
 				t = EventRemoved
 				version = Version(response.Header.Index)
 			}
+			value, err := primitive.DecodeValue(m.codec, response.Value)
+			if err != nil {
+				continue
+			}
+			if resume && version <= fromVersion {
+				continue
+			}
 			ch <- &Event{
 				Type: t,
 				Entry: &Entry{
 					Key:     response.Key,
-					Value:   response.Value,
+					Value:   value,
 					Version: version,
 					Created: response.Created,
 					Updated: response.Updated,
@@ -279,6 +611,82 @@ func (m *mapPartition) Watch(ctx context.Context, ch chan<- *Event, opts ...Watc
 	return nil
 }
 
+// getAll gets the entries for the given keys from the given map, omitting keys that are not present
+func getAll(ctx context.Context, m Map, keys []string) ([]*Entry, error) {
+	entries := make([]*Entry, 0, len(keys))
+	for _, key := range keys {
+		entry, err := m.Get(ctx, key)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if entry != nil && entry.Value != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// checkVersions returns whether the versions of the given keys in the given map match the given versions
+// Keys that are not present in the map have version zero.
+func checkVersions(ctx context.Context, m Map, versions map[string]Version) (bool, error) {
+	for key, version := range versions {
+		entry, err := m.Get(ctx, key)
+		if err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		var current Version
+		if err == nil && entry != nil {
+			current = entry.Version
+		}
+		if current != version {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m *mapPartition) AwaitKey(ctx context.Context, key string) (*Entry, error) {
+	return awaitKey(ctx, m, key)
+}
+
+// awaitKey waits for the given key to be present in the given map
+func awaitKey(ctx context.Context, m Map, key string) (*Entry, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Watch the key before reading it to ensure a write that occurs after the read is not missed
+	ch := make(chan *Event)
+	if err := m.Watch(ctx, ch, WithFilter(Filter{Key: key})); err != nil {
+		return nil, err
+	}
+	defer func() {
+		go func() {
+			for range ch {
+			}
+		}()
+	}()
+
+	entry, err := m.Get(ctx, key)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	} else if err == nil && entry != nil && entry.Version != 0 {
+		return entry, nil
+	}
+
+	for event := range ch {
+		if event.Entry.Key == key && (event.Type == EventInserted || event.Type == EventUpdated) {
+			return event.Entry, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, errors.New(errors.Unavailable, "watch closed before key %s was set", key)
+}
+
 func (m *mapPartition) Close(ctx context.Context) error {
 	return m.instance.Close(ctx)
 }
@@ -0,0 +1,115 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+)
+
+// ReadOnlyMap is a read-only view of a Map
+// A ReadOnlyMap does not expose the methods that modify the map, so code that's only given a ReadOnlyMap
+// cannot modify the map. The view does not own the map: closing or deleting the map is left to the owner.
+type ReadOnlyMap interface {
+	// Name returns the name of the map
+	Name() primitive.Name
+
+	// Get gets the value of the given key
+	Get(ctx context.Context, key string, opts ...GetOption) (*Entry, error)
+
+	// GetAll gets the values of the given keys
+	// The entries are returned in the order of the given keys. Keys that are not present in the map are omitted.
+	GetAll(ctx context.Context, keys []string) ([]*Entry, error)
+
+	// AwaitKey waits for the given key to be present in the map and returns its entry
+	AwaitKey(ctx context.Context, key string) (*Entry, error)
+
+	// Len returns the number of entries in the map
+	Len(ctx context.Context) (int, error)
+
+	// Entries lists the entries in the map
+	// This is a non-blocking method. If the method returns without error, key/value pairs will be pushed on to the
+	// given channel and the channel will be closed once all entries have been read from the map.
+	Entries(ctx context.Context, ch chan<- *Entry) error
+
+	// EntriesInVersionRange lists the entries in the map with a version in the given inclusive range
+	EntriesInVersionRange(ctx context.Context, min Version, max Version, ch chan<- *Entry) error
+
+	// Snapshot lists the entries in the map as of a consistent version
+	Snapshot(ctx context.Context) (Version, <-chan *Entry, error)
+
+	// Diff lists the changes to the map since the snapshot at the given version
+	Diff(ctx context.Context, fromVersion Version, ch chan<- *Event) error
+
+	// Watch watches the map for changes
+	// This is a non-blocking method. If the method returns without error, map events will be pushed onto
+	// the given channel in the order in which they occur.
+	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
+}
+
+var _ primitive.Sized = ReadOnlyMap(nil)
+
+// newReadOnlyMap returns a read-only view of the given Map
+func newReadOnlyMap(_map Map) ReadOnlyMap {
+	return &readOnlyMap{
+		delegate: _map,
+	}
+}
+
+// readOnlyMap is a ReadOnlyMap that delegates reads to an underlying Map
+// The underlying Map is not embedded so the view cannot be converted back to a Map.
+type readOnlyMap struct {
+	delegate Map
+}
+
+func (m *readOnlyMap) Name() primitive.Name {
+	return m.delegate.Name()
+}
+
+func (m *readOnlyMap) Get(ctx context.Context, key string, opts ...GetOption) (*Entry, error) {
+	return m.delegate.Get(ctx, key, opts...)
+}
+
+func (m *readOnlyMap) GetAll(ctx context.Context, keys []string) ([]*Entry, error) {
+	return m.delegate.GetAll(ctx, keys)
+}
+
+func (m *readOnlyMap) AwaitKey(ctx context.Context, key string) (*Entry, error) {
+	return m.delegate.AwaitKey(ctx, key)
+}
+
+func (m *readOnlyMap) Len(ctx context.Context) (int, error) {
+	return m.delegate.Len(ctx)
+}
+
+func (m *readOnlyMap) Entries(ctx context.Context, ch chan<- *Entry) error {
+	return m.delegate.Entries(ctx, ch)
+}
+
+func (m *readOnlyMap) EntriesInVersionRange(ctx context.Context, min Version, max Version, ch chan<- *Entry) error {
+	return m.delegate.EntriesInVersionRange(ctx, min, max, ch)
+}
+
+func (m *readOnlyMap) Snapshot(ctx context.Context) (Version, <-chan *Entry, error) {
+	return m.delegate.Snapshot(ctx)
+}
+
+func (m *readOnlyMap) Diff(ctx context.Context, fromVersion Version, ch chan<- *Event) error {
+	return m.delegate.Diff(ctx, fromVersion, ch)
+}
+
+func (m *readOnlyMap) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	return m.delegate.Watch(ctx, ch, opts...)
+}
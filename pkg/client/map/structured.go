@@ -0,0 +1,203 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package _map //nolint:golint
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"strconv"
+	"strings"
+)
+
+// KeyEncoder encodes structured keys as map keys
+// Encoders must be injective: distinct structured keys must be encoded as distinct map keys.
+type KeyEncoder interface {
+	// Encode encodes the given key fields as a map key
+	Encode(fields []string) string
+
+	// Decode decodes the given map key into its fields
+	Decode(key string) ([]string, error)
+}
+
+// NewLengthPrefixedKeyEncoder returns a KeyEncoder that prefixes each field with its length
+// Each field is encoded as its length in bytes, a colon, and the field, so fields may contain any character
+// and concatenations of different fields never collide, e.g. ["a", "bc"] is encoded as "1:a2:bc" and
+// ["ab", "c"] is encoded as "2:ab1:c".
+func NewLengthPrefixedKeyEncoder() KeyEncoder {
+	return lengthPrefixedKeyEncoder{}
+}
+
+// lengthPrefixedKeyEncoder is a KeyEncoder that prefixes each field with its length
+type lengthPrefixedKeyEncoder struct{}
+
+func (e lengthPrefixedKeyEncoder) Encode(fields []string) string {
+	var sb strings.Builder
+	for _, field := range fields {
+		sb.WriteString(strconv.Itoa(len(field)))
+		sb.WriteByte(':')
+		sb.WriteString(field)
+	}
+	return sb.String()
+}
+
+func (e lengthPrefixedKeyEncoder) Decode(key string) ([]string, error) {
+	fields := make([]string, 0)
+	for len(key) > 0 {
+		i := strings.IndexByte(key, ':')
+		if i <= 0 {
+			return nil, errors.NewInvalid("malformed key: missing field length")
+		}
+		length, err := strconv.Atoi(key[:i])
+		if err != nil || length < 0 || length > len(key)-i-1 {
+			return nil, errors.NewInvalid("malformed key: invalid field length")
+		}
+		fields = append(fields, key[i+1:i+1+length])
+		key = key[i+1+length:]
+	}
+	return fields, nil
+}
+
+// StructuredMap is a map keyed by structured keys
+// Structured keys are composed of multiple fields and are encoded as map keys by a KeyEncoder, so callers
+// don't need to concatenate fields into keys themselves.
+type StructuredMap interface {
+	primitive.Primitive
+
+	// Put sets a key/value pair in the map
+	Put(ctx context.Context, key []string, value []byte, opts ...PutOption) (*StructuredEntry, error)
+
+	// Get gets the value of the given key
+	Get(ctx context.Context, key []string, opts ...GetOption) (*StructuredEntry, error)
+
+	// Remove removes a key from the map
+	Remove(ctx context.Context, key []string, opts ...RemoveOption) (*StructuredEntry, error)
+
+	// Len returns the number of entries in the map
+	Len(ctx context.Context) (int, error)
+
+	// Clear removes all entries from the map
+	Clear(ctx context.Context, opts ...ClearOption) error
+
+	// Entries lists the entries in the map
+	// This is a non-blocking method. If the method returns without error, entries will be pushed on to the
+	// given channel and the channel will be closed once all entries have been read from the map. Entries
+	// whose keys cannot be decoded by the map's KeyEncoder are skipped.
+	Entries(ctx context.Context, ch chan<- *StructuredEntry) error
+}
+
+// StructuredEntry is an entry in a StructuredMap
+// The Key of the entry is the encoded key.
+type StructuredEntry struct {
+	*Entry
+
+	// Fields is the structured key of the entry
+	Fields []string
+}
+
+// NewStructuredMap returns a StructuredMap that stores entries in the given map
+// Keys are encoded with the given encoder. If the encoder is nil, keys are encoded with a length-prefixed
+// encoder.
+func NewStructuredMap(m Map, encoder KeyEncoder) StructuredMap {
+	if encoder == nil {
+		encoder = NewLengthPrefixedKeyEncoder()
+	}
+	return &structuredMap{
+		_map:    m,
+		encoder: encoder,
+	}
+}
+
+// structuredMap is the implementation of StructuredMap
+type structuredMap struct {
+	_map    Map
+	encoder KeyEncoder
+}
+
+func (m *structuredMap) Name() primitive.Name {
+	return m._map.Name()
+}
+
+// newEntry returns the structured entry for the given entry with the given fields
+func (m *structuredMap) newEntry(entry *Entry, fields []string) *StructuredEntry {
+	if entry == nil {
+		return nil
+	}
+	return &StructuredEntry{
+		Entry:  entry,
+		Fields: fields,
+	}
+}
+
+func (m *structuredMap) Put(ctx context.Context, key []string, value []byte, opts ...PutOption) (*StructuredEntry, error) {
+	entry, err := m._map.Put(ctx, m.encoder.Encode(key), value, opts...)
+	if err != nil {
+		if entry != nil {
+			return m.newEntry(entry, key), err
+		}
+		return nil, err
+	}
+	return m.newEntry(entry, key), nil
+}
+
+func (m *structuredMap) Get(ctx context.Context, key []string, opts ...GetOption) (*StructuredEntry, error) {
+	entry, err := m._map.Get(ctx, m.encoder.Encode(key), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return m.newEntry(entry, key), nil
+}
+
+func (m *structuredMap) Remove(ctx context.Context, key []string, opts ...RemoveOption) (*StructuredEntry, error) {
+	entry, err := m._map.Remove(ctx, m.encoder.Encode(key), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return m.newEntry(entry, key), nil
+}
+
+func (m *structuredMap) Len(ctx context.Context) (int, error) {
+	return m._map.Len(ctx)
+}
+
+func (m *structuredMap) Clear(ctx context.Context, opts ...ClearOption) error {
+	return m._map.Clear(ctx, opts...)
+}
+
+func (m *structuredMap) Entries(ctx context.Context, ch chan<- *StructuredEntry) error {
+	entries := make(chan *Entry)
+	if err := m._map.Entries(ctx, entries); err != nil {
+		return err
+	}
+	go func() {
+		defer close(ch)
+		for entry := range entries {
+			fields, err := m.encoder.Decode(entry.Key)
+			if err != nil {
+				continue
+			}
+			ch <- m.newEntry(entry, fields)
+		}
+	}()
+	return nil
+}
+
+func (m *structuredMap) Close(ctx context.Context) error {
+	return m._map.Close(ctx)
+}
+
+func (m *structuredMap) Delete(ctx context.Context) error {
+	return m._map.Delete(ctx)
+}
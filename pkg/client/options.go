@@ -16,6 +16,7 @@ package client
 
 import (
 	"github.com/lucasbfernandes/go-client/pkg/client/peer"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"google.golang.org/grpc"
 	"os"
 	"time"
@@ -46,6 +47,9 @@ type options struct {
 	scope          string
 	namespace      string
 	sessionTimeout time.Duration
+	lazy           bool
+	sessionManager *primitive.SessionManager
+	callOpts       []grpc.CallOption
 }
 
 // Option provides a client option
@@ -184,3 +188,49 @@ func WithSessionTimeout(timeout time.Duration) Option {
 		timeout: timeout,
 	}
 }
+
+type lazyOption struct{}
+
+func (o *lazyOption) apply(options *options) {
+	options.lazy = true
+}
+
+// WithLazyPrimitives configures the client to defer opening sessions and creating primitives until first use
+// Databases and primitives are returned without waiting for their partitions, so startup is not blocked by
+// transient cluster unavailability. The first operation on a primitive opens its session and creates the
+// primitive, and returns an Unavailable error if the partition still cannot be reached.
+func WithLazyPrimitives() Option {
+	return &lazyOption{}
+}
+
+type sessionManagerOption struct {
+	manager *primitive.SessionManager
+}
+
+func (o *sessionManagerOption) apply(options *options) {
+	options.sessionManager = o.manager
+}
+
+// WithSessionManager configures the client to keep its sessions alive with the given SessionManager
+// The sessions of all the databases returned by the client are kept alive on the manager's shared ticker
+// rather than each on its own ticker. The manager is not closed when the client is closed.
+func WithSessionManager(manager *primitive.SessionManager) Option {
+	return &sessionManagerOption{
+		manager: manager,
+	}
+}
+
+type callOptionsOption struct {
+	opts []grpc.CallOption
+}
+
+func (o *callOptionsOption) apply(options *options) {
+	options.callOpts = append(options.callOpts, o.opts...)
+}
+
+// WithCallOptions configures the client to apply the given gRPC call options to every RPC sent by its sessions
+func WithCallOptions(opts ...grpc.CallOption) Option {
+	return &callOptionsOption{
+		opts: opts,
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"context"
+	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCloseAllTimeout is the time CloseAll waits for primitives to close if the context has no deadline
+const DefaultCloseAllTimeout = 30 * time.Second
+
+// CloseAllError is returned by CloseAll when some of the primitives could not be closed
+type CloseAllError struct {
+	// Errors maps the index of each primitive that could not be closed to the error
+	Errors map[int]error
+
+	// names are the names of the primitives that were closed
+	names []Name
+}
+
+func (e *CloseAllError) Error() string {
+	indexes := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	failures := make([]string, len(indexes))
+	for i, index := range indexes {
+		failures[i] = fmt.Sprintf("%s: %s", e.names[index], e.Errors[index])
+	}
+	return fmt.Sprintf("failed to close %d primitive(s): %s", len(indexes), strings.Join(failures, "; "))
+}
+
+var _ error = &CloseAllError{}
+
+// CloseAll closes the given primitives concurrently
+// Every primitive is closed even if others fail to close. If the context has no deadline, CloseAll waits up to
+// DefaultCloseAllTimeout for the primitives to close. Once the context is done, CloseAll returns without waiting
+// for the remaining primitives, and those primitives are reported as failed with a Timeout error, or a Canceled
+// error if the context was canceled. If any primitive could not be closed, a *CloseAllError listing the
+// failures is returned.
+func CloseAll(ctx context.Context, primitives ...Primitive) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultCloseAllTimeout)
+		defer cancel()
+	}
+
+	type result struct {
+		index int
+		err   error
+	}
+	results := make(chan result, len(primitives))
+	names := make([]Name, len(primitives))
+	for i, primitive := range primitives {
+		names[i] = primitive.Name()
+		go func(i int, primitive Primitive) {
+			results <- result{index: i, err: primitive.Close(ctx)}
+		}(i, primitive)
+	}
+
+	errs := make(map[int]error)
+	closed := make([]bool, len(primitives))
+	for range primitives {
+		select {
+		case result := <-results:
+			closed[result.index] = true
+			if result.err != nil {
+				errs[result.index] = result.err
+			}
+		case <-ctx.Done():
+			for i := range primitives {
+				if !closed[i] {
+					if ctx.Err() == context.Canceled {
+						errs[i] = errors.NewCanceled(ctx.Err().Error())
+					} else {
+						errs[i] = errors.NewTimeout(ctx.Err().Error())
+					}
+				}
+			}
+			return &CloseAllError{Errors: errs, names: names}
+		}
+	}
+	if len(errs) > 0 {
+		return &CloseAllError{Errors: errs, names: names}
+	}
+	return nil
+}
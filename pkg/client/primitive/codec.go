@@ -0,0 +1,120 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"io/ioutil"
+)
+
+// valueTag is the prefix used to tag values written by a Codec
+var valueTag = []byte("\x00acv")
+
+// Codec compresses and decompresses primitive values
+type Codec interface {
+	// Name returns the name of the codec
+	// The name is used to tag compressed values and must not be changed once values have been written.
+	Name() string
+
+	// Compress compresses the given value
+	Compress(value []byte) ([]byte, error)
+
+	// Decompress decompresses the given value
+	Decompress(value []byte) ([]byte, error)
+}
+
+// NewGzipCodec returns a new Codec that compresses values with gzip
+func NewGzipCodec() Codec {
+	return &gzipCodec{}
+}
+
+// gzipCodec is a Codec that compresses values with gzip
+type gzipCodec struct{}
+
+func (c *gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c *gzipCodec) Compress(value []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	if _, err := writer.Write(value); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCodec) Decompress(value []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// EncodeValue compresses the given value with the given codec and tags it
+// Values that do not shrink when compressed are tagged and stored uncompressed. If the codec is nil, the value
+// is returned unchanged.
+func EncodeValue(codec Codec, value []byte) ([]byte, error) {
+	if codec == nil || value == nil {
+		return value, nil
+	}
+	compressed, err := codec.Compress(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) < len(value) {
+		return tagValue(codec.Name(), compressed), nil
+	}
+	return tagValue("", value), nil
+}
+
+// tagValue prefixes the given value with a tag identifying the named codec
+func tagValue(name string, value []byte) []byte {
+	tagged := make([]byte, 0, len(valueTag)+1+len(name)+len(value))
+	tagged = append(tagged, valueTag...)
+	tagged = append(tagged, byte(len(name)))
+	tagged = append(tagged, name...)
+	return append(tagged, value...)
+}
+
+// DecodeValue decodes a value encoded with EncodeValue
+// Values that are not tagged are returned unchanged, so values written without compression can be read
+// alongside compressed values. An untagged value that happens to begin with the tag will be misread.
+func DecodeValue(codec Codec, value []byte) ([]byte, error) {
+	if codec == nil || !bytes.HasPrefix(value, valueTag) {
+		return value, nil
+	}
+	value = value[len(valueTag):]
+	if len(value) == 0 || len(value) < 1+int(value[0]) {
+		return nil, errors.NewInvalid("malformed value tag")
+	}
+	name := string(value[1 : 1+int(value[0])])
+	value = value[1+int(value[0]):]
+	switch name {
+	case "":
+		return value, nil
+	case codec.Name():
+		return codec.Decompress(value)
+	default:
+		return nil, errors.New(errors.Invalid, "unknown value codec %s", name)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"bytes"
+	"crypto/rand"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	codec := NewGzipCodec()
+
+	compressible := bytes.Repeat([]byte("foo"), 1000)
+	encoded, err := EncodeValue(codec, compressible)
+	assert.NoError(t, err)
+	assert.True(t, len(encoded) < len(compressible))
+	decoded, err := DecodeValue(codec, encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, compressible, decoded)
+
+	incompressible := make([]byte, 1000)
+	_, err = rand.Read(incompressible)
+	assert.NoError(t, err)
+	encoded, err = EncodeValue(codec, incompressible)
+	assert.NoError(t, err)
+	assert.Equal(t, incompressible, encoded[len(encoded)-len(incompressible):])
+	decoded, err = DecodeValue(codec, encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, incompressible, decoded)
+
+	decoded, err = DecodeValue(codec, []byte("bar"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(decoded))
+
+	encoded, err = EncodeValue(nil, compressible)
+	assert.NoError(t, err)
+	assert.Equal(t, compressible, encoded)
+
+	_, err = DecodeValue(codec, tagValue("snappy", []byte("bar")))
+	assert.Error(t, err)
+}
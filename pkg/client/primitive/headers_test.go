@@ -0,0 +1,170 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"github.com/atomix/api/proto/atomix/headers"
+	primitiveapi "github.com/atomix/api/proto/atomix/primitive"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestSession(streams int) *Session {
+	session := &Session{
+		streams: make(map[uint64]*Stream),
+	}
+	for i := 0; i < streams; i++ {
+		stream, header := session.nextStreamHeader(primitiveapi.PrimitiveId{})
+		session.recordResponse(header, &headers.ResponseHeader{Index: uint64(i + 1)})
+		stream.serialize(&headers.ResponseHeader{ResponseID: 1})
+	}
+	return session
+}
+
+func TestStreamHeaders(t *testing.T) {
+	session := newTestSession(2)
+	header := session.getState(primitiveapi.PrimitiveId{})
+	assert.Len(t, header.Streams, 2)
+	for _, stream := range header.Streams {
+		assert.Equal(t, uint64(1), stream.ResponseID)
+	}
+
+	// Advancing a stream's response ID updates the stream headers
+	stream := session.streams[1]
+	stream.serialize(&headers.ResponseHeader{ResponseID: 2})
+	header = session.getState(primitiveapi.PrimitiveId{})
+	for _, streamHeader := range header.Streams {
+		if streamHeader.StreamID == stream.ID {
+			assert.Equal(t, uint64(2), streamHeader.ResponseID)
+		} else {
+			assert.Equal(t, uint64(1), streamHeader.ResponseID)
+		}
+	}
+
+	// Streams are only included once the session's response ID has advanced past the stream
+	next, requestHeader := session.nextStreamHeader(primitiveapi.PrimitiveId{})
+	assert.Len(t, session.getState(primitiveapi.PrimitiveId{}).Streams, 2)
+	session.recordResponse(requestHeader, &headers.ResponseHeader{Index: 10})
+	assert.Len(t, session.getState(primitiveapi.PrimitiveId{}).Streams, 3)
+
+	// Closed streams are removed from the stream headers
+	next.Close()
+	stream.Close()
+	header = session.getState(primitiveapi.PrimitiveId{})
+	assert.Len(t, header.Streams, 1)
+	assert.NotEqual(t, stream.ID, header.Streams[0].StreamID)
+}
+
+func BenchmarkSessionState(b *testing.B) {
+	session := newTestSession(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		session.getState(primitiveapi.PrimitiveId{})
+	}
+}
+
+func TestKeepAliveStreamHeaders(t *testing.T) {
+	session := newTestSession(3)
+
+	// Streams are acknowledged in full until a keep-alive succeeds
+	header, streams := session.getKeepAliveState()
+	assert.Len(t, header.Streams, 3)
+	for _, stream := range header.Streams {
+		assert.Equal(t, uint64(1), stream.ResponseID)
+	}
+	header, _ = session.getKeepAliveState()
+	for _, stream := range header.Streams {
+		assert.Equal(t, uint64(1), stream.ResponseID)
+	}
+
+	// Once acknowledged, open streams are listed without their response IDs
+	session.ackStreamHeaders(session.SessionID, streams)
+	header, _ = session.getKeepAliveState()
+	assert.Len(t, header.Streams, 3)
+	for _, stream := range header.Streams {
+		assert.Equal(t, uint64(0), stream.ResponseID)
+	}
+
+	// Only streams that have advanced since the last acknowledgement carry a response ID
+	stream := session.streams[1]
+	stream.serialize(&headers.ResponseHeader{ResponseID: 2})
+	header, streams = session.getKeepAliveState()
+	assert.Len(t, header.Streams, 3)
+	for _, streamHeader := range header.Streams {
+		if streamHeader.StreamID == stream.ID {
+			assert.Equal(t, uint64(2), streamHeader.ResponseID)
+		} else {
+			assert.Equal(t, uint64(0), streamHeader.ResponseID)
+		}
+	}
+
+	// An earlier keep-alive completing late does not move acknowledgements backwards
+	earlier := []headers.StreamHeader{{StreamID: stream.ID, ResponseID: 1}}
+	session.ackStreamHeaders(session.SessionID, streams)
+	session.ackStreamHeaders(session.SessionID, earlier)
+	header, _ = session.getKeepAliveState()
+	for _, streamHeader := range header.Streams {
+		if streamHeader.StreamID == stream.ID {
+			assert.Equal(t, uint64(0), streamHeader.ResponseID)
+		}
+	}
+
+	// The full stream headers are still sent with other session requests
+	for _, streamHeader := range session.getState(primitiveapi.PrimitiveId{}).Streams {
+		assert.NotEqual(t, uint64(0), streamHeader.ResponseID)
+	}
+}
+
+// BenchmarkKeepAliveState compares the size of keep-alive headers with full and compact stream headers
+// Each stream has acknowledged 1000 responses and one in ten streams has advanced since the last keep-alive.
+// The encoded size of the keep-alive header is reported as the bytes metric.
+func BenchmarkKeepAliveState(b *testing.B) {
+	const streams = 1000
+	newSession := func() *Session {
+		session := newTestSession(streams)
+		for _, stream := range session.streams {
+			stream.responseID = 1000
+		}
+		session.invalidateStreamHeaders()
+		_, acked := session.getKeepAliveState()
+		session.ackStreamHeaders(session.SessionID, acked)
+		for _, stream := range session.streams {
+			if stream.ID%10 == 0 {
+				stream.serialize(&headers.ResponseHeader{ResponseID: stream.responseID + 1})
+			}
+		}
+		return session
+	}
+
+	b.Run("Full", func(b *testing.B) {
+		session := newSession()
+		var size int
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			size = session.getState(primitiveapi.PrimitiveId{}).Size()
+		}
+		b.ReportMetric(float64(size), "bytes")
+	})
+	b.Run("Compact", func(b *testing.B) {
+		session := newSession()
+		var size int
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			header, _ := session.getKeepAliveState()
+			size = header.Size()
+		}
+		b.ReportMetric(float64(size), "bytes")
+	})
+}
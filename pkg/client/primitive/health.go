@@ -0,0 +1,147 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is the time a HealthChecker waits for partitions to respond if the context has no
+// deadline
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// Health is the aggregate health of the sessions checked by a HealthChecker
+type Health string
+
+const (
+	// HealthReady indicates all the partitions are reachable
+	HealthReady Health = "ready"
+
+	// HealthDegraded indicates some but not all of the partitions are reachable
+	HealthDegraded Health = "degraded"
+
+	// HealthDown indicates none of the partitions are reachable
+	HealthDown Health = "down"
+)
+
+// PartitionHealth is the health of a single session's partition
+type PartitionHealth struct {
+	// Partition is the ID of the partition
+	Partition int
+
+	// Session is the client ID of the session
+	Session string
+
+	// Error is the error returned when pinging the partition, or nil if the partition is reachable
+	Error error
+}
+
+// Ready returns whether the partition is reachable
+func (h PartitionHealth) Ready() bool {
+	return h.Error == nil
+}
+
+// HealthReport is the result of a health check
+type HealthReport struct {
+	// Health is the aggregate health of the sessions
+	Health Health
+
+	// Partitions is the health of each session's partition in the order in which the sessions were added
+	Partitions []PartitionHealth
+}
+
+// NewHealthChecker returns a new HealthChecker for the given sessions
+func NewHealthChecker(sessions ...*Session) *HealthChecker {
+	checker := &HealthChecker{}
+	checker.Add(sessions...)
+	return checker
+}
+
+// HealthChecker checks the health of a set of sessions
+// Each check pings the sessions' partitions concurrently with Session.Ping, which only sends a keep-alive, so
+// checks do not change the state of the sessions or their primitives. A checker with no sessions is ready.
+type HealthChecker struct {
+	sessions []*Session
+	mu       sync.RWMutex
+}
+
+// Add adds the given sessions to the checker
+func (c *HealthChecker) Add(sessions ...*Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions = append(c.sessions, sessions...)
+}
+
+// Remove removes the given session from the checker
+func (c *HealthChecker) Remove(session *Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, s := range c.sessions {
+		if s == session {
+			c.sessions = append(c.sessions[:i], c.sessions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Check pings the sessions' partitions and returns a report of their health
+// If the context has no deadline, Check waits up to DefaultHealthCheckTimeout for the partitions to respond.
+// Partitions that do not respond before the context is done are reported as unreachable.
+func (c *HealthChecker) Check(ctx context.Context) *HealthReport {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultHealthCheckTimeout)
+		defer cancel()
+	}
+
+	c.mu.RLock()
+	sessions := make([]*Session, len(c.sessions))
+	copy(sessions, c.sessions)
+	c.mu.RUnlock()
+
+	partitions := make([]PartitionHealth, len(sessions))
+	wg := sync.WaitGroup{}
+	wg.Add(len(sessions))
+	for i, session := range sessions {
+		go func(i int, session *Session) {
+			defer wg.Done()
+			partitions[i] = PartitionHealth{
+				Partition: session.Partition,
+				Session:   session.ID(),
+				Error:     session.Ping(ctx),
+			}
+		}(i, session)
+	}
+	wg.Wait()
+
+	ready := 0
+	for _, partition := range partitions {
+		if partition.Ready() {
+			ready++
+		}
+	}
+	health := HealthDegraded
+	if ready == len(partitions) {
+		health = HealthReady
+	} else if ready == 0 {
+		health = HealthDown
+	}
+	return &HealthReport{
+		Health:     health,
+		Partitions: partitions,
+	}
+}
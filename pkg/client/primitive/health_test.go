@@ -0,0 +1,71 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive_test
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(2)
+	defer test.StopTestPartitions(closers[1:])
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions[1:])
+
+	checker := primitive.NewHealthChecker(sessions...)
+	report := checker.Check(context.TODO())
+	assert.Equal(t, primitive.HealthReady, report.Health)
+	assert.Len(t, report.Partitions, 2)
+	for i, partition := range report.Partitions {
+		assert.True(t, partition.Ready())
+		assert.Equal(t, partitions[i].ID, partition.Partition)
+		assert.Equal(t, sessions[i].ID(), partition.Session)
+		assert.True(t, sessions[i].Ready())
+	}
+
+	// Stopping one of the partitions degrades the sessions' health
+	close(closers[0])
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	report = checker.Check(ctx)
+	cancel()
+	assert.Equal(t, primitive.HealthDegraded, report.Health)
+	assert.False(t, report.Partitions[0].Ready())
+	assert.Error(t, report.Partitions[0].Error)
+	assert.True(t, report.Partitions[1].Ready())
+
+	// Removing the unreachable session restores the sessions' health
+	checker.Remove(sessions[0])
+	report = checker.Check(context.TODO())
+	assert.Equal(t, primitive.HealthReady, report.Health)
+	assert.Len(t, report.Partitions, 1)
+
+	// Closed sessions are down
+	assert.NoError(t, sessions[1].Close())
+	assert.False(t, sessions[1].Ready())
+	report = checker.Check(context.TODO())
+	assert.Equal(t, primitive.HealthDown, report.Health)
+	assert.Error(t, report.Partitions[0].Error)
+
+	// Checkers with no sessions are ready
+	assert.Equal(t, primitive.HealthReady, primitive.NewHealthChecker().Check(context.TODO()).Health)
+}
@@ -18,18 +18,39 @@ import (
 	"context"
 	"github.com/atomix/api/proto/atomix/headers"
 	"google.golang.org/grpc"
+	"sync"
 )
 
 // NewInstance creates a new primitive instance
+// An Invalid error is returned if the name is not valid. If the session was created with WithLazyOpen, the
+// instance is not created on the partition until its first operation.
 func NewInstance(ctx context.Context, name Name, session *Session, handler Handler) (*Instance, error) {
+	if err := name.Validate(); err != nil {
+		return nil, err
+	}
 	instance := &Instance{
 		Name:    name,
 		Session: session,
 		handler: handler,
 	}
-	if err := instance.create(ctx); err != nil {
-		return nil, err
+	if !session.lazy {
+		if err := instance.create(ctx); err != nil {
+			return nil, err
+		}
+		instance.created = true
 	}
+	session.acquire()
+	instance.removeListener = session.OnReopen(func(ctx context.Context) {
+		instance.mu.RLock()
+		closed := instance.closed
+		instance.mu.RUnlock()
+		instance.createMu.Lock()
+		created := instance.created
+		instance.createMu.Unlock()
+		if !closed && created {
+			_ = instance.create(ctx)
+		}
+	})
 	return instance, nil
 }
 
@@ -38,6 +59,14 @@ type Instance struct {
 	Name    Name
 	Session *Session
 	handler Handler
+	mu      sync.RWMutex
+	closed  bool
+
+	createMu sync.Mutex
+	created  bool
+
+	removeListener func()
+	releaseOnce    sync.Once
 }
 
 // DoCreate sends a create session request
@@ -51,20 +80,40 @@ func (i *Instance) DoClose(ctx context.Context, f func(ctx context.Context, conn
 }
 
 // DoQuery sends a session query request
-func (i *Instance) DoQuery(ctx context.Context, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) (interface{}, error) {
-	return i.Session.doQuery(ctx, i.Name, f)
+func (i *Instance) DoQuery(ctx context.Context, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error), opts ...QueryOption) (interface{}, error) {
+	if err := i.ensureCreated(ctx); err != nil {
+		return nil, err
+	}
+	return i.Session.doQuery(ctx, i.Name, f, opts...)
 }
 
 // DoCommand sends a session command request
 func (i *Instance) DoCommand(ctx context.Context, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) (interface{}, error) {
+	if err := i.ensureCreated(ctx); err != nil {
+		return nil, err
+	}
 	return i.Session.doCommand(ctx, i.Name, f)
 }
 
+// DoBatch sends a batch of session command requests
+// The commands are sent in order without interleaving other commands from the session, and the results are
+// returned in the order in which the commands were provided. A batch is not a transaction: if a command fails,
+// the commands that preceded it remain applied.
+func (i *Instance) DoBatch(ctx context.Context, fns []CommandFunc) ([]interface{}, error) {
+	if err := i.ensureCreated(ctx); err != nil {
+		return nil, err
+	}
+	return i.Session.doBatch(ctx, i.Name, fns)
+}
+
 // DoQueryStream sends a session query stream request
 func (i *Instance) DoQueryStream(
 	ctx context.Context,
 	f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error),
 	responseFunc func(interface{}) (*headers.ResponseHeader, interface{}, error)) (<-chan interface{}, error) {
+	if err := i.ensureCreated(ctx); err != nil {
+		return nil, err
+	}
 	return i.Session.doQueryStream(ctx, i.Name, f, responseFunc)
 }
 
@@ -73,6 +122,9 @@ func (i *Instance) DoCommandStream(
 	ctx context.Context,
 	f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error),
 	responseFunc func(interface{}) (*headers.ResponseHeader, interface{}, error)) (<-chan interface{}, error) {
+	if err := i.ensureCreated(ctx); err != nil {
+		return nil, err
+	}
 	return i.Session.doCommandStream(ctx, i.Name, f, responseFunc)
 }
 
@@ -81,12 +133,68 @@ func (i *Instance) create(ctx context.Context) error {
 	return i.handler.Create(ctx, i)
 }
 
+// ensureCreated creates the instance if its creation was deferred and it has not yet been created
+func (i *Instance) ensureCreated(ctx context.Context) error {
+	if !i.Session.lazy {
+		return nil
+	}
+	i.createMu.Lock()
+	defer i.createMu.Unlock()
+	if i.created {
+		return nil
+	}
+	if err := i.create(ctx); err != nil {
+		return err
+	}
+	i.created = true
+	return nil
+}
+
 // Close closes the instance
 func (i *Instance) Close(ctx context.Context) error {
-	return i.handler.Close(ctx, i)
+	i.setClosed()
+	i.createMu.Lock()
+	created := i.created
+	i.createMu.Unlock()
+	if !created {
+		return i.release()
+	}
+	err := i.handler.Close(ctx, i)
+	if releaseErr := i.release(); err == nil {
+		err = releaseErr
+	}
+	return err
 }
 
 // Delete deletes the instance
 func (i *Instance) Delete(ctx context.Context) error {
-	return i.handler.Delete(ctx, i)
+	if err := i.ensureCreated(ctx); err != nil {
+		return err
+	}
+	i.setClosed()
+	err := i.handler.Delete(ctx, i)
+	if releaseErr := i.release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// release releases the instance's reference to its session
+// If the session is shared, it's closed once the references of all its instances have been released.
+func (i *Instance) release() error {
+	var err error
+	i.releaseOnce.Do(func() {
+		err = i.Session.release()
+	})
+	return err
+}
+
+// setClosed marks the instance closed to prevent it being re-created when the session is reopened
+func (i *Instance) setClosed() {
+	i.mu.Lock()
+	i.closed = true
+	i.mu.Unlock()
+	if i.removeListener != nil {
+		i.removeListener()
+	}
 }
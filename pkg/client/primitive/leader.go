@@ -0,0 +1,72 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"github.com/lucasbfernandes/go-client/pkg/client/util/net"
+	"sync"
+)
+
+// LeaderCache caches the last known leader of each partition
+// Sessions record the leader of their partition when they're redirected to it, and new sessions for the
+// partition connect to the cached leader rather than the partition address to avoid being redirected again.
+// Implementations must be safe for concurrent use.
+type LeaderCache interface {
+	// GetLeader returns the last known leader of the given partition
+	GetLeader(partition Partition) (net.Address, bool)
+
+	// SetLeader records the leader of the given partition
+	SetLeader(partition Partition, leader net.Address)
+
+	// InvalidateLeader removes the cached leader of the given partition if it's the given leader
+	InvalidateLeader(partition Partition, leader net.Address)
+}
+
+// NewLeaderCache returns a new in-memory LeaderCache
+func NewLeaderCache() LeaderCache {
+	return &leaderCache{
+		leaders: make(map[Partition]net.Address),
+	}
+}
+
+// defaultLeaderCache is the leader cache shared by sessions created without WithLeaderCache
+var defaultLeaderCache = NewLeaderCache()
+
+// leaderCache is an in-memory LeaderCache
+type leaderCache struct {
+	leaders map[Partition]net.Address
+	mu      sync.RWMutex
+}
+
+func (c *leaderCache) GetLeader(partition Partition) (net.Address, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	leader, ok := c.leaders[partition]
+	return leader, ok
+}
+
+func (c *leaderCache) SetLeader(partition Partition, leader net.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leaders[partition] = leader
+}
+
+func (c *leaderCache) InvalidateLeader(partition Partition, leader net.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leaders[partition] == leader {
+		delete(c.leaders, partition)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"sync"
+	"time"
+)
+
+// NewSessionManager returns a SessionManager that sends keep-alives for its sessions at the given interval
+// The interval must be shorter than the timeout of each of the managed sessions. Unmanaged sessions send
+// keep-alives at half their timeout, which is a reasonable interval for the shortest managed session timeout.
+func NewSessionManager(interval time.Duration) *SessionManager {
+	if interval <= 0 {
+		panic("keep-alive interval must be positive")
+	}
+	manager := &SessionManager{
+		ticker:   time.NewTicker(interval),
+		sessions: make(map[*Session]bool),
+		closed:   make(chan struct{}),
+	}
+	go manager.run()
+	return manager
+}
+
+// SessionManager coordinates keep-alives for a set of sessions
+// Sessions created with WithSessionManager are kept alive by the manager on a single shared ticker rather
+// than each by its own ticker and goroutine. Keep-alives are sent concurrently, so a partition that is slow to
+// respond does not delay keep-alives for other sessions.
+type SessionManager struct {
+	ticker *time.Ticker
+	// sessions maps each managed session to whether a keep-alive is in progress for the session
+	sessions  map[*Session]bool
+	mu        sync.Mutex
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Len returns the number of sessions kept alive by the manager
+func (m *SessionManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// run sends keep-alives on each tick until the manager is closed
+func (m *SessionManager) run() {
+	for {
+		select {
+		case <-m.ticker.C:
+			m.heartbeat()
+		case <-m.closed:
+			return
+		}
+	}
+}
+
+// heartbeat sends a keep-alive for each session for which a keep-alive is not already in progress
+func (m *SessionManager) heartbeat() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for session, active := range m.sessions {
+		if active {
+			continue
+		}
+		m.sessions[session] = true
+		go func(session *Session) {
+			session.heartbeat()
+			m.mu.Lock()
+			if _, ok := m.sessions[session]; ok {
+				m.sessions[session] = false
+			}
+			m.mu.Unlock()
+		}(session)
+	}
+}
+
+// add adds the given session to the manager
+func (m *SessionManager) add(session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-m.closed:
+		return errors.NewUnavailable("session manager is closed")
+	default:
+	}
+	m.sessions[session] = false
+	return nil
+}
+
+// remove removes the given session from the manager
+func (m *SessionManager) remove(session *Session) {
+	m.mu.Lock()
+	delete(m.sessions, session)
+	m.mu.Unlock()
+}
+
+// Close stops sending keep-alives for the managed sessions
+// The sessions are not closed, but they will expire unless they're closed before their timeout. Sessions can
+// no longer be opened with the manager once it's closed.
+func (m *SessionManager) Close() {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		m.ticker.Stop()
+		close(m.closed)
+		m.mu.Unlock()
+	})
+}
@@ -17,7 +17,10 @@ package primitive
 import (
 	"context"
 	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/util/net"
+	"strings"
+	"unicode"
 )
 
 // Type is the type of a primitive
@@ -49,6 +52,41 @@ func (n Name) String() string {
 	return fmt.Sprintf("%s.%s.%s.%s", n.Namespace, n.Database, n.Scope, n.Name)
 }
 
+// Validate returns an Invalid error if the name is not a valid primitive name
+// The simple name of the primitive is required. The namespace, database and scope may be empty, but they may
+// not contain periods, which delimit the components of the qualified name, or whitespace or control characters.
+// The simple name may contain periods, since it's the last component of the qualified name.
+func (n Name) Validate() error {
+	if n.Name == "" {
+		return errors.New(errors.Invalid, "invalid primitive name %s: name is required", n)
+	}
+	components := []struct {
+		field string
+		value string
+	}{
+		{"namespace", n.Namespace},
+		{"database", n.Database},
+		{"scope", n.Scope},
+	}
+	for _, component := range components {
+		if strings.Contains(component.value, ".") {
+			return errors.New(errors.Invalid, "invalid primitive name %s: %s '%s' contains '.'", n, component.field, component.value)
+		}
+		if strings.IndexFunc(component.value, isInvalidNameRune) >= 0 {
+			return errors.New(errors.Invalid, "invalid primitive name %s: %s '%s' contains whitespace or control characters", n, component.field, component.value)
+		}
+	}
+	if strings.IndexFunc(n.Name, unicode.IsControl) >= 0 {
+		return errors.New(errors.Invalid, "invalid primitive name %s: name contains control characters", n)
+	}
+	return nil
+}
+
+// isInvalidNameRune returns whether the given rune is not allowed in the components of a name
+func isInvalidNameRune(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
 // Primitive is the base interface for primitives
 type Primitive interface {
 	// Name returns the fully namespaced primitive name
@@ -61,6 +99,14 @@ type Primitive interface {
 	Delete(ctx context.Context) error
 }
 
+// Sized is implemented by collection primitives that can report their size
+// The map, indexed map, list, set and log primitives implement Sized, so generic code over primitives can query
+// the size of a collection by asserting the primitive implements Sized.
+type Sized interface {
+	// Len returns the number of elements in the collection
+	Len(ctx context.Context) (int, error)
+}
+
 // Partition is the ID and address for a partition
 type Partition struct {
 	// ID is the partition identifier
@@ -0,0 +1,183 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive_test
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/counter"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/list"
+	"github.com/lucasbfernandes/go-client/pkg/client/log"
+	_map "github.com/lucasbfernandes/go-client/pkg/client/map"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/set"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNameValidate(t *testing.T) {
+	valid := []primitive.Name{
+		primitive.NewName("default", "test", "default", "test"),
+		primitive.NewName("default", "test", "default", "test.locks.foo bar"),
+		primitive.NewName("", "", "", "test"),
+		primitive.NewName("my-namespace", "my_database", "scope1", "test"),
+	}
+	for _, name := range valid {
+		assert.NoError(t, name.Validate(), name.String())
+	}
+
+	invalid := []primitive.Name{
+		primitive.NewName("default", "test", "default", ""),
+		primitive.NewName("default.test", "test", "default", "test"),
+		primitive.NewName("default", "test.db", "default", "test"),
+		primitive.NewName("default", "test", "default.", "test"),
+		primitive.NewName("default", "test db", "default", "test"),
+		primitive.NewName("default\n", "test", "default", "test"),
+		primitive.NewName("default", "test", "default", "test\x00"),
+	}
+	for _, name := range invalid {
+		err := name.Validate()
+		assert.Error(t, err, name.String())
+		assert.True(t, errors.IsInvalid(err), name.String())
+	}
+}
+
+func TestNewInstanceInvalidName(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	_, err = counter.New(context.TODO(), primitive.NewName("default.test", "test", "default", "test"), sessions)
+	assert.True(t, errors.IsInvalid(err))
+}
+
+func TestSized(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	m, err := _map.New(context.TODO(), primitive.NewName("default", "test", "default", "map"), sessions)
+	assert.NoError(t, err)
+	_, err = m.Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+
+	l, err := list.New(context.TODO(), primitive.NewName("default", "test", "default", "list"), sessions)
+	assert.NoError(t, err)
+	_, err = l.Append(context.TODO(), []byte("foo"))
+	assert.NoError(t, err)
+
+	st, err := set.New(context.TODO(), primitive.NewName("default", "test", "default", "set"), sessions)
+	assert.NoError(t, err)
+	_, err = st.Add(context.TODO(), "foo")
+	assert.NoError(t, err)
+
+	lg, err := log.New(context.TODO(), primitive.NewName("default", "test", "default", "log"), sessions)
+	assert.NoError(t, err)
+	_, err = lg.Append(context.TODO(), []byte("foo"))
+	assert.NoError(t, err)
+
+	// The size of each collection can be queried without knowing its type
+	for _, p := range []primitive.Primitive{m, l, st, lg} {
+		sized, ok := p.(primitive.Sized)
+		assert.True(t, ok, p.Name().String())
+		size, err := sized.Len(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, size, p.Name().String())
+	}
+
+	c, err := counter.New(context.TODO(), primitive.NewName("default", "test", "default", "counter"), sessions)
+	assert.NoError(t, err)
+	_, ok := c.(primitive.Sized)
+	assert.False(t, ok)
+}
+
+// closeFailingPrimitive is a primitive that fails to close
+type closeFailingPrimitive struct {
+	name  primitive.Name
+	err   error
+	block chan struct{}
+}
+
+func (p *closeFailingPrimitive) Name() primitive.Name {
+	return p.name
+}
+
+func (p *closeFailingPrimitive) Close(ctx context.Context) error {
+	if p.block != nil {
+		<-p.block
+	}
+	return p.err
+}
+
+func (p *closeFailingPrimitive) Delete(ctx context.Context) error {
+	return nil
+}
+
+func TestCloseAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	c1, err := counter.New(context.TODO(), primitive.NewName("default", "test", "default", "counter1"), sessions)
+	assert.NoError(t, err)
+	c2, err := counter.New(context.TODO(), primitive.NewName("default", "test", "default", "counter2"), sessions)
+	assert.NoError(t, err)
+
+	assert.NoError(t, primitive.CloseAll(context.TODO()))
+
+	failing := &closeFailingPrimitive{
+		name: primitive.NewName("default", "test", "default", "failing"),
+		err:  errors.NewUnavailable("failed"),
+	}
+	err = primitive.CloseAll(context.TODO(), c1, failing, c2)
+	assert.Error(t, err)
+	closeErr, ok := err.(*primitive.CloseAllError)
+	assert.True(t, ok)
+	assert.Len(t, closeErr.Errors, 1)
+	assert.True(t, errors.IsUnavailable(closeErr.Errors[1]))
+	assert.Contains(t, err.Error(), "failing")
+
+	// The primitives that did not fail were closed
+	_, err = c1.Get(context.TODO())
+	assert.Equal(t, counter.ErrClosed, err)
+	_, err = c2.Get(context.TODO())
+	assert.Equal(t, counter.ErrClosed, err)
+
+	// Primitives that have not closed by the deadline are reported as timed out
+	blocking := &closeFailingPrimitive{
+		name:  primitive.NewName("default", "test", "default", "blocking"),
+		block: make(chan struct{}),
+	}
+	defer close(blocking.block)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = primitive.CloseAll(ctx, failing, blocking)
+	closeErr, ok = err.(*primitive.CloseAllError)
+	assert.True(t, ok)
+	assert.Len(t, closeErr.Errors, 2)
+	assert.True(t, errors.IsUnavailable(closeErr.Errors[0]))
+	assert.True(t, errors.IsTimeout(closeErr.Errors[1]))
+}
@@ -0,0 +1,67 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/util/net"
+	"math"
+	"time"
+)
+
+// ReconnectStrategy determines how a session retries failed requests and to which address it connects
+// Sessions call the strategy when a request fails, when a request or stream is redirected to the leader of
+// the partition, and when the session fails to connect to its partition. Implementations must be safe for
+// concurrent use.
+type ReconnectStrategy interface {
+	// ShouldRetry returns whether a request that failed with the given error should be retried
+	// attempt is the number of times the request has failed, including the given failure.
+	ShouldRetry(attempt int, err error) bool
+
+	// NextDelay returns the delay before a request that failed the given number of times is retried
+	NextDelay(attempt int) time.Duration
+
+	// PickAddress returns the address to which the session should connect
+	// If the session was redirected to the given leader, err is nil. Otherwise, the session failed to connect
+	// to the given current address with the given error and leader is empty. The partition's address is the
+	// address with which the session was created.
+	PickAddress(partition Partition, current net.Address, leader net.Address, err error) net.Address
+}
+
+// DefaultReconnectStrategy returns the default ReconnectStrategy
+// The default strategy retries requests until they're canceled, waiting at least one second between attempts.
+// Sessions connect to the leader to which they're redirected and fall back to the partition address when they
+// fail to connect to the leader.
+func DefaultReconnectStrategy() ReconnectStrategy {
+	return defaultReconnectStrategy{}
+}
+
+// defaultReconnectStrategy is the default ReconnectStrategy
+type defaultReconnectStrategy struct{}
+
+func (s defaultReconnectStrategy) ShouldRetry(attempt int, err error) bool {
+	return err != context.Canceled
+}
+
+func (s defaultReconnectStrategy) NextDelay(attempt int) time.Duration {
+	return time.Duration(math.Max(math.Pow(float64(attempt-1), 2), 1000)) * time.Millisecond
+}
+
+func (s defaultReconnectStrategy) PickAddress(partition Partition, current net.Address, leader net.Address, err error) net.Address {
+	if leader != "" {
+		return leader
+	}
+	return partition.Address
+}
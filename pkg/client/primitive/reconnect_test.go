@@ -0,0 +1,41 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestDefaultReconnectStrategy(t *testing.T) {
+	strategy := DefaultReconnectStrategy()
+
+	assert.True(t, strategy.ShouldRetry(1, status.Error(codes.Unavailable, "unavailable")))
+	assert.True(t, strategy.ShouldRetry(100, status.Error(codes.Internal, "internal")))
+	assert.False(t, strategy.ShouldRetry(1, context.Canceled))
+
+	assert.Equal(t, time.Second, strategy.NextDelay(1))
+	assert.Equal(t, time.Second, strategy.NextDelay(10))
+	assert.Equal(t, 2500*time.Millisecond, strategy.NextDelay(51))
+
+	partition := Partition{ID: 1, Address: "partition:5678"}
+	assert.Equal(t, partition.Address, strategy.PickAddress(partition, "leader:5678", "", status.Error(codes.Unavailable, "unavailable")))
+	assert.Equal(t, partition.Address, strategy.PickAddress(partition, partition.Address, "", status.Error(codes.Unavailable, "unavailable")))
+	assert.Equal(t, "leader:5678", string(strategy.PickAddress(partition, partition.Address, "leader:5678", nil)))
+}
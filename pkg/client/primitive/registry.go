@@ -0,0 +1,92 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"context"
+	"fmt"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"sort"
+	"sync"
+)
+
+// Constructor creates a primitive of a specific type
+// The options are specific to the primitive type. A constructor returns an Invalid error if an option is not
+// an option for its primitive type.
+type Constructor func(ctx context.Context, name Name, sessions []*Session, opts ...interface{}) (Primitive, error)
+
+// NewRegistry creates a new primitive registry
+func NewRegistry() *Registry {
+	return &Registry{
+		constructors: make(map[Type]Constructor),
+	}
+}
+
+// Registry is a registry of primitive constructors by primitive type
+type Registry struct {
+	constructors map[Type]Constructor
+	mu           sync.RWMutex
+}
+
+// Register registers the constructor for the given primitive type
+// Register panics if a constructor is already registered for the type.
+func (r *Registry) Register(primitiveType Type, constructor Constructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.constructors[primitiveType]; ok {
+		panic(fmt.Sprintf("primitive type %s is already registered", primitiveType))
+	}
+	r.constructors[primitiveType] = constructor
+}
+
+// Types returns the registered primitive types in sorted order
+func (r *Registry) Types() []Type {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]Type, 0, len(r.constructors))
+	for primitiveType := range r.constructors {
+		types = append(types, primitiveType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// Create creates a primitive of the given type
+// If no constructor is registered for the type, a NotSupported error is returned.
+func (r *Registry) Create(ctx context.Context, primitiveType Type, name Name, sessions []*Session, opts ...interface{}) (Primitive, error) {
+	r.mu.RLock()
+	constructor, ok := r.constructors[primitiveType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, errors.New(errors.NotSupported, "primitive type %s is not registered", primitiveType)
+	}
+	return constructor(ctx, name, sessions, opts...)
+}
+
+// DefaultRegistry is the registry with which the primitive packages register their constructors
+// Each primitive package registers its constructor when it's imported.
+var DefaultRegistry = NewRegistry()
+
+// Register registers the constructor for the given primitive type with the default registry
+func Register(primitiveType Type, constructor Constructor) {
+	DefaultRegistry.Register(primitiveType, constructor)
+}
+
+// Create creates a primitive of the given type using the default registry
+func Create(ctx context.Context, primitiveType Type, name Name, sessions []*Session, opts ...interface{}) (Primitive, error) {
+	return DefaultRegistry.Create(ctx, primitiveType, name, sessions, opts...)
+}
@@ -0,0 +1,77 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive_test
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/counter"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/list"
+	_map "github.com/lucasbfernandes/go-client/pkg/client/map"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	assert.Contains(t, primitive.DefaultRegistry.Types(), counter.Type)
+	assert.Contains(t, primitive.DefaultRegistry.Types(), list.Type)
+	assert.Contains(t, primitive.DefaultRegistry.Types(), _map.Type)
+
+	name := primitive.NewName("default", "test", "default", "counter")
+	p, err := primitive.Create(context.TODO(), counter.Type, name, sessions)
+	assert.NoError(t, err)
+	c, ok := p.(counter.Counter)
+	assert.True(t, ok)
+	value, err := c.Increment(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	_, err = primitive.Create(context.TODO(), counter.Type, name, sessions, list.WithElementIDs())
+	assert.Error(t, err)
+	assert.True(t, errors.IsInvalid(err))
+
+	name = primitive.NewName("default", "test", "default", "list")
+	p, err = primitive.Create(context.TODO(), list.Type, name, sessions, list.WithElementIDs())
+	assert.NoError(t, err)
+	_, ok = p.(list.List)
+	assert.True(t, ok)
+
+	_, err = primitive.Create(context.TODO(), list.Type, name, sessions, _map.WithCache(10))
+	assert.Error(t, err)
+	assert.True(t, errors.IsInvalid(err))
+
+	_, err = primitive.Create(context.TODO(), "Unknown", name, sessions)
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotSupported(err))
+
+	registry := primitive.NewRegistry()
+	constructor := func(ctx context.Context, name primitive.Name, sessions []*primitive.Session, opts ...interface{}) (primitive.Primitive, error) {
+		return nil, nil
+	}
+	registry.Register("Foo", constructor)
+	assert.Equal(t, []primitive.Type{"Foo"}, registry.Types())
+	assert.Panics(t, func() {
+		registry.Register("Foo", constructor)
+	})
+}
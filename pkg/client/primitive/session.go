@@ -23,12 +23,19 @@ import (
 	"github.com/google/uuid"
 	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/util/net"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
-	"math"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"math/rand"
 	"sync"
 	"time"
 )
 
+// CommandFunc is a function that sends a single command to the given connection using the given header
+type CommandFunc func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)
+
 // SessionOption implements a session option
 type SessionOption interface {
 	prepare(options *sessionOptions)
@@ -47,11 +54,452 @@ func (o sessionTimeoutOption) prepare(options *sessionOptions) {
 	options.timeout = o.timeout
 }
 
+// WithRateLimit returns a session SessionOption to limit the rate at which the session issues commands
+// Commands are limited by a token bucket that allows rps commands per second with bursts of up to burst
+// commands. A command that exceeds the limit blocks until it's allowed to proceed. If the command's context
+// is done or its deadline would be exceeded before the command is allowed to proceed, ErrRateLimited is
+// returned. Queries are not limited.
+func WithRateLimit(rps int, burst int) SessionOption {
+	return sessionRateLimitOption{
+		rps:   rps,
+		burst: burst,
+	}
+}
+
+type sessionRateLimitOption struct {
+	rps   int
+	burst int
+}
+
+func (o sessionRateLimitOption) prepare(options *sessionOptions) {
+	options.limiter = rate.NewLimiter(rate.Limit(o.rps), o.burst)
+}
+
+// WithEagerConnect returns a session SessionOption to connect to the partition when the session is created
+// The session waits for the connection to the partition to become ready before opening the session, and
+// NewSession fails with an Unavailable error if the partition cannot be reached.
+func WithEagerConnect() SessionOption {
+	return sessionEagerConnectOption{}
+}
+
+type sessionEagerConnectOption struct{}
+
+func (o sessionEagerConnectOption) prepare(options *sessionOptions) {
+	options.eagerConnect = true
+}
+
+// WithConfirmKeepAlive returns a session SessionOption to confirm the session is alive when it's opened
+// Once the session has been opened, a keep-alive is sent synchronously rather than waiting for the first
+// keep-alive to be sent in the background. If the keep-alive fails, the session is closed and opening the
+// session fails with the keep-alive error.
+func WithConfirmKeepAlive() SessionOption {
+	return sessionConfirmKeepAliveOption{}
+}
+
+type sessionConfirmKeepAliveOption struct{}
+
+func (o sessionConfirmKeepAliveOption) prepare(options *sessionOptions) {
+	options.confirmKeepAlive = true
+}
+
+// WithSessionManager returns a session SessionOption to keep the session alive with the given SessionManager
+// Rather than sending keep-alives on its own ticker, the session is kept alive by the manager once it has been
+// opened, and it's removed from the manager when it's closed. The manager's interval must be shorter than
+// the session timeout. If the manager is closed, the session cannot be opened.
+func WithSessionManager(manager *SessionManager) SessionOption {
+	return &sessionManagerOption{
+		manager: manager,
+	}
+}
+
+type sessionManagerOption struct {
+	manager *SessionManager
+}
+
+func (o *sessionManagerOption) prepare(options *sessionOptions) {
+	options.manager = o.manager
+}
+
+// WithCallOptions returns a session SessionOption to apply the given gRPC call options to every RPC sent by
+// the session
+// The options are applied to the session's connections as default call options, so they apply to the session's
+// own requests, e.g. keep-alives, as well as to the operations of primitives created with the session. This
+// can be used e.g. to raise the maximum message size for large payloads with grpc.MaxCallRecvMsgSize, or to
+// wait for a connection rather than failing fast with grpc.WaitForReady. Options passed to WithCallOptions
+// more than once are accumulated.
+func WithCallOptions(opts ...grpc.CallOption) SessionOption {
+	return sessionCallOptionsOption{
+		opts: opts,
+	}
+}
+
+type sessionCallOptionsOption struct {
+	opts []grpc.CallOption
+}
+
+func (o sessionCallOptionsOption) prepare(options *sessionOptions) {
+	options.callOpts = append(options.callOpts, o.opts...)
+}
+
+// WithLazyOpen returns a session SessionOption to defer opening the session until it's first used
+// NewSession returns without contacting the partition, and primitive instances created with the session are
+// not created on the partition until their first operation. The first operation opens the session, retrying
+// until the operation's context is done, and fails with an Unavailable error if the session cannot be opened.
+// Concurrent first operations open the session once. WithLazyOpen takes precedence over WithEagerConnect.
+func WithLazyOpen() SessionOption {
+	return sessionLazyOpenOption{}
+}
+
+type sessionLazyOpenOption struct{}
+
+func (o sessionLazyOpenOption) prepare(options *sessionOptions) {
+	options.lazy = true
+}
+
+// WithLeaderCache returns a session SessionOption to configure the cache of partition leaders
+// The session connects to the cached leader of its partition, if any, rather than the partition address, and
+// records the leader in the cache when it's redirected to it. If the session fails to connect to the leader,
+// the leader is removed from the cache and the session falls back to the partition address. By default,
+// sessions share an in-memory cache.
+func WithLeaderCache(cache LeaderCache) SessionOption {
+	return sessionLeaderCacheOption{cache: cache}
+}
+
+type sessionLeaderCacheOption struct {
+	cache LeaderCache
+}
+
+func (o sessionLeaderCacheOption) prepare(options *sessionOptions) {
+	options.leaders = o.cache
+}
+
+// WithReconnectStrategy returns a session SessionOption to configure how the session retries failed requests
+// and to which address it connects
+// By default, sessions use the DefaultReconnectStrategy.
+func WithReconnectStrategy(strategy ReconnectStrategy) SessionOption {
+	return sessionReconnectStrategyOption{strategy: strategy}
+}
+
+type sessionReconnectStrategyOption struct {
+	strategy ReconnectStrategy
+}
+
+func (o sessionReconnectStrategyOption) prepare(options *sessionOptions) {
+	options.strategy = o.strategy
+}
+
+// WithStreamPolicy returns a session SessionOption to configure how command stream responses that are received
+// out of order are delivered
+// The policy applies to command streams, e.g. watches, opened by the session. By default, sessions use DropLate.
+func WithStreamPolicy(policy StreamPolicy) SessionOption {
+	return sessionStreamPolicyOption{policy: policy}
+}
+
+type sessionStreamPolicyOption struct {
+	policy StreamPolicy
+}
+
+func (o sessionStreamPolicyOption) prepare(options *sessionOptions) {
+	options.streamPolicy = o.policy
+}
+
+// WithStreamBuffer returns a session SessionOption to buffer up to the given number of responses on each stream
+// opened by the session
+// By default, stream responses are not buffered, and the stream is not read while a response is waiting to be
+// consumed. When a stream is closed cleanly by the partition, the responses already received are delivered before
+// the stream's channel is closed. If the stream's context is canceled, the buffered responses are dropped.
+func WithStreamBuffer(size int) SessionOption {
+	if size <= 0 {
+		panic("stream buffer size must be positive")
+	}
+	return sessionStreamBufferOption{size: size}
+}
+
+type sessionStreamBufferOption struct {
+	size int
+}
+
+func (o sessionStreamBufferOption) prepare(options *sessionOptions) {
+	options.streamBuffer = o.size
+}
+
+// WithRetryBackoff returns a session SessionOption to configure the delay between attempts of a request that is
+// redirected to the leader of the partition
+// A request redirected by a partition that is not the leader is retried against the leader named in the response
+// immediately, but if the request is redirected again, e.g. while the partition is electing a leader, the session
+// waits before each further attempt. The delay starts at base and doubles with each redirect up to max, and is
+// randomized between half and all of that delay so clients redirected at the same time do not retry in lockstep.
+// The delay is reset for each request, and the request fails with the context's error if its context is done
+// while waiting. By default, the delay starts at 10ms and is at most one second. Requests that fail are retried
+// as determined by the session's ReconnectStrategy.
+func WithRetryBackoff(base, max time.Duration) SessionOption {
+	if base <= 0 {
+		panic("retry backoff base must be positive")
+	}
+	if max < base {
+		panic("retry backoff max must not be less than base")
+	}
+	return sessionRetryBackoffOption{backoff: retryBackoff{base: base, max: max}}
+}
+
+type sessionRetryBackoffOption struct {
+	backoff retryBackoff
+}
+
+func (o sessionRetryBackoffOption) prepare(options *sessionOptions) {
+	options.backoff = o.backoff
+}
+
+// retryBackoff computes the delays between redirected attempts of a request
+type retryBackoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+// defaultRetryBackoff is the retry backoff of sessions created without WithRetryBackoff
+var defaultRetryBackoff = retryBackoff{
+	base: 10 * time.Millisecond,
+	max:  time.Second,
+}
+
+// delay returns the delay before the given retry of a request, starting at 1
+func (b retryBackoff) delay(retry int) time.Duration {
+	delay := b.base
+	for i := 1; i < retry && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		delay = b.max
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// WithReplicas returns a session SessionOption to configure the addresses of the partition's replicas
+// Queries that allow stale reads, e.g. with WithStaleRead, are sent to the replicas when the leader of the
+// partition cannot be reached. Commands are always sent to the leader.
+func WithReplicas(replicas ...net.Address) SessionOption {
+	return sessionReplicasOption{replicas: replicas}
+}
+
+type sessionReplicasOption struct {
+	replicas []net.Address
+}
+
+func (o sessionReplicasOption) prepare(options *sessionOptions) {
+	options.replicas = o.replicas
+}
+
+// WithMaxMissedKeepAlives returns a session SessionOption to expire the session after the given number of
+// consecutive missed keep-alives
+// A keep-alive is missed if it fails or does not complete within half the session timeout. Once n consecutive
+// keep-alives have been missed, the session expires: the expire listeners are called, the Done channel is
+// closed, and operations fail with ErrSessionExpired rather than being retried until the session is reopened.
+// A successful keep-alive resets the count. Regardless of this option, the session expires if the partition
+// rejects a keep-alive or if no keep-alive succeeds within the session timeout.
+func WithMaxMissedKeepAlives(n int) SessionOption {
+	if n <= 0 {
+		panic("max missed keep-alives must be positive")
+	}
+	return sessionMaxMissedKeepAlivesOption{n: n}
+}
+
+type sessionMaxMissedKeepAlivesOption struct {
+	n int
+}
+
+func (o sessionMaxMissedKeepAlivesOption) prepare(options *sessionOptions) {
+	options.maxMissedKeepAlives = o.n
+}
+
+// WithInitialIndex returns a session SessionOption to seed the index observed by the session
+// Requests sent by the session carry the highest index the session has observed, and the partition does not
+// execute a read until it has applied that index, so reads observe all the writes previously observed by the
+// session. Seeding the index of a new session with the LastIndex of another session extends this guarantee
+// across sessions: reads on the new session observe at least the writes observed by the other session when
+// the index was read. Indexes are assigned per partition, so the index must be read from a session of the same
+// partition; an index from another partition's session is meaningless and may delay the session's reads. The
+// index is applied once the session has been opened, and again whenever the session is reopened.
+func WithInitialIndex(index uint64) SessionOption {
+	return sessionInitialIndexOption{index: index}
+}
+
+type sessionInitialIndexOption struct {
+	index uint64
+}
+
+func (o sessionInitialIndexOption) prepare(options *sessionOptions) {
+	options.initialIndex = o.index
+}
+
+// WithHeaderInterceptor returns a session SessionOption to call the given function with each outgoing request header
+// The function is called once for each request sent by the session, including keep-alives and the requests that
+// open and close the session, once the header has been built and before the request is sent. A request that is
+// retried is sent with the same header, so the function is not called again. The header is sent as modified by
+// the function, but the session relies on the session ID, request ID, index and streams to order requests, so
+// changing them may cause requests to be rejected or executed out of order; the stream headers may be shared
+// with other requests and must not be modified. The function is called without holding the session's locks, so
+// it may call the session's methods, and it may be called concurrently by requests sent concurrently.
+func WithHeaderInterceptor(f func(header *headers.RequestHeader)) SessionOption {
+	return sessionHeaderInterceptorOption{f: f}
+}
+
+type sessionHeaderInterceptorOption struct {
+	f func(header *headers.RequestHeader)
+}
+
+func (o sessionHeaderInterceptorOption) prepare(options *sessionOptions) {
+	options.interceptor = o.f
+}
+
+// IDGenerator generates the IDs of new sessions
+type IDGenerator func() string
+
+// defaultIDGenerator generates random UUIDs
+func defaultIDGenerator() string {
+	return uuid.New().String()
+}
+
+// WithIDGenerator returns a session SessionOption to generate the session's ID with the given generator
+// The generator is called once when the session is created. By default, the ID is a random (version 4) UUID.
+// A custom generator can produce deterministic IDs for tests, or IDs in another format, e.g. ULIDs or IDs
+// embedding the host name. The generator is responsible for the uniqueness of the IDs it generates.
+func WithIDGenerator(generator IDGenerator) SessionOption {
+	return sessionIDGeneratorOption{generator: generator}
+}
+
+type sessionIDGeneratorOption struct {
+	generator IDGenerator
+}
+
+func (o sessionIDGeneratorOption) prepare(options *sessionOptions) {
+	options.idGenerator = o.generator
+}
+
 type sessionOptions struct {
-	id      string
-	timeout time.Duration
+	idGenerator      IDGenerator
+	timeout          time.Duration
+	limiter          *rate.Limiter
+	eagerConnect     bool
+	confirmKeepAlive bool
+	manager          *SessionManager
+	lazy             bool
+	leaders          LeaderCache
+	strategy         ReconnectStrategy
+	streamPolicy     StreamPolicy
+	streamBuffer     int
+	replicas         []net.Address
+	backoff          retryBackoff
+	callOpts         []grpc.CallOption
+	initialIndex     uint64
+	interceptor      func(header *headers.RequestHeader)
+	// maxMissedKeepAlives is the number of consecutive missed keep-alives after which the session expires
+	maxMissedKeepAlives int
+}
+
+// QueryOption implements an option for a session query
+type QueryOption interface {
+	prepare(options *queryOptions)
+}
+
+// WithStaleRead returns a QueryOption that allows the query to be served by a replica of the partition when the
+// leader cannot be reached
+// If the session was created WithReplicas and the query fails because the leader is unreachable, the query is
+// sent to each replica in turn. A replica's response is rejected if its index is more than maxLag indexes behind
+// the last index observed by the session, and if maxLag is 0, the staleness of the response is not bounded. If no
+// replica serves the query, it's retried against the leader. Replicas may not have applied the session's own
+// writes, so the query should not require them, e.g. by setting the header's index to 0 or by using WithConsistency
+// with Eventual or BoundedStaleness instead. Commands are always sent to the leader.
+func WithStaleRead(maxLag uint64) QueryOption {
+	return queryStaleReadOption{maxLag: maxLag}
+}
+
+type queryStaleReadOption struct {
+	maxLag uint64
+}
+
+func (o queryStaleReadOption) prepare(options *queryOptions) {
+	options.stale = true
+	options.bounded = o.maxLag > 0
+	options.maxLag = o.maxLag
+}
+
+// Consistency is the consistency level of a query
+type Consistency struct {
+	level  consistencyLevel
+	maxLag uint64
+}
+
+type consistencyLevel int
+
+const (
+	sequential consistencyLevel = iota
+	linearizable
+	boundedStaleness
+	eventual
+)
+
+var (
+	// Sequential queries observe all writes previously observed by the session
+	// Sequential is the default consistency level. A sequential query that fails mid-flight is retried as soon as
+	// the session's connection is ready again.
+	Sequential = Consistency{level: sequential}
+
+	// Linearizable queries observe all writes completed before the query is sent
+	// Partitions serve every query from the leader once it has applied the session's writes, so linearizable
+	// queries are sent like sequential queries, but a linearizable query that fails mid-flight is only retried
+	// as determined by the session's reconnect strategy.
+	Linearizable = Consistency{level: linearizable}
+
+	// Eventual queries are served as soon as they're received and may not observe the session's own writes
+	// The query header omits the session's index and sequence number. If the session was created WithReplicas,
+	// the query is served by a replica when the leader cannot be reached.
+	Eventual = Consistency{level: eventual}
+)
+
+// BoundedStaleness returns the consistency level of queries that may lag the session by up to maxLag indexes
+// The query header omits the session's index and sequence number. If the session was created WithReplicas, the
+// query is served by a replica when the leader cannot be reached, as long as the replica's index is no more than
+// maxLag indexes behind the last index observed by the session.
+func BoundedStaleness(maxLag uint64) Consistency {
+	return Consistency{level: boundedStaleness, maxLag: maxLag}
+}
+
+// WithConsistency returns a QueryOption that sets the consistency level of the query
+func WithConsistency(consistency Consistency) QueryOption {
+	return queryConsistencyOption{consistency: consistency}
+}
+
+type queryConsistencyOption struct {
+	consistency Consistency
+}
+
+func (o queryConsistencyOption) prepare(options *queryOptions) {
+	options.consistency = o.consistency
+	switch o.consistency.level {
+	case eventual:
+		options.stale = true
+		options.bounded = false
+	case boundedStaleness:
+		options.stale = true
+		options.bounded = true
+		options.maxLag = o.consistency.maxLag
+	}
 }
 
+type queryOptions struct {
+	consistency Consistency
+	stale       bool
+	bounded     bool
+	maxLag      uint64
+}
+
+// ErrSessionExpired is returned by operations on a session that has expired
+var ErrSessionExpired = errors.NewUnavailable("session expired")
+
+// ErrRateLimited is returned when a command cannot be sent within the session's rate limit
+var ErrRateLimited = errors.NewUnavailable("rate limit exceeded")
+
 // MetadataOption implements a session metadata option
 type MetadataOption interface {
 	apply(options *metadataOptions)
@@ -97,42 +545,243 @@ type metadataOptions struct {
 // handler is the primitive's session handler
 func NewSession(ctx context.Context, partition Partition, opts ...SessionOption) (*Session, error) {
 	options := &sessionOptions{
-		id:      uuid.New().String(),
-		timeout: 30 * time.Second,
+		idGenerator: defaultIDGenerator,
+		timeout:     30 * time.Second,
+		leaders:     defaultLeaderCache,
+		strategy:    DefaultReconnectStrategy(),
+		backoff:     defaultRetryBackoff,
 	}
 	for i := range opts {
 		opts[i].prepare(options)
 	}
 	session := &Session{
-		Partition: partition.ID,
-		conns:     net.NewConns(partition.Address),
-		Timeout:   options.timeout,
-		streams:   make(map[uint64]*Stream),
-		mu:        sync.RWMutex{},
-		ticker:    time.NewTicker(options.timeout / 2),
+		id:          options.idGenerator(),
+		Partition:   partition.ID,
+		address:     partition.Address,
+		leaders:     options.leaders,
+		strategy:    options.strategy,
+		backoff:     options.backoff,
+		policy:      options.streamPolicy,
+		buffer:      options.streamBuffer,
+		conns:       newConns(partition.Address, options.callOpts),
+		replicas:    newReplicaConns(options.replicas, options.callOpts),
+		Timeout:     options.timeout,
+		streams:     make(map[uint64]*Stream),
+		mu:          sync.RWMutex{},
+		manager:     options.manager,
+		closed:      make(chan struct{}),
+		done:        make(chan struct{}),
+		limiter:     options.limiter,
+		lazy:        options.lazy,
+		confirm:     options.confirmKeepAlive,
+		initial:     options.initialIndex,
+		maxMissed:   options.maxMissedKeepAlives,
+		interceptor: options.interceptor,
+	}
+	if session.manager == nil {
+		session.ticker = time.NewTicker(options.timeout / 2)
+	}
+	if leader, ok := session.leaders.GetLeader(partition); ok {
+		session.conns.Reconnect(leader)
+	}
+	if options.lazy {
+		return session, nil
+	}
+	if options.eagerConnect {
+		if err := session.waitForReady(ctx); err != nil {
+			session.stopKeepAlive()
+			_ = session.conns.Close()
+			return nil, errors.NewUnavailable(err.Error())
+		}
 	}
 	if err := session.open(ctx); err != nil {
+		session.stopKeepAlive()
+		_ = session.conns.Close()
+		return nil, err
+	}
+	session.opened = true
+	return session, nil
+}
+
+// newReplicaConns returns a connection manager for each of the given replica addresses
+func newReplicaConns(replicas []net.Address, callOpts []grpc.CallOption) []*net.Conns {
+	conns := make([]*net.Conns, len(replicas))
+	for i, replica := range replicas {
+		conns[i] = newConns(replica, callOpts)
+	}
+	return conns
+}
+
+// newConns returns a connection manager for the given address that applies the given call options to every RPC
+func newConns(address net.Address, callOpts []grpc.CallOption) *net.Conns {
+	if len(callOpts) == 0 {
+		return net.NewConns(address)
+	}
+	return net.NewConns(address, grpc.WithDefaultCallOptions(callOpts...))
+}
+
+// NewSessionWithContext creates a new Session for the given partition that is bound to the given context
+// The context bounds the session's open, and the session is closed automatically when the context is
+// cancelled. The session may still be closed explicitly by calling Close, in which case it will not be closed
+// again when the context is cancelled. The context is released once the session is closed or expires.
+func NewSessionWithContext(ctx context.Context, partition Partition, opts ...SessionOption) (*Session, error) {
+	session, err := NewSession(ctx, partition, opts...)
+	if err != nil {
 		return nil, err
 	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Close()
+		case <-session.Done():
+		}
+	}()
 	return session, nil
 }
 
+// waitForReady waits for the connection to the partition to become ready
+// If the session fails to connect, the session reconnects to the address picked by its reconnect strategy and
+// waits for the new connection to become ready.
+func (s *Session) waitForReady(ctx context.Context) error {
+	err := s.conns.WaitForReady(ctx)
+	if err != nil && ctx.Err() == nil {
+		current := s.conns.Leader()
+		s.reconnect("", err)
+		if s.conns.Leader() != current {
+			return s.conns.WaitForReady(ctx)
+		}
+	}
+	return err
+}
+
 // Session maintains the session for a primitive
 type Session struct {
 	Partition  int
 	Timeout    time.Duration
 	SessionID  uint64
+	id         string
+	address    net.Address
+	leaders    LeaderCache
+	strategy   ReconnectStrategy
+	backoff    retryBackoff
+	policy     StreamPolicy
+	buffer     int
 	conns      *net.Conns
+	replicas   []*net.Conns
 	lastIndex  uint64
+	initial    uint64
 	requestID  uint64
 	responseID uint64
 	streams    map[uint64]*Stream
 	mu         sync.RWMutex
-	ticker     *time.Ticker
+	// headerMu is held while command headers are assigned, so a batch is assigned consecutive sequence numbers
+	// and a reopen never races with the assignment of a header
+	headerMu sync.RWMutex
+	// streamHeaders caches the stream headers and is nil when the headers must be rebuilt
+	streamHeaders []headers.StreamHeader
+	// streamAcks holds the response IDs of the streams acknowledged by the last successful keep-alive
+	streamAcks      map[uint64]uint64
+	streamHeadersMu sync.Mutex
+	ticker          *time.Ticker
+	manager         *SessionManager
+	closeOnce       sync.Once
+	closeErr        error
+	closed          chan struct{}
+	done            chan struct{}
+	doneOnce        sync.Once
+	limiter         *rate.Limiter
+	listeners       []*reopenListener
+	lazy            bool
+	confirm         bool
+	openMu          sync.Mutex
+	opened          bool
+	lastKeepAlive   time.Time
+	expired         bool
+	maxMissed       int
+	missed          int
+	expireListeners []*expireListener
+	streamListeners []*streamErrorListener
+	shared          bool
+	refs            int
+	interceptor     func(header *headers.RequestHeader)
+}
+
+// reopenListener is a listener for session reopen events
+type reopenListener struct {
+	f func(ctx context.Context)
+}
+
+// expireListener is a listener for session expiration events
+type expireListener struct {
+	f func()
 }
 
 // open creates the session and begins keep-alives
 func (s *Session) open(ctx context.Context) error {
+	if err := s.openSession(ctx); err != nil {
+		return err
+	}
+	if s.confirm {
+		if err := s.keepAlive(ctx); err != nil {
+			_ = s.close(ctx)
+			return err
+		}
+	}
+
+	if s.manager != nil {
+		if err := s.manager.add(s); err != nil {
+			_ = s.close(ctx)
+			return err
+		}
+		return nil
+	}
+
+	go func() {
+		for range s.ticker.C {
+			s.heartbeat()
+		}
+	}()
+	return nil
+}
+
+// stopKeepAlive stops sending keep-alives for the session
+func (s *Session) stopKeepAlive() {
+	if s.manager != nil {
+		s.manager.remove(s)
+	} else {
+		s.ticker.Stop()
+	}
+}
+
+// ensureOpen opens the session if it was created lazily and has not yet been opened
+// If the session has expired, ErrSessionExpired is returned.
+func (s *Session) ensureOpen(ctx context.Context) error {
+	if s.isExpired() {
+		return ErrSessionExpired
+	}
+	if !s.lazy {
+		return nil
+	}
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
+	if s.opened {
+		return nil
+	}
+	select {
+	case <-s.closed:
+		return errors.NewUnavailable("session is closed")
+	default:
+	}
+	if err := s.open(ctx); err != nil {
+		return errors.NewUnavailable(fmt.Sprintf("failed to open session: %s", err))
+	}
+	s.opened = true
+	return nil
+}
+
+// openSession sends a request to open a new session
+func (s *Session) openSession(ctx context.Context) error {
+	opened := time.Now()
 	err := s.doSession(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		request := &api.OpenSessionRequest{
 			Header:  header,
@@ -148,18 +797,290 @@ func (s *Session) open(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	s.mu.Lock()
+	s.lastKeepAlive = opened
+	s.expired = false
+	s.missed = 0
+	// The initial index is applied once the session has been opened, since the session is initialized by
+	// the first response with an index greater than the last index
+	if s.initial > s.lastIndex {
+		s.lastIndex = s.initial
+	}
+	s.mu.Unlock()
+	return nil
+}
 
-	go func() {
-		for range s.ticker.C {
-			_ = s.keepAlive(context.TODO())
+// Reopen replaces the session with a new session
+// The state of the current session is discarded and a new session is opened on the partition. Once the new
+// session has been opened, reopen listeners are called in the order in which they were added. Listeners
+// added by primitive instances re-create the instances in the new session, so listeners added after an
+// instance has been created are called after the instance has been re-created.
+func (s *Session) Reopen(ctx context.Context) error {
+	if s.lazy {
+		s.openMu.Lock()
+		opened := s.opened
+		s.openMu.Unlock()
+		if !opened {
+			return s.ensureOpen(ctx)
 		}
-	}()
+	}
+
+	s.headerMu.Lock()
+	s.mu.Lock()
+	s.SessionID = 0
+	s.lastIndex = 0
+	s.requestID = 0
+	s.responseID = 0
+	s.streams = make(map[uint64]*Stream)
+	s.invalidateStreamHeaders()
+	s.streamHeadersMu.Lock()
+	s.streamAcks = nil
+	s.streamHeadersMu.Unlock()
+	s.mu.Unlock()
+	err := s.openSession(ctx)
+	s.headerMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	listeners := make([]*reopenListener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+	for _, listener := range listeners {
+		listener.f(ctx)
+	}
 	return nil
 }
 
+// OnReopen adds a listener to be called when the session is reopened
+// The returned function removes the listener.
+func (s *Session) OnReopen(f func(ctx context.Context)) func() {
+	listener := &reopenListener{f: f}
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, l := range s.listeners {
+			if l == listener {
+				s.listeners = append(s.listeners[:i], s.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// OnExpire adds a listener to be called when the session expires
+// The session expires if the partition rejects a keep-alive, e.g. because it enforces a shorter session timeout
+// than the session requested, if no keep-alive succeeds within the session timeout of the last successful
+// keep-alive, or if it misses the number of consecutive keep-alives set by WithMaxMissedKeepAlives. Operations
+// on an expired session fail with ErrSessionExpired. Once the session has expired, keep-alives are no longer
+// sent, and listeners are not called again until the session has been reopened. The returned function removes
+// the listener.
+func (s *Session) OnExpire(f func()) func() {
+	listener := &expireListener{f: f}
+	s.mu.Lock()
+	s.expireListeners = append(s.expireListeners, listener)
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, l := range s.expireListeners {
+			if l == listener {
+				s.expireListeners = append(s.expireListeners[:i], s.expireListeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// OnStreamError adds a listener to be called when a command stream is closed with an error
+// Command streams are closed with an error when a response is missed and the session's stream policy is
+// ErrorOnGap. The stream's response channel is closed once the listeners have been called. The returned
+// function removes the listener.
+func (s *Session) OnStreamError(f func(err error)) func() {
+	listener := &streamErrorListener{f: f}
+	s.mu.Lock()
+	s.streamListeners = append(s.streamListeners, listener)
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, l := range s.streamListeners {
+			if l == listener {
+				s.streamListeners = append(s.streamListeners[:i], s.streamListeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// streamError calls the stream error listeners with the given error
+func (s *Session) streamError(err error) {
+	s.mu.RLock()
+	listeners := make([]*streamErrorListener, len(s.streamListeners))
+	copy(listeners, s.streamListeners)
+	s.mu.RUnlock()
+	for _, listener := range listeners {
+		listener.f(err)
+	}
+}
+
+// heartbeat sends a keep-alive for the session and expires the session if it cannot be kept alive
+func (s *Session) heartbeat() {
+	s.mu.RLock()
+	expired := s.expired
+	deadline := s.lastKeepAlive.Add(s.Timeout)
+	s.mu.RUnlock()
+	if expired {
+		return
+	}
+
+	// If missed keep-alives are counted, a keep-alive that does not complete within half the session timeout
+	// is missed
+	sent := time.Now()
+	keepAliveDeadline := deadline
+	if s.maxMissed > 0 && sent.Add(s.Timeout/2).Before(deadline) {
+		keepAliveDeadline = sent.Add(s.Timeout / 2)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), keepAliveDeadline)
+	err := s.keepAlive(ctx)
+	cancel()
+	if err == nil {
+		s.mu.Lock()
+		if sent.After(s.lastKeepAlive) {
+			s.lastKeepAlive = sent
+		}
+		s.missed = 0
+		s.mu.Unlock()
+	} else if errors.IsUnknown(err) || errors.IsNotFound(err) || !time.Now().Before(deadline) || s.miss() {
+		s.expire()
+	}
+}
+
+// miss records a missed keep-alive and returns whether the session has missed the maximum number of
+// consecutive keep-alives
+func (s *Session) miss() bool {
+	if s.maxMissed == 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.missed++
+	return s.missed >= s.maxMissed
+}
+
+// isExpired returns whether the session has expired
+func (s *Session) isExpired() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.expired
+}
+
+// expire marks the session expired and calls the expire listeners
+func (s *Session) expire() {
+	s.mu.Lock()
+	if s.expired {
+		s.mu.Unlock()
+		return
+	}
+	s.expired = true
+	listeners := make([]*expireListener, len(s.expireListeners))
+	copy(listeners, s.expireListeners)
+	s.mu.Unlock()
+	s.markDone()
+	for _, listener := range listeners {
+		listener.f()
+	}
+}
+
+// ID returns the ID generated for the session when it was created
+// Unlike the SessionID, which is assigned by the partition each time the session is opened, the ID is generated
+// by the client and does not change when the session is reopened. The ID is not sent to the partition; it
+// identifies the session to the client, e.g. in logs.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// LastIndex returns the highest index observed by the session
+// The index can be passed to WithInitialIndex to open a session on the same partition whose reads observe at
+// least the writes observed by this session. The index is reset when the session is reopened.
+func (s *Session) LastIndex() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastIndex
+}
+
+// Done returns a channel that's closed when the session ends
+// The channel is closed when the session is closed or expires. Once closed, the channel remains closed even
+// if an expired session is reopened. Done may be called before or after the session ends.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Ready returns whether the session is open
+// A session is ready once it has been opened and until it's closed or expires. Ready does not contact the
+// partition, so a ready session's partition may be unreachable; use Ping to check that the partition is
+// reachable.
+func (s *Session) Ready() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+	}
+	if s.isExpired() {
+		return false
+	}
+	if !s.lazy {
+		return true
+	}
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
+	return s.opened
+}
+
+// Ping checks that the session's partition is reachable
+// If the session is open, Ping sends a keep-alive for the session, so a successful Ping also keeps the session
+// alive. A lazy session that has not been opened yet is not opened by Ping: Ping only waits for the connection
+// to the partition to become ready. Ping fails with an Unavailable error if the session is closed or expired.
+func (s *Session) Ping(ctx context.Context) error {
+	select {
+	case <-s.closed:
+		return errors.NewUnavailable("session is closed")
+	default:
+	}
+	if s.isExpired() {
+		return ErrSessionExpired
+	}
+	if s.lazy {
+		s.openMu.Lock()
+		opened := s.opened
+		s.openMu.Unlock()
+		if !opened {
+			if err := s.waitForReady(ctx); err != nil {
+				return errors.NewUnavailable(err.Error())
+			}
+			return nil
+		}
+	}
+	return s.keepAlive(ctx)
+}
+
+// markDone closes the done channel if it's not already closed
+func (s *Session) markDone() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
 // keepAlive keeps the session alive
+// Keep-alives send compact stream headers, and the stream acknowledgements are recorded once the keep-alive
+// succeeds so later keep-alives can omit them.
 func (s *Session) keepAlive(ctx context.Context) error {
-	return s.doSession(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+	header, streams := s.getKeepAliveState()
+	_, err := s.doRequest(ctx, header, func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
 		request := &api.KeepAliveRequest{
 			Header: header,
 		}
@@ -170,13 +1091,30 @@ func (s *Session) keepAlive(ctx context.Context) error {
 		}
 		return response.Header, response, nil
 	})
+	if err != nil {
+		return err
+	}
+	s.ackStreamHeaders(header.SessionID, streams)
+	return nil
 }
 
 // Close closes the session
+// Close is idempotent: only the first call closes the session, and subsequent calls return the same result.
 func (s *Session) Close() error {
-	err := s.close(context.TODO())
-	s.ticker.Stop()
-	return err
+	s.closeOnce.Do(func() {
+		s.openMu.Lock()
+		if s.opened {
+			s.closeErr = s.close(context.TODO())
+		}
+		s.stopKeepAlive()
+		for _, replica := range s.replicas {
+			_ = replica.Close()
+		}
+		close(s.closed)
+		s.markDone()
+		s.openMu.Unlock()
+	})
+	return s.closeErr
 }
 
 // close closes the session
@@ -204,8 +1142,7 @@ func getPrimitiveID(name Name) primitiveapi.PrimitiveId {
 // getState gets the header for the current state of the session
 func (s *Session) getState(primitive primitiveapi.PrimitiveId) *headers.RequestHeader {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return &headers.RequestHeader{
+	header := &headers.RequestHeader{
 		Primitive: primitive,
 		Partition: uint32(s.Partition),
 		SessionID: s.SessionID,
@@ -213,25 +1150,54 @@ func (s *Session) getState(primitive primitiveapi.PrimitiveId) *headers.RequestH
 		RequestID: s.responseID,
 		Streams:   s.getStreamHeaders(),
 	}
+	s.mu.RUnlock()
+	s.intercept(header)
+	return header
 }
 
-// getQueryHeader gets the current read header
-func (s *Session) getQueryHeader(primitive primitiveapi.PrimitiveId) *headers.RequestHeader {
+// getKeepAliveState gets the header for a keep-alive request and the stream headers it acknowledges
+// The server closes streams that are missing from a keep-alive, so every open stream must be listed, but
+// streams whose response ID was acknowledged by a previous keep-alive are listed without a response ID.
+// The server ignores acknowledgements of responses it has already discarded, so this only omits
+// acknowledgements the server has already applied.
+func (s *Session) getKeepAliveState() (*headers.RequestHeader, []headers.StreamHeader) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return &headers.RequestHeader{
+	streams := s.getStreamHeaders()
+	header := &headers.RequestHeader{
+		Partition: uint32(s.Partition),
+		SessionID: s.SessionID,
+		Index:     s.lastIndex,
+		RequestID: s.responseID,
+		Streams:   s.compactStreamHeaders(streams),
+	}
+	s.mu.RUnlock()
+	s.intercept(header)
+	return header, streams
+}
+
+// getQueryHeader gets the current read header for a query with the given consistency level
+// Queries that may be stale do not wait for the session's index or sequence number.
+func (s *Session) getQueryHeader(primitive primitiveapi.PrimitiveId, consistency Consistency) *headers.RequestHeader {
+	s.mu.RLock()
+	header := &headers.RequestHeader{
 		Primitive: primitive,
 		Partition: uint32(s.Partition),
 		SessionID: s.SessionID,
 		Index:     s.lastIndex,
 		RequestID: s.requestID,
 	}
+	s.mu.RUnlock()
+	if consistency.level == eventual || consistency.level == boundedStaleness {
+		header.Index = 0
+		header.RequestID = 0
+	}
+	s.intercept(header)
+	return header
 }
 
 // nextCommandHeader returns the next write header
 func (s *Session) nextCommandHeader(primitive primitiveapi.PrimitiveId) *headers.RequestHeader {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.requestID = s.requestID + 1
 	header := &headers.RequestHeader{
 		Primitive: primitive,
@@ -240,19 +1206,42 @@ func (s *Session) nextCommandHeader(primitive primitiveapi.PrimitiveId) *headers
 		Index:     s.lastIndex,
 		RequestID: s.requestID,
 	}
+	s.mu.Unlock()
+	s.intercept(header)
 	return header
 }
 
+// nextCommandHeaders returns the given number of consecutive write headers
+func (s *Session) nextCommandHeaders(primitive primitiveapi.PrimitiveId, n int) []*headers.RequestHeader {
+	requestHeaders := make([]*headers.RequestHeader, n)
+	s.mu.Lock()
+	for i := 0; i < n; i++ {
+		s.requestID = s.requestID + 1
+		requestHeaders[i] = &headers.RequestHeader{
+			Primitive: primitive,
+			Partition: uint32(s.Partition),
+			SessionID: s.SessionID,
+			Index:     s.lastIndex,
+			RequestID: s.requestID,
+		}
+	}
+	s.mu.Unlock()
+	for _, header := range requestHeaders {
+		s.intercept(header)
+	}
+	return requestHeaders
+}
+
 // nextStreamHeader returns the next write stream and header
 func (s *Session) nextStreamHeader(primitive primitiveapi.PrimitiveId) (*Stream, *headers.RequestHeader) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.requestID = s.requestID + 1
 	stream := &Stream{
 		ID:      s.requestID,
 		session: s,
 	}
 	s.streams[s.requestID] = stream
+	s.invalidateStreamHeaders()
 	header := &headers.RequestHeader{
 		Primitive: primitive,
 		Partition: uint32(s.Partition),
@@ -260,12 +1249,31 @@ func (s *Session) nextStreamHeader(primitive primitiveapi.PrimitiveId) (*Stream,
 		Index:     s.lastIndex,
 		RequestID: s.requestID,
 	}
+	s.mu.Unlock()
+	s.intercept(header)
 	return stream, header
 }
 
+// intercept calls the session's header interceptor, if any, with the given outgoing header
+// The interceptor is called without holding the session's locks.
+func (s *Session) intercept(header *headers.RequestHeader) {
+	if s.interceptor != nil {
+		s.interceptor(header)
+	}
+}
+
+// WithConn calls the given function with the session's current connection to the partition
+// The connection is managed by the session: it may change across calls, e.g. when the session is redirected to
+// a new leader, so the function must not retain the connection after it returns. Long-running uses should call
+// WithConn again to re-acquire the connection. The connection is not closed while the function is running, but
+// the session cannot reconnect until the function returns, so the function should return promptly.
+func (s *Session) WithConn(f func(conn *grpc.ClientConn) error) error {
+	return s.conns.Use(f)
+}
+
 func (s *Session) doSession(ctx context.Context, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) error {
 	header := s.getState(primitiveapi.PrimitiveId{})
-	_, err := s.doRequest(ctx, header, func(conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
+	_, err := s.doRequest(ctx, header, func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
 		return f(ctx, conn, header)
 	})
 	return err
@@ -273,8 +1281,13 @@ func (s *Session) doSession(ctx context.Context, f func(ctx context.Context, con
 
 // doPrimitive sends a primitive request
 func (s *Session) doPrimitive(ctx context.Context, name Name, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) error {
+	if err := s.ensureOpen(ctx); err != nil {
+		return err
+	}
+	s.headerMu.RLock()
 	header := s.nextCommandHeader(getPrimitiveID(name))
-	_, err := s.doRequest(ctx, header, func(conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
+	s.headerMu.RUnlock()
+	_, err := s.doRequest(ctx, header, func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
 		return f(ctx, conn, header)
 	})
 	return err
@@ -282,89 +1295,386 @@ func (s *Session) doPrimitive(ctx context.Context, name Name, f func(ctx context
 
 // doCreate sends a create session request
 func (s *Session) doCreate(ctx context.Context, name Name, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) error {
-	return s.doPrimitive(ctx, name, f)
+	return s.wrapError(name, "create", s.doPrimitive(ctx, name, f))
 }
 
 // doClose sends a session close request
 func (s *Session) doClose(ctx context.Context, name Name, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) error {
-	return s.doPrimitive(ctx, name, f)
+	return s.wrapError(name, "close", s.doPrimitive(ctx, name, f))
 }
 
 // doQuery sends a session query request
-func (s *Session) doQuery(ctx context.Context, name Name, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) (interface{}, error) {
-	header := s.getQueryHeader(getPrimitiveID(name))
-	return s.doRequest(ctx, header, func(conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
+func (s *Session) doQuery(ctx context.Context, name Name, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error), opts ...QueryOption) (interface{}, error) {
+	options := &queryOptions{}
+	for _, opt := range opts {
+		opt.prepare(options)
+	}
+	if err := s.ensureOpen(ctx); err != nil {
+		return nil, s.wrapError(name, "query", err)
+	}
+	header := s.getQueryHeader(getPrimitiveID(name), options.consistency)
+	query := func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
 		return f(ctx, conn, header)
-	})
+	}
+	// Queries are idempotent, so unless they're linearizable, a query that failed mid-flight is retried as soon
+	// as the connection is ready again rather than after backing off
+	retryTransient := options.consistency.level != linearizable
+	if options.stale && len(s.replicas) > 0 {
+		response, err := s.doStaleQuery(ctx, header, query, options, retryTransient)
+		return response, s.wrapError(name, "query", err)
+	}
+	response, err := s.sendRequest(ctx, header, query, retryTransient)
+	return response, s.wrapError(name, "query", err)
+}
+
+// doStaleQuery sends a query that may be served by a replica of the partition
+// The query is sent to the leader first. If the leader cannot be reached, the query is sent to each replica until
+// one of them serves it within the given lag, and otherwise the query is retried against the leader.
+func (s *Session) doStaleQuery(ctx context.Context, requestHeader *headers.RequestHeader, f func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error), options *queryOptions, retryTransient bool) (interface{}, error) {
+	conn, err := s.conns.Connect()
+	if err == nil {
+		attemptCtx, cancel := attemptContext(ctx, 0)
+		var responseHeader *headers.ResponseHeader
+		var response interface{}
+		responseHeader, response, err = f(attemptCtx, conn)
+		cancel()
+		if err == nil && responseHeader.Status == headers.ResponseStatus_OK {
+			s.recordResponse(requestHeader, responseHeader)
+			return response, nil
+		}
+	}
+
+	if err != nil && ctx.Err() == nil {
+		lastIndex := s.LastIndex()
+		for _, replica := range s.replicas {
+			conn, err := replica.Connect()
+			if err != nil {
+				continue
+			}
+			attemptCtx, cancel := attemptContext(ctx, 0)
+			responseHeader, response, err := f(attemptCtx, conn)
+			cancel()
+			if err != nil || responseHeader.Status != headers.ResponseStatus_OK {
+				continue
+			}
+			// Replica responses are not recorded, since the replica may be behind the session
+			if !options.bounded || responseHeader.Index+options.maxLag >= lastIndex {
+				return response, nil
+			}
+		}
+	}
+	return s.sendRequest(ctx, requestHeader, f, retryTransient)
+}
+
+// isTransient returns whether the given error is a transient gRPC failure, e.g. a reset connection
+func isTransient(err error) bool {
+	return status.Code(err) == codes.Unavailable
 }
 
 // doCommand sends a session command request
 func (s *Session) doCommand(ctx context.Context, name Name, f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error)) (interface{}, error) {
+	if err := s.ensureOpen(ctx); err != nil {
+		return nil, s.wrapError(name, "command", err)
+	}
+	if err := s.waitRateLimit(ctx); err != nil {
+		return nil, s.wrapError(name, "command", err)
+	}
+	s.headerMu.RLock()
 	header := s.nextCommandHeader(getPrimitiveID(name))
-	return s.doRequest(ctx, header, func(conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
+	s.headerMu.RUnlock()
+	response, err := s.doRequest(ctx, header, func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
 		return f(ctx, conn, header)
 	})
+	return response, s.wrapError(name, "command", err)
 }
 
-func (s *Session) doRequest(ctx context.Context, requestHeader *headers.RequestHeader, f func(conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error)) (interface{}, error) {
-	i := 0
-	for {
+// doBatch sends a batch of session command requests
+// The commands are assigned consecutive headers and sent in order. Other commands may be sent by the session
+// while the batch is in progress, but the partition applies commands in sequence order, so they are applied
+// after the batch rather than interleaved with it. If a command fails, the batch is aborted and the results of the
+// commands that completed are returned along with the error. Commands that have already completed are
+// not rolled back. The sequence numbers reserved for the commands that were not applied are filled with
+// requests for a primitive that does not exist, which the server rejects without side effects, so the
+// commands sent by the session after the batch are not blocked waiting for the missing sequence numbers.
+func (s *Session) doBatch(ctx context.Context, name Name, fns []CommandFunc) ([]interface{}, error) {
+	if err := s.ensureOpen(ctx); err != nil {
+		return nil, s.wrapError(name, "batch", err)
+	}
+	s.headerMu.RLock()
+	requestHeaders := s.nextCommandHeaders(getPrimitiveID(name), len(fns))
+	s.headerMu.RUnlock()
+	results := make([]interface{}, 0, len(fns))
+	for i, f := range fns {
+		header := requestHeaders[i]
+		f := f
+		err := s.waitRateLimit(ctx)
+		if err == nil {
+			var result interface{}
+			applied := false
+			result, err = s.doRequest(ctx, header, func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
+				responseHeader, response, err := f(ctx, conn, header)
+				if err == nil && responseHeader.Status != headers.ResponseStatus_NOT_LEADER {
+					applied = true
+				}
+				return responseHeader, response, err
+			})
+			if err == nil {
+				results = append(results, result)
+				continue
+			}
+			if applied {
+				// The partition responded to the command, so its sequence number has been consumed
+				i++
+			}
+		}
+		s.skipCommands(requestHeaders[i:], fns[i:])
+		return results, s.wrapError(name, "batch", err)
+	}
+	return results, nil
+}
+
+// skipCommands fills the sequence numbers of the given command headers
+// Each command is resent with its primitive removed from the header, so the server consumes its sequence
+// number without applying it. If the original command did reach the server, the server's cached result
+// for the sequence number is not returned since no session is open for the missing primitive. Any response
+// from the partition, including an error status, means the sequence number has been consumed.
+func (s *Session) skipCommands(requestHeaders []*headers.RequestHeader, fns []CommandFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	for i, f := range fns {
+		header := *requestHeaders[i]
+		header.Primitive = primitiveapi.PrimitiveId{}
+		f := f
+		_, err := s.doRequest(ctx, &header, func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error) {
+			responseHeader, response, err := f(ctx, conn, &header)
+			if err != nil {
+				if isTransient(err) {
+					return nil, nil, err
+				}
+				return &headers.ResponseHeader{Status: headers.ResponseStatus_OK}, nil, nil
+			}
+			if responseHeader.Status != headers.ResponseStatus_NOT_LEADER {
+				return &headers.ResponseHeader{Status: headers.ResponseStatus_OK}, nil, nil
+			}
+			return responseHeader, response, err
+		})
+		if err != nil {
+			// The partition could not be reached within the session timeout, so the session expires
+			return
+		}
+	}
+}
+
+// wrapError wraps an error returned by an operation on the given primitive with the context of the session
+func (s *Session) wrapError(name Name, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*errors.OperationError); ok {
+		return err
+	}
+	s.mu.RLock()
+	sessionID := s.SessionID
+	s.mu.RUnlock()
+	return &errors.OperationError{
+		Partition: s.Partition,
+		SessionID: sessionID,
+		Primitive: name.String(),
+		Operation: operation,
+		Err:       err,
+	}
+}
+
+// partition returns the partition to which the session belongs
+func (s *Session) partition() Partition {
+	return Partition{
+		ID:      s.Partition,
+		Address: s.address,
+	}
+}
+
+// reconnect reconnects the session to the address picked by the session's reconnect strategy
+// Either the session was redirected to the given leader, or it failed to connect with the given error. If the
+// session connects to the leader to which it was redirected, the leader is recorded in the leader cache. If it
+// connects to another address after failing to connect, its current address is removed from the leader cache.
+func (s *Session) reconnect(leader net.Address, err error) {
+	current := s.conns.Leader()
+	address := s.strategy.PickAddress(s.partition(), current, leader, err)
+	if address == "" || address == current {
+		return
+	}
+	if err != nil {
+		s.leaders.InvalidateLeader(s.partition(), current)
+	}
+	s.conns.Reconnect(address)
+	if leader != "" && address == leader {
+		s.leaders.SetLeader(s.partition(), leader)
+	}
+}
+
+// redirect reconnects the session after a stream was redirected to the given leader and returns the connection
+func (s *Session) redirect(leader string) (*grpc.ClientConn, error) {
+	s.reconnect(net.Address(leader), nil)
+	return s.conns.Connect()
+}
+
+// waitRateLimit blocks until the session's rate limit allows a command to be sent
+func (s *Session) waitRateLimit(ctx context.Context) error {
+	if s.limiter == nil {
+		return nil
+	}
+	if err := s.limiter.Wait(ctx); err != nil {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// attemptBudget is the number of attempts across which a request's deadline is split
+const attemptBudget = 3
+
+// attemptContext returns the context for the given attempt of a request with the given context
+// If the context has a deadline, the remaining time is split evenly across the attempts remaining in the
+// attempt budget, so a single slow attempt cannot consume the entire deadline. Attempts beyond the budget
+// are given the remaining time.
+func attemptContext(ctx context.Context, attempt int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	attempts := attemptBudget - attempt
+	if attempts < 1 {
+		attempts = 1
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(attempts))
+}
+
+// doRequest sends a request, retrying as determined by the session's reconnect strategy
+// f is called with a context bounded by the attempt's share of the context's deadline. If the context's
+// deadline would expire before the next attempt, a Timeout error is returned without waiting. The context is
+// checked between attempts, so once it's done the request fails with a Canceled or Timeout error wrapping the
+// context's error rather than being retried.
+func (s *Session) doRequest(ctx context.Context, requestHeader *headers.RequestHeader, f func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error)) (interface{}, error) {
+	return s.sendRequest(ctx, requestHeader, f, false)
+}
+
+// sendRequest sends a request as doRequest does
+// If retryTransient is true, a request that failed with a transient error is retried as soon as the session's
+// connection is ready again, waiting no longer than the reconnect strategy's delay.
+func (s *Session) sendRequest(ctx context.Context, requestHeader *headers.RequestHeader, f func(ctx context.Context, conn *grpc.ClientConn) (*headers.ResponseHeader, interface{}, error), retryTransient bool) (interface{}, error) {
+	failures := 0
+	redirects := 0
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && ctx.Err() != nil {
+			return nil, errors.FromContext(ctx.Err())
+		}
 		conn, err := s.conns.Connect()
 		if err != nil {
 			return nil, err
 		}
-		responseHeader, response, err := f(conn)
+		attemptCtx, cancel := attemptContext(ctx, attempt)
+		responseHeader, response, err := f(attemptCtx, conn)
+		cancel()
 		if err == nil {
 			switch responseHeader.Status {
 			case headers.ResponseStatus_OK:
 				s.recordResponse(requestHeader, responseHeader)
 				return response, nil
 			case headers.ResponseStatus_NOT_LEADER:
-				s.conns.Reconnect(net.Address(responseHeader.Leader))
+				s.reconnect(net.Address(responseHeader.Leader), nil)
+				// The first redirect usually names the new leader, so only further redirects are delayed
+				redirects++
+				if redirects > 1 {
+					select {
+					case <-time.After(s.backoff.delay(redirects - 1)):
+					case <-ctx.Done():
+						return nil, errors.FromContext(ctx.Err())
+					}
+				}
 				continue
 			default:
 				s.recordResponse(requestHeader, responseHeader)
 				return response, errors.FromHeader(responseHeader)
 			}
-		} else if err == context.Canceled {
-			return nil, errors.NewCanceled(err.Error())
 		} else {
+			failures++
+			if !s.strategy.ShouldRetry(failures, err) {
+				if ctx.Err() != nil {
+					return nil, errors.FromContext(ctx.Err())
+				}
+				return nil, errors.FromContext(err)
+			}
+			if isTransient(err) {
+				s.reconnect("", err)
+			}
+			// Requests in an expired session are not retried, except requests that open a new session
+			if requestHeader.SessionID != 0 && s.isExpired() {
+				return nil, ErrSessionExpired
+			}
+			backoff := s.strategy.NextDelay(failures)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+				return nil, errors.FromContext(context.DeadlineExceeded)
+			}
+			if retryTransient && isTransient(err) {
+				waitCtx, cancel := context.WithTimeout(ctx, backoff)
+				s.awaitReady(waitCtx)
+				cancel()
+				continue
+			}
 			select {
-			case <-time.After(time.Duration(math.Max(math.Pow(float64(i), 2), 1000)) * time.Millisecond):
-				i++
+			case <-time.After(backoff):
 			case <-ctx.Done():
-				return nil, ctx.Err()
+				return nil, errors.FromContext(ctx.Err())
 			}
 		}
 	}
 }
 
+// awaitReady waits until the session's connection is ready or the given context is done
+// The connection's reconnect backoff is reset, so a connection that was dropped is re-established immediately.
+func (s *Session) awaitReady(ctx context.Context) {
+	conn, err := s.conns.Connect()
+	if err != nil {
+		return
+	}
+	conn.ResetConnectBackoff()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready || !conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
 // doQueryStream sends a session query stream request
 func (s *Session) doQueryStream(
 	ctx context.Context,
 	name Name,
 	f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error),
 	responseFunc func(interface{}) (*headers.ResponseHeader, interface{}, error)) (<-chan interface{}, error) {
+	if err := s.ensureOpen(ctx); err != nil {
+		return nil, s.wrapError(name, "query stream", err)
+	}
+
 	conn, err := s.conns.Connect()
 	if err != nil {
-		return nil, err
+		return nil, s.wrapError(name, "query stream", err)
 	}
 
-	requestHeader := s.getQueryHeader(getPrimitiveID(name))
+	requestHeader := s.getQueryHeader(getPrimitiveID(name), Sequential)
 	responses, err := f(ctx, conn, requestHeader)
 	if err != nil {
-		return nil, err
+		return nil, s.wrapError(name, "query stream", err)
 	}
 
 	handshakeCh := make(chan struct{})
 	responseCh := make(chan interface{})
-	go s.queryStream(ctx, f, responseFunc, responses, requestHeader, handshakeCh, responseCh)
+	go s.queryStream(ctx, f, responseFunc, responses, requestHeader, handshakeCh, s.bufferResponses(ctx, responseCh))
 
 	select {
 	case <-handshakeCh:
 		return responseCh, nil
 	case <-time.After(15 * time.Second):
-		return nil, errors.NewTimeout("handshake timed out")
+		return nil, s.wrapError(name, "query stream", errors.NewTimeout("handshake timed out"))
 	}
 }
 
@@ -375,7 +1685,7 @@ func (s *Session) queryStream(
 	responses interface{},
 	requestHeader *headers.RequestHeader,
 	handshakeCh chan<- struct{},
-	responseCh chan interface{}) {
+	responseCh chan<- interface{}) {
 	for {
 		responseHeader, response, err := responseFunc(responses)
 		if err != nil {
@@ -394,10 +1704,17 @@ func (s *Session) queryStream(
 			case headers.ResponseStatus_OK:
 				// Record the response
 				s.recordResponse(requestHeader, responseHeader)
-				responseCh <- response
+
+				// If the context is canceled while the response is being delivered, stop reading the stream.
+				// The stream was created with the context, so the server-side stream is canceled with it.
+				select {
+				case responseCh <- response:
+				case <-ctx.Done():
+					close(responseCh)
+					return
+				}
 			case headers.ResponseStatus_NOT_LEADER:
-				s.conns.Reconnect(net.Address(responseHeader.Leader))
-				conn, err := s.conns.Connect()
+				conn, err := s.redirect(responseHeader.Leader)
 				if err != nil {
 					close(responseCh)
 				} else {
@@ -417,25 +1734,56 @@ func (s *Session) queryStream(
 	}
 }
 
+// bufferResponses returns a channel that buffers stream responses before they're pushed onto the given channel
+// If the session does not buffer stream responses, the given channel is returned. Otherwise, the given channel is
+// closed once the returned channel has been closed and the buffered responses have been pushed, so responses
+// received before the stream is closed are not lost. If the context is canceled, the remaining responses are dropped.
+func (s *Session) bufferResponses(ctx context.Context, ch chan<- interface{}) chan<- interface{} {
+	if s.buffer == 0 {
+		return ch
+	}
+	buffer := make(chan interface{}, s.buffer)
+	go func() {
+		defer close(ch)
+		for response := range buffer {
+			select {
+			case ch <- response:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return buffer
+}
+
 // doCommandStream sends a session command stream request
 func (s *Session) doCommandStream(
 	ctx context.Context,
 	name Name,
 	f func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error),
 	responseFunc func(interface{}) (*headers.ResponseHeader, interface{}, error)) (<-chan interface{}, error) {
+	if err := s.ensureOpen(ctx); err != nil {
+		return nil, s.wrapError(name, "command stream", err)
+	}
+
+	if err := s.waitRateLimit(ctx); err != nil {
+		return nil, s.wrapError(name, "command stream", err)
+	}
+
 	conn, err := s.conns.Connect()
 	if err != nil {
-		return nil, err
+		return nil, s.wrapError(name, "command stream", err)
 	}
 
+	s.headerMu.RLock()
 	stream, requestHeader := s.nextStreamHeader(getPrimitiveID(name))
+	s.headerMu.RUnlock()
 	responses, err := f(ctx, conn, requestHeader)
 	if err != nil {
 		stream.Close()
-		return nil, err
+		return nil, s.wrapError(name, "command stream", err)
 	}
 
-	fmt.Printf("GO_CLIENT:STREAM_HEADER_OBJECT %s\n", stream)
+	fmt.Printf("GO_CLIENT:STREAM_HEADER_OBJECT %v\n", stream)
 
 	// Create a goroutine to close the stream when the context is canceled.
 	// This will ensure that the server is notified the stream has been closed on the next keep-alive.
@@ -447,13 +1795,13 @@ func (s *Session) doCommandStream(
 
 	handshakeCh := make(chan struct{})
 	responseCh := make(chan interface{})
-	go s.commandStream(ctx, f, responseFunc, responses, stream, requestHeader, handshakeCh, responseCh)
+	go s.commandStream(ctx, f, responseFunc, responses, stream, requestHeader, handshakeCh, s.bufferResponses(ctx, responseCh))
 
 	select {
 	case <-handshakeCh:
 		return responseCh, nil
 	case <-time.After(15 * time.Second):
-		return nil, errors.NewTimeout("handshake timed out")
+		return nil, s.wrapError(name, "command stream", errors.NewTimeout("handshake timed out"))
 	}
 }
 
@@ -498,13 +1846,25 @@ func (s *Session) commandStream(
 				// Record the response
 				s.recordResponse(requestHeader, responseHeader)
 
-				// Attempt to serialize the response to the stream and skip the response if serialization failed.
-				if stream.serialize(responseHeader) {
-					responseCh <- response
+				// Sequence the response on the stream and skip or fail on out of order responses according to
+				// the session's stream policy.
+				deliver, err := stream.sequence(responseHeader, s.policy)
+				if err != nil {
+					s.streamError(err)
+					close(responseCh)
+					stream.Close()
+					return
+				} else if deliver {
+					select {
+					case responseCh <- response:
+					case <-ctx.Done():
+						close(responseCh)
+						stream.Close()
+						return
+					}
 				}
 			case headers.ResponseStatus_NOT_LEADER:
-				s.conns.Reconnect(net.Address(responseHeader.Leader))
-				conn, err := s.conns.Connect()
+				conn, err := s.redirect(responseHeader.Leader)
 				if err != nil {
 					close(responseCh)
 					stream.Close()
@@ -544,6 +1904,7 @@ func (s *Session) recordResponse(requestHeader *headers.RequestHeader, responseH
 		// If the request ID is greater than the highest response ID, update the response ID.
 		if requestHeader.RequestID > s.responseID {
 			s.responseID = requestHeader.RequestID
+			s.invalidateStreamHeaders()
 		}
 
 		// If the response index has increased, update the last received index
@@ -561,19 +1922,75 @@ func (s *Session) deleteStream(streamID uint64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.streams, streamID)
+	s.invalidateStreamHeaders()
 }
 
 // getStreamHeaders returns a slice of headers for all open streams
+// The headers are cached until a stream is added or removed or the response ID of the session or a stream
+// advances. The caller must hold the session lock, and must not modify the returned slice.
 func (s *Session) getStreamHeaders() []headers.StreamHeader {
+	s.streamHeadersMu.Lock()
+	defer s.streamHeadersMu.Unlock()
+	if s.streamHeaders != nil {
+		return s.streamHeaders
+	}
 	result := make([]headers.StreamHeader, 0, len(s.streams))
 	for _, stream := range s.streams {
 		if stream.ID <= s.responseID {
 			result = append(result, stream.getHeader())
 		}
 	}
+	s.streamHeaders = result
+	return result
+}
+
+// compactStreamHeaders returns the given stream headers without the response IDs already acknowledged
+func (s *Session) compactStreamHeaders(streams []headers.StreamHeader) []headers.StreamHeader {
+	s.streamHeadersMu.Lock()
+	defer s.streamHeadersMu.Unlock()
+	result := make([]headers.StreamHeader, len(streams))
+	for i, stream := range streams {
+		if s.streamAcks[stream.StreamID] == stream.ResponseID {
+			result[i] = headers.StreamHeader{
+				StreamID: stream.StreamID,
+			}
+		} else {
+			result[i] = stream
+		}
+	}
 	return result
 }
 
+// ackStreamHeaders records the stream headers sent in a successful keep-alive of the given session
+// Streams missing from the headers have been closed, so their acknowledgements are discarded. Keep-alives
+// sent before the session was reopened are ignored.
+func (s *Session) ackStreamHeaders(sessionID uint64, streams []headers.StreamHeader) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.SessionID != sessionID {
+		return
+	}
+	s.streamHeadersMu.Lock()
+	defer s.streamHeadersMu.Unlock()
+	acks := make(map[uint64]uint64, len(streams))
+	for _, stream := range streams {
+		// Keep-alives may complete out of order, so never move an acknowledgement backwards
+		if ack := s.streamAcks[stream.StreamID]; ack > stream.ResponseID {
+			acks[stream.StreamID] = ack
+		} else {
+			acks[stream.StreamID] = stream.ResponseID
+		}
+	}
+	s.streamAcks = acks
+}
+
+// invalidateStreamHeaders invalidates the cached stream headers
+func (s *Session) invalidateStreamHeaders() {
+	s.streamHeadersMu.Lock()
+	s.streamHeaders = nil
+	s.streamHeadersMu.Unlock()
+}
+
 // Stream manages the context for a single response stream within a session
 type Stream struct {
 	ID         uint64
@@ -595,12 +2012,17 @@ func (s *Stream) getHeader() headers.StreamHeader {
 // serialize updates the stream response metadata and returns whether the response was received in sequential order
 func (s *Stream) serialize(header *headers.ResponseHeader) bool {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	if header.ResponseID == s.responseID+1 {
-		s.responseID++
-		return true
+	if header.ResponseID != s.responseID+1 {
+		s.mu.Unlock()
+		return false
 	}
-	return false
+	s.responseID++
+	s.mu.Unlock()
+
+	// The stream lock must be released before invalidating the session's stream headers, since the
+	// stream headers are built while holding the stream headers lock
+	s.session.invalidateStreamHeaders()
+	return true
 }
 
 // Close closes the stream
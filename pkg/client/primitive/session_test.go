@@ -0,0 +1,1690 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive_test
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	counterapi "github.com/atomix/api/proto/atomix/counter"
+	"github.com/atomix/api/proto/atomix/headers"
+	primitiveapi "github.com/atomix/api/proto/atomix/primitive"
+	sessionapi "github.com/atomix/api/proto/atomix/session"
+	"github.com/lucasbfernandes/go-client/pkg/client/counter"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/lucasbfernandes/go-client/pkg/client/lock"
+	_map "github.com/lucasbfernandes/go-client/pkg/client/map"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	netutil "github.com/lucasbfernandes/go-client/pkg/client/util/net"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionRateLimit(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions, primitive.WithRateLimit(10, 1))
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	counter, err := counter.New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := counter.Increment(context.TODO(), 1)
+		assert.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) >= 350*time.Millisecond)
+
+	value, err := counter.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), value)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = counter.Increment(context.TODO(), 1)
+	assert.NoError(t, err)
+	_, err = counter.Increment(ctx, 1)
+	assert.True(t, goerrors.Is(err, primitive.ErrRateLimited))
+}
+
+func TestSessionEagerConnect(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions, primitive.WithEagerConnect())
+	assert.NoError(t, err)
+	test.CloseSessions(sessions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	partition := primitive.Partition{
+		ID:      1,
+		Address: "localhost:1",
+	}
+	_, err = primitive.NewSession(ctx, partition, primitive.WithEagerConnect())
+	assert.Error(t, err)
+	assert.True(t, errors.IsUnavailable(err))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestSessionLazyOpen(t *testing.T) {
+	partition := primitive.Partition{
+		ID:      1,
+		Address: "localhost:1",
+	}
+	session, err := primitive.NewSession(context.TODO(), partition, primitive.WithLazyOpen())
+	assert.NoError(t, err)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	unavailable, err := counter.New(context.TODO(), name, []*primitive.Session{session})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = unavailable.Get(ctx)
+	assert.Error(t, err)
+	assert.True(t, errors.IsUnavailable(err))
+	assert.NoError(t, unavailable.Close(context.TODO()))
+	assert.NoError(t, session.Close())
+
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions, primitive.WithLazyOpen())
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+	assert.Equal(t, uint64(0), sessions[0].SessionID)
+
+	counter, err := counter.New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := counter.Increment(context.TODO(), 1)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.NotEqual(t, uint64(0), sessions[0].SessionID)
+
+	value, err := counter.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), value)
+}
+
+func TestSessionOperationError(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_map, err := _map.New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = _map.Get(context.TODO(), "foo")
+	assert.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	var operationErr *errors.OperationError
+	assert.True(t, goerrors.As(err, &operationErr))
+	assert.Equal(t, name.String(), operationErr.Primitive)
+	assert.Equal(t, "query", operationErr.Operation)
+
+	var session *primitive.Session
+	for _, s := range sessions {
+		if s.Partition == operationErr.Partition {
+			session = s
+		}
+	}
+	assert.NotNil(t, session)
+	assert.Equal(t, session.SessionID, operationErr.SessionID)
+	assert.NotEqual(t, uint64(0), operationErr.SessionID)
+}
+
+func TestSessionQueryTransientRetry(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	proxy := newTestProxy(t, partitions[0].Address)
+	defer proxy.close()
+	partition := primitive.Partition{
+		ID:      partitions[0].ID,
+		Address: proxy.address(),
+	}
+	strategy := &testReconnectStrategy{
+		ReconnectStrategy: primitive.DefaultReconnectStrategy(),
+		maxAttempts:       3,
+		delay:             time.Second,
+	}
+	session, err := primitive.NewSession(context.TODO(), partition, primitive.WithReconnectStrategy(strategy), primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer session.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, session, &counterHandler{})
+	assert.NoError(t, err)
+	defer instance.Close(context.TODO())
+
+	// Drop the connection during the first attempt
+	attempts := 0
+	get := func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		attempts++
+		if attempts == 1 {
+			// Fail once the client has observed the dropped connection, as a real RPC would
+			proxy.drop()
+			conn.WaitForStateChange(ctx, connectivity.Ready)
+			return nil, nil, status.Error(codes.Unavailable, "connection reset")
+		}
+		client := counterapi.NewCounterServiceClient(conn)
+		response, err := client.Get(ctx, &counterapi.GetRequest{Header: header})
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	}
+
+	// A sequential query is retried as soon as the connection has been re-established
+	start := time.Now()
+	response, err := instance.DoQuery(context.TODO(), get)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), response.(*counterapi.GetResponse).Value)
+	assert.Equal(t, 2, attempts)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// A linearizable query is retried after the reconnect strategy's delay
+	attempts = 0
+	start = time.Now()
+	response, err = instance.DoQuery(context.TODO(), get, primitive.WithConsistency(primitive.Linearizable))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), response.(*counterapi.GetResponse).Value)
+	assert.Equal(t, 2, attempts)
+	assert.True(t, time.Since(start) >= time.Second)
+}
+
+// testProxy is a TCP proxy whose connections can be dropped
+type testProxy struct {
+	lis    net.Listener
+	target netutil.Address
+	conns  []net.Conn
+	mu     sync.Mutex
+}
+
+func newTestProxy(t *testing.T, target netutil.Address) *testProxy {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	proxy := &testProxy{
+		lis:    lis,
+		target: target,
+	}
+	go proxy.serve()
+	return proxy
+}
+
+func (p *testProxy) address() netutil.Address {
+	return netutil.Address(p.lis.Addr().String())
+}
+
+func (p *testProxy) serve() {
+	for {
+		client, err := p.lis.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", string(p.target))
+		if err != nil {
+			client.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, client, server)
+		p.mu.Unlock()
+		go func() {
+			_, _ = io.Copy(server, client)
+			server.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(client, server)
+			client.Close()
+		}()
+	}
+}
+
+// drop closes the proxy's open connections
+func (p *testProxy) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *testProxy) close() {
+	p.lis.Close()
+	p.drop()
+}
+
+// counterHandler is a primitive handler for raw counter instances
+type counterHandler struct{}
+
+func (h *counterHandler) Create(ctx context.Context, instance *primitive.Instance) error {
+	return instance.DoCreate(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		response, err := counterapi.NewCounterServiceClient(conn).Create(ctx, &counterapi.CreateRequest{Header: header})
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	})
+}
+
+func (h *counterHandler) Close(ctx context.Context, instance *primitive.Instance) error {
+	return instance.DoClose(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		response, err := counterapi.NewCounterServiceClient(conn).Close(ctx, &counterapi.CloseRequest{Header: header})
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	})
+}
+
+func (h *counterHandler) Delete(ctx context.Context, instance *primitive.Instance) error {
+	return instance.DoClose(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		response, err := counterapi.NewCounterServiceClient(conn).Close(ctx, &counterapi.CloseRequest{Header: header, Delete: true})
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	})
+}
+
+func TestSessionWithConn(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	counter, err := counter.New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+	err = counter.Set(context.TODO(), 1)
+	assert.NoError(t, err)
+
+	// Issue a custom request on the session's connection
+	var value int64
+	err = sessions[0].WithConn(func(conn *grpc.ClientConn) error {
+		assert.NotEqual(t, connectivity.Shutdown, conn.GetState())
+		client := counterapi.NewCounterServiceClient(conn)
+		response, err := client.Get(context.TODO(), &counterapi.GetRequest{
+			Header: &headers.RequestHeader{
+				Primitive: primitiveapi.PrimitiveId{Namespace: "default", Name: "test"},
+				Partition: uint32(sessions[0].Partition),
+				SessionID: sessions[0].SessionID,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		value = response.Value
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	custom := goerrors.New("custom")
+	err = sessions[0].WithConn(func(conn *grpc.ClientConn) error {
+		return custom
+	})
+	assert.Equal(t, custom, err)
+}
+
+func TestSessionLeaderCache(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	// Start a follower that redirects all requests to the partition
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	follower := &redirectingSessionServer{leader: string(partitions[0].Address)}
+	sessionapi.RegisterSessionServiceServer(server, follower)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	partition := primitive.Partition{
+		ID:      partitions[0].ID,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+	cache := primitive.NewLeaderCache()
+
+	session1, err := primitive.NewSession(context.TODO(), partition, primitive.WithLeaderCache(cache))
+	assert.NoError(t, err)
+	defer session1.Close()
+	assert.Equal(t, 1, follower.count())
+	leader, ok := cache.GetLeader(partition)
+	assert.True(t, ok)
+	assert.Equal(t, partitions[0].Address, leader)
+
+	// The second session connects to the cached leader without being redirected
+	session2, err := primitive.NewSession(context.TODO(), partition, primitive.WithLeaderCache(cache))
+	assert.NoError(t, err)
+	defer session2.Close()
+	assert.Equal(t, 1, follower.count())
+
+	// Sessions with their own cache are redirected
+	session3, err := primitive.NewSession(context.TODO(), partition, primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer session3.Close()
+	assert.Equal(t, 2, follower.count())
+
+	cache.InvalidateLeader(partition, "localhost:0")
+	_, ok = cache.GetLeader(partition)
+	assert.True(t, ok)
+	cache.InvalidateLeader(partition, partitions[0].Address)
+	_, ok = cache.GetLeader(partition)
+	assert.False(t, ok)
+}
+
+// redirectingSessionServer is a session service that redirects all requests to the leader
+type redirectingSessionServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+	leader   string
+	requests int
+	mu       sync.Mutex
+}
+
+func (s *redirectingSessionServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *redirectingSessionServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	return &sessionapi.OpenSessionResponse{
+		Header: &headers.ResponseHeader{
+			Status: headers.ResponseStatus_NOT_LEADER,
+			Leader: s.leader,
+		},
+	}, nil
+}
+
+func TestSessionDeadlineBudget(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, sessions[0], &counterHandler{})
+	assert.NoError(t, err)
+	defer instance.Close(context.TODO())
+
+	// Fail the first attempt immediately and block the second attempt until its budget is exhausted
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	var attemptDeadlines []time.Time
+	start := time.Now()
+	_, err = instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		attemptDeadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		attemptDeadlines = append(attemptDeadlines, attemptDeadline)
+		if len(attemptDeadlines) == 1 {
+			return nil, nil, status.Error(codes.Internal, "internal error")
+		}
+		<-ctx.Done()
+		return nil, nil, status.FromContextError(ctx.Err()).Err()
+	})
+	assert.Error(t, err)
+	assert.True(t, goerrors.Is(err, context.DeadlineExceeded))
+
+	// Each attempt is bounded by its share of the remaining deadline, and the request fails without
+	// waiting for the deadline once another attempt cannot be made
+	assert.Len(t, attemptDeadlines, 2)
+	assert.True(t, attemptDeadlines[0].Before(start.Add(time.Second)))
+	assert.True(t, attemptDeadlines[1].Before(deadline.Add(-500*time.Millisecond)))
+	assert.True(t, time.Now().Before(deadline))
+}
+
+func TestSessionExpire(t *testing.T) {
+	// Start a partition that enforces a shorter session timeout than the session requests
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(server, &timeoutSessionServer{timeout: 200 * time.Millisecond})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	partition := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+	session, err := primitive.NewSession(context.TODO(), partition, primitive.WithSessionTimeout(time.Second), primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer session.Close()
+
+	expired := make(chan struct{})
+	session.OnExpire(func() {
+		close(expired)
+	})
+
+	select {
+	case <-expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not expire")
+	}
+}
+
+func TestSessionDone(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(server, &timeoutSessionServer{timeout: 200 * time.Millisecond})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	partition := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+
+	// Abandon a session and let it expire
+	expiring, err := primitive.NewSession(context.TODO(), partition, primitive.WithSessionTimeout(time.Second), primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer expiring.Close()
+
+	select {
+	case <-expiring.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not end")
+	}
+
+	// Close a session explicitly
+	closing, err := primitive.NewSession(context.TODO(), partition, primitive.WithSessionTimeout(time.Minute), primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	done := closing.Done()
+	select {
+	case <-done:
+		t.Fatal("session ended before it was closed")
+	default:
+	}
+	assert.NoError(t, closing.Close())
+	assert.NoError(t, closing.Close())
+	<-done
+	<-closing.Done()
+}
+
+type blockingSessionServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+}
+
+func (s *blockingSessionServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	<-ctx.Done()
+	return nil, status.FromContextError(ctx.Err()).Err()
+}
+
+// sessionWatchers returns the number of goroutines binding a session to its context
+func sessionWatchers() int {
+	buf := make([]byte, 1<<20)
+	return strings.Count(string(buf[:runtime.Stack(buf, true)]), "primitive.NewSessionWithContext.func")
+}
+
+func awaitSessionWatchers(t *testing.T, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for sessionWatchers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d session watchers, found %d", n, sessionWatchers())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSessionWithContext(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	// A context cancelled before the session is opened fails the open
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	session, err := primitive.NewSessionWithContext(ctx, partitions[0])
+	assert.Error(t, err)
+	assert.True(t, errors.IsCanceled(err))
+	assert.Nil(t, session)
+	awaitSessionWatchers(t, 0)
+
+	// A deadline exceeded while the session is being opened fails the open
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(server, &blockingSessionServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	blocking := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	start := time.Now()
+	session, err = primitive.NewSessionWithContext(ctx, blocking, primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	cancel()
+	assert.Error(t, err)
+	assert.True(t, errors.IsTimeout(err))
+	assert.Nil(t, session)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	awaitSessionWatchers(t, 0)
+
+	// Cancelling the context closes the session once
+	ctx, cancel = context.WithCancel(context.Background())
+	session, err = primitive.NewSessionWithContext(ctx, partitions[0])
+	assert.NoError(t, err)
+	assert.True(t, session.Ready())
+	cancel()
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session was not closed when its context was cancelled")
+	}
+	assert.False(t, session.Ready())
+	assert.NoError(t, session.Close())
+	awaitSessionWatchers(t, 0)
+
+	// Closing the session releases its context, so cancelling it later does not touch the session
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	session, err = primitive.NewSessionWithContext(ctx, partitions[0])
+	assert.NoError(t, err)
+	awaitSessionWatchers(t, 1)
+	assert.NoError(t, session.Close())
+	awaitSessionWatchers(t, 0)
+	cancel()
+	assert.NoError(t, session.Close())
+
+	// A session that expires releases its context too
+	lis, err = net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	expiringServer := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(expiringServer, &timeoutSessionServer{timeout: 200 * time.Millisecond})
+	go expiringServer.Serve(lis)
+	defer expiringServer.Stop()
+
+	expiring := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	session, err = primitive.NewSessionWithContext(ctx, expiring, primitive.WithSessionTimeout(time.Second), primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer session.Close()
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not expire")
+	}
+	awaitSessionWatchers(t, 0)
+}
+
+func TestSessionCallOptions(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(server, &headerSessionServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	partition := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+
+	// The header call option records the response headers of the RPCs sent by the session
+	var md metadata.MD
+	session, err := primitive.NewSession(context.TODO(), partition,
+		primitive.WithSessionTimeout(time.Minute),
+		primitive.WithLeaderCache(primitive.NewLeaderCache()),
+		primitive.WithCallOptions(grpc.Header(&md)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"OpenSession"}, md.Get("method"))
+	assert.NoError(t, session.Close())
+	assert.Equal(t, []string{"CloseSession"}, md.Get("method"))
+
+	// Options that cannot be satisfied cause the session's RPCs to fail
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = primitive.NewSession(ctx, partition,
+		primitive.WithSessionTimeout(time.Minute),
+		primitive.WithLeaderCache(primitive.NewLeaderCache()),
+		primitive.WithCallOptions(grpc.MaxCallRecvMsgSize(1)))
+	assert.Error(t, err)
+}
+
+// headerSessionServer is a session service that sends the name of each method in the response headers
+type headerSessionServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+}
+
+func (s *headerSessionServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs("method", "OpenSession")); err != nil {
+		return nil, err
+	}
+	return &sessionapi.OpenSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *headerSessionServer) CloseSession(ctx context.Context, request *sessionapi.CloseSessionRequest) (*sessionapi.CloseSessionResponse, error) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs("method", "CloseSession")); err != nil {
+		return nil, err
+	}
+	return &sessionapi.CloseSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+// timeoutSessionServer is a session service that expires sessions that are not kept alive within its timeout
+type timeoutSessionServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+	timeout time.Duration
+	updated time.Time
+	mu      sync.Mutex
+}
+
+func (s *timeoutSessionServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updated = time.Now()
+	return &sessionapi.OpenSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *timeoutSessionServer) KeepAlive(ctx context.Context, request *sessionapi.KeepAliveRequest) (*sessionapi.KeepAliveResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.updated) > s.timeout {
+		return &sessionapi.KeepAliveResponse{
+			Header: &headers.ResponseHeader{
+				Status:  headers.ResponseStatus_ERROR,
+				Message: fmt.Sprintf("unknown session %d", request.Header.SessionID),
+			},
+		}, nil
+	}
+	s.updated = time.Now()
+	return &sessionapi.KeepAliveResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *timeoutSessionServer) CloseSession(ctx context.Context, request *sessionapi.CloseSessionRequest) (*sessionapi.CloseSessionResponse, error) {
+	return &sessionapi.CloseSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func TestSessionMaxMissedKeepAlives(t *testing.T) {
+	failing := &failingSessionServer{
+		fail: func(n int) bool {
+			return true
+		},
+	}
+	intermittent := &failingSessionServer{
+		fail: func(n int) bool {
+			return n%3 != 0
+		},
+	}
+
+	open := func(server *failingSessionServer) *primitive.Session {
+		lis, err := net.Listen("tcp", "localhost:0")
+		assert.NoError(t, err)
+		s := grpc.NewServer()
+		sessionapi.RegisterSessionServiceServer(s, server)
+		go s.Serve(lis)
+
+		partition := primitive.Partition{
+			ID:      1,
+			Address: netutil.Address(lis.Addr().String()),
+		}
+		manager := primitive.NewSessionManager(20 * time.Millisecond)
+		session, err := primitive.NewSession(context.TODO(), partition,
+			primitive.WithSessionTimeout(time.Minute),
+			primitive.WithSessionManager(manager),
+			primitive.WithMaxMissedKeepAlives(3),
+			primitive.WithLeaderCache(primitive.NewLeaderCache()))
+		assert.NoError(t, err)
+		go func() {
+			<-session.Done()
+			manager.Close()
+			s.Stop()
+		}()
+		return session
+	}
+
+	// A session that misses three consecutive keep-alives expires
+	session := open(failing)
+	defer session.Close()
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not expire")
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 3, failing.count())
+
+	name := primitive.NewName("default", "test", "default", "test")
+	_, err := counter.New(context.TODO(), name, []*primitive.Session{session})
+	assert.Error(t, err)
+	assert.True(t, goerrors.Is(err, primitive.ErrSessionExpired))
+
+	// A session that never misses three consecutive keep-alives is kept alive
+	session = open(intermittent)
+	defer session.Close()
+	select {
+	case <-session.Done():
+		t.Fatal("session expired")
+	case <-time.After(500 * time.Millisecond):
+	}
+	assert.True(t, intermittent.count() > 6)
+}
+
+// failingSessionServer is a session server that fails the keep-alives for which fail returns true
+// Keep-alives are numbered from 1.
+type failingSessionServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+	fail       func(n int) bool
+	keepAlives int
+	mu         sync.Mutex
+}
+
+func (s *failingSessionServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keepAlives
+}
+
+func (s *failingSessionServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	return &sessionapi.OpenSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *failingSessionServer) KeepAlive(ctx context.Context, request *sessionapi.KeepAliveRequest) (*sessionapi.KeepAliveResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keepAlives++
+	if s.fail(s.keepAlives) {
+		return &sessionapi.KeepAliveResponse{
+			Header: &headers.ResponseHeader{
+				Status:  headers.ResponseStatus_UNAVAILABLE,
+				Message: "unavailable",
+			},
+		}, nil
+	}
+	return &sessionapi.KeepAliveResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *failingSessionServer) CloseSession(ctx context.Context, request *sessionapi.CloseSessionRequest) (*sessionapi.CloseSessionResponse, error) {
+	return &sessionapi.CloseSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func TestSessionHeaderInterceptor(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	recorder := &recordingSessionServer{}
+	sessionapi.RegisterSessionServiceServer(server, recorder)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	partition := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+
+	// The interceptor stamps each header with a distinct index, so the headers received by the server can be
+	// matched with the headers observed by the interceptor
+	var session *primitive.Session
+	mu := sync.Mutex{}
+	intercepted := make([]uint64, 0)
+	manager := primitive.NewSessionManager(20 * time.Millisecond)
+	defer manager.Close()
+	s, err := primitive.NewSession(context.TODO(), partition,
+		primitive.WithSessionTimeout(time.Minute),
+		primitive.WithSessionManager(manager),
+		primitive.WithLeaderCache(primitive.NewLeaderCache()),
+		primitive.WithHeaderInterceptor(func(header *headers.RequestHeader) {
+			mu.Lock()
+			defer mu.Unlock()
+			// The interceptor is called without holding the session's locks
+			if session != nil {
+				session.LastIndex()
+			}
+			header.Index = uint64(len(intercepted) + 1000)
+			intercepted = append(intercepted, header.Index)
+		}))
+	assert.NoError(t, err)
+	mu.Lock()
+	session = s
+	mu.Unlock()
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, session.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	received := recorder.indexes()
+	assert.True(t, len(received) > 2)
+	assert.ElementsMatch(t, intercepted, received)
+}
+
+// recordingSessionServer is a session server that records the index of each request header it receives
+type recordingSessionServer struct {
+	sessionapi.UnimplementedSessionServiceServer
+	received []uint64
+	mu       sync.Mutex
+}
+
+func (s *recordingSessionServer) record(header *headers.RequestHeader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, header.Index)
+}
+
+func (s *recordingSessionServer) indexes() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64{}, s.received...)
+}
+
+func (s *recordingSessionServer) OpenSession(ctx context.Context, request *sessionapi.OpenSessionRequest) (*sessionapi.OpenSessionResponse, error) {
+	s.record(request.Header)
+	return &sessionapi.OpenSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *recordingSessionServer) KeepAlive(ctx context.Context, request *sessionapi.KeepAliveRequest) (*sessionapi.KeepAliveResponse, error) {
+	s.record(request.Header)
+	return &sessionapi.KeepAliveResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func (s *recordingSessionServer) CloseSession(ctx context.Context, request *sessionapi.CloseSessionRequest) (*sessionapi.CloseSessionResponse, error) {
+	s.record(request.Header)
+	return &sessionapi.CloseSessionResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: 1,
+			Index:     1,
+		},
+	}, nil
+}
+
+func TestSessionConfirmKeepAlive(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(server, &timeoutSessionServer{timeout: -time.Second})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	partition := primitive.Partition{
+		ID:      1,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+
+	// Without confirmation, the rejected keep-alives are not observed until the session expires
+	session, err := primitive.NewSession(context.TODO(), partition, primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	assert.NoError(t, session.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	session, err = primitive.NewSession(ctx, partition, primitive.WithLeaderCache(primitive.NewLeaderCache()), primitive.WithConfirmKeepAlive())
+	assert.Error(t, err)
+	assert.Nil(t, session)
+	assert.NoError(t, ctx.Err())
+}
+
+func TestSessionManager(t *testing.T) {
+	manager := primitive.NewSessionManager(100 * time.Millisecond)
+	defer manager.Close()
+
+	// Start partitions that expire sessions more quickly than the sessions' own tickers would keep them alive
+	partitions := make([]primitive.Partition, 3)
+	sessions := make([]*primitive.Session, 3)
+	expired := make([]chan struct{}, 3)
+	for i := range sessions {
+		lis, err := net.Listen("tcp", "localhost:0")
+		assert.NoError(t, err)
+		server := grpc.NewServer()
+		sessionapi.RegisterSessionServiceServer(server, &timeoutSessionServer{timeout: 300 * time.Millisecond})
+		go server.Serve(lis)
+		defer server.Stop()
+
+		partition := primitive.Partition{
+			ID:      i + 1,
+			Address: netutil.Address(lis.Addr().String()),
+		}
+		partitions[i] = partition
+		session, err := primitive.NewSession(context.TODO(), partition,
+			primitive.WithSessionTimeout(time.Second),
+			primitive.WithLeaderCache(primitive.NewLeaderCache()),
+			primitive.WithSessionManager(manager))
+		assert.NoError(t, err)
+		defer session.Close()
+		sessions[i] = session
+
+		ch := make(chan struct{})
+		session.OnExpire(func() {
+			close(ch)
+		})
+		expired[i] = ch
+	}
+	assert.Equal(t, 3, manager.Len())
+
+	time.Sleep(time.Second)
+	for _, ch := range expired {
+		select {
+		case <-ch:
+			t.Fatal("managed session expired")
+		default:
+		}
+	}
+
+	// Closed sessions are removed from the manager
+	assert.NoError(t, sessions[2].Close())
+	assert.Equal(t, 2, manager.Len())
+
+	// Sessions kept alive by their own tickers expire
+	session, err := primitive.NewSession(context.TODO(), partitions[2],
+		primitive.WithSessionTimeout(time.Second),
+		primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer session.Close()
+	ch := make(chan struct{})
+	session.OnExpire(func() {
+		close(ch)
+	})
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not expire")
+	}
+
+	// Sessions cannot be opened with a closed manager
+	manager.Close()
+	_, err = primitive.NewSession(context.TODO(), partitions[0], primitive.WithLeaderCache(primitive.NewLeaderCache()), primitive.WithSessionManager(manager))
+	assert.True(t, errors.IsUnavailable(err))
+}
+
+func TestSessionReconnectStrategy(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	// Start a follower that redirects all requests to the partition
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	sessionapi.RegisterSessionServiceServer(server, &redirectingSessionServer{leader: string(partitions[0].Address)})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	partition := primitive.Partition{
+		ID:      partitions[0].ID,
+		Address: netutil.Address(lis.Addr().String()),
+	}
+	strategy := &testReconnectStrategy{
+		ReconnectStrategy: primitive.DefaultReconnectStrategy(),
+		maxAttempts:       3,
+	}
+	session, err := primitive.NewSession(context.TODO(), partition, primitive.WithReconnectStrategy(strategy), primitive.WithLeaderCache(primitive.NewLeaderCache()))
+	assert.NoError(t, err)
+	defer session.Close()
+	assert.Equal(t, []netutil.Address{partitions[0].Address}, strategy.redirects)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, session, &counterHandler{})
+	assert.NoError(t, err)
+	defer instance.Close(context.TODO())
+
+	// Failed requests are retried with the strategy's delay until the strategy gives up
+	attempts := 0
+	start := time.Now()
+	_, err = instance.DoQuery(context.TODO(), func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		attempts++
+		return nil, nil, status.Error(codes.Internal, "internal error")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, codes.Internal, status.Code(goerrors.Unwrap(err)))
+	assert.Equal(t, 3, attempts)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+// testReconnectStrategy is a ReconnectStrategy that limits the number of attempts and records redirects
+type testReconnectStrategy struct {
+	primitive.ReconnectStrategy
+	maxAttempts int
+	delay       time.Duration
+	redirects   []netutil.Address
+	mu          sync.Mutex
+}
+
+func (s *testReconnectStrategy) ShouldRetry(attempt int, err error) bool {
+	return attempt < s.maxAttempts && s.ReconnectStrategy.ShouldRetry(attempt, err)
+}
+
+func (s *testReconnectStrategy) NextDelay(attempt int) time.Duration {
+	if s.delay != 0 {
+		return s.delay
+	}
+	return 10 * time.Millisecond
+}
+
+func (s *testReconnectStrategy) PickAddress(partition primitive.Partition, current netutil.Address, leader netutil.Address, err error) netutil.Address {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if leader != "" {
+		s.redirects = append(s.redirects, leader)
+	}
+	return s.ReconnectStrategy.PickAddress(partition, current, leader, err)
+}
+
+func TestSessionStaleRead(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+
+	// Start a replica that serves counter reads
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	replica := &replicaCounterServer{}
+	counterapi.RegisterCounterServiceServer(server, replica)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	strategy := &testReconnectStrategy{
+		ReconnectStrategy: primitive.DefaultReconnectStrategy(),
+		maxAttempts:       3,
+	}
+	session, err := primitive.NewSession(context.TODO(), partitions[0],
+		primitive.WithReconnectStrategy(strategy),
+		primitive.WithLeaderCache(primitive.NewLeaderCache()),
+		primitive.WithReplicas(netutil.Address(lis.Addr().String())))
+	assert.NoError(t, err)
+	defer session.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, session, &counterHandler{})
+	assert.NoError(t, err)
+
+	get := func(opts ...primitive.QueryOption) (interface{}, error) {
+		return instance.DoQuery(context.TODO(), func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			header.Index = 0
+			response, err := counterapi.NewCounterServiceClient(conn).Get(ctx, &counterapi.GetRequest{Header: header})
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		}, opts...)
+	}
+
+	// Stale reads are served by the leader while it's reachable
+	response, err := get(primitive.WithStaleRead(0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), response.(*counterapi.GetResponse).Value)
+
+	// Once the leader is down, only stale reads succeed, and they're served by the replica within their lag
+	test.StopTestPartitions(closers)
+	time.Sleep(100 * time.Millisecond)
+	lastIndex := session.LastIndex()
+	assert.True(t, lastIndex >= 2)
+	replica.index = lastIndex - 2
+
+	_, err = get()
+	assert.Error(t, err)
+
+	response, err = get(primitive.WithStaleRead(0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), response.(*counterapi.GetResponse).Value)
+
+	response, err = get(primitive.WithStaleRead(2))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), response.(*counterapi.GetResponse).Value)
+
+	_, err = get(primitive.WithStaleRead(1))
+	assert.Error(t, err)
+}
+
+// replicaCounterServer is a counter replica that serves reads at a fixed index
+type replicaCounterServer struct {
+	counterapi.UnimplementedCounterServiceServer
+	index uint64
+}
+
+func (s *replicaCounterServer) Get(ctx context.Context, request *counterapi.GetRequest) (*counterapi.GetResponse, error) {
+	return &counterapi.GetResponse{
+		Header: &headers.ResponseHeader{
+			SessionID: request.Header.SessionID,
+			Index:     s.index,
+		},
+		Value: 42,
+	}, nil
+}
+
+func TestSessionInitialIndex(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	writer, err := primitive.NewSession(context.TODO(), partitions[0])
+	assert.NoError(t, err)
+	defer writer.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	writes, err := _map.New(context.TODO(), name, []*primitive.Session{writer})
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = writes.Put(context.TODO(), "foo", []byte(fmt.Sprintf("bar-%d", i)))
+		assert.NoError(t, err)
+	}
+	index := writer.LastIndex()
+	assert.NotEqual(t, uint64(0), index)
+
+	reader, err := primitive.NewSession(context.TODO(), partitions[0], primitive.WithInitialIndex(index))
+	assert.NoError(t, err)
+	defer reader.Close()
+	assert.True(t, reader.LastIndex() >= index)
+
+	reads, err := _map.New(context.TODO(), name, []*primitive.Session{reader})
+	assert.NoError(t, err)
+	entry, err := reads.Get(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar-9", string(entry.Value))
+	assert.True(t, reader.LastIndex() >= index)
+
+	// The initial index is applied again when the session is reopened
+	assert.NoError(t, reader.Reopen(context.TODO()))
+	assert.True(t, reader.LastIndex() >= index)
+}
+
+func TestSessionIDGenerator(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	// By default, each session is assigned a unique ID
+	session1, err := primitive.NewSession(context.TODO(), partitions[0])
+	assert.NoError(t, err)
+	defer session1.Close()
+	session2, err := primitive.NewSession(context.TODO(), partitions[0])
+	assert.NoError(t, err)
+	defer session2.Close()
+	assert.NotEmpty(t, session1.ID())
+	assert.NotEqual(t, session1.ID(), session2.ID())
+
+	// A custom generator is called once for each session
+	mu := sync.Mutex{}
+	next := 0
+	generator := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		next++
+		return fmt.Sprintf("session-%d", next)
+	}
+	session3, err := primitive.NewSession(context.TODO(), partitions[0], primitive.WithIDGenerator(generator))
+	assert.NoError(t, err)
+	defer session3.Close()
+	session4, err := primitive.NewSession(context.TODO(), partitions[0], primitive.WithIDGenerator(generator))
+	assert.NoError(t, err)
+	defer session4.Close()
+	assert.Equal(t, "session-1", session3.ID())
+	assert.Equal(t, "session-2", session4.ID())
+
+	// The ID does not change when the session is reopened
+	assert.NoError(t, session3.Reopen(context.TODO()))
+	assert.Equal(t, "session-1", session3.ID())
+	assert.Equal(t, 2, next)
+}
+
+func TestSessionStreamBuffer(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions, primitive.WithStreamBuffer(10))
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, sessions[0], &counterHandler{})
+	assert.NoError(t, err)
+
+	// The stream fills the buffer and is then closed cleanly before any of the responses have been consumed
+	responses := make(chan *headers.ResponseHeader, 12)
+	responses <- &headers.ResponseHeader{Type: headers.ResponseType_OPEN_STREAM, ResponseID: 1}
+	for i := 2; i <= 11; i++ {
+		responses <- &headers.ResponseHeader{Type: headers.ResponseType_RESPONSE, ResponseID: uint64(i)}
+	}
+	responses <- &headers.ResponseHeader{Type: headers.ResponseType_CLOSE_STREAM, ResponseID: 12}
+
+	closed := make(chan struct{})
+	ch, err := instance.DoCommandStream(context.TODO(),
+		func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
+			return responses, nil
+		},
+		func(responses interface{}) (*headers.ResponseHeader, interface{}, error) {
+			header := <-responses.(chan *headers.ResponseHeader)
+			if header.Type == headers.ResponseType_CLOSE_STREAM {
+				close(closed)
+			}
+			return header, header.ResponseID, nil
+		})
+	assert.NoError(t, err)
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed")
+	}
+
+	received := make([]uint64, 0)
+	for response := range ch {
+		received = append(received, response.(uint64))
+	}
+	assert.Equal(t, []uint64{2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, received)
+
+	// If the context is canceled, the buffered responses are dropped
+	ctx, cancel := context.WithCancel(context.Background())
+	responses = make(chan *headers.ResponseHeader, 3)
+	responses <- &headers.ResponseHeader{Type: headers.ResponseType_OPEN_STREAM, ResponseID: 1}
+	responses <- &headers.ResponseHeader{Type: headers.ResponseType_RESPONSE, ResponseID: 2}
+	responses <- &headers.ResponseHeader{Type: headers.ResponseType_RESPONSE, ResponseID: 3}
+	delivered := make(chan struct{})
+	ch, err = instance.DoCommandStream(ctx,
+		func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
+			return responses, nil
+		},
+		func(responses interface{}) (*headers.ResponseHeader, interface{}, error) {
+			select {
+			case header := <-responses.(chan *headers.ResponseHeader):
+				return header, header.ResponseID, nil
+			default:
+				close(delivered)
+				<-ctx.Done()
+				return nil, nil, ctx.Err()
+			}
+		})
+	assert.NoError(t, err)
+
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("responses were not buffered")
+	}
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	late := 0
+	for range ch {
+		late++
+	}
+	assert.Equal(t, 0, late)
+}
+
+func TestSharedSession(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	shared := primitive.Shared(sessions[0])
+	assert.Equal(t, 0, shared.Refs())
+
+	const n = 3
+	maps := make([]_map.Map, n)
+	for i := 0; i < n; i++ {
+		name := primitive.NewName("default", "test", "default", fmt.Sprintf("test-%d", i))
+		maps[i], err = _map.New(context.TODO(), name, []*primitive.Session{shared.Session})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, n, shared.Refs())
+
+	// Closing all but one of the primitives keeps the session open
+	for i := 0; i < n-1; i++ {
+		assert.NoError(t, maps[i].Close(context.TODO()))
+		assert.NoError(t, maps[i].Close(context.TODO()))
+	}
+	assert.Equal(t, 1, shared.Refs())
+	_, err = maps[n-1].Put(context.TODO(), "foo", []byte("bar"))
+	assert.NoError(t, err)
+
+	// Closing the last primitive closes the session, so the partition no longer accepts its requests
+	assert.NoError(t, maps[n-1].Close(context.TODO()))
+	assert.Equal(t, 0, shared.Refs())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = _map.New(ctx, primitive.NewName("default", "test", "default", "test"), []*primitive.Session{shared.Session})
+	assert.Error(t, err)
+}
+
+// BenchmarkSessionKeepAlive compares sessions kept alive by their own tickers with sessions kept alive by a
+// SessionManager
+// Each op is one keep-alive interval with all the sessions open. The number of goroutines running while the
+// sessions are open is reported as the goroutines metric.
+func BenchmarkSessionKeepAlive(b *testing.B) {
+	const sessions = 100
+	const timeout = time.Second
+
+	benchmark := func(b *testing.B, opts ...primitive.SessionOption) {
+		partitions, closers := test.StartTestPartitions(1)
+		defer test.StopTestPartitions(closers)
+
+		goroutines := runtime.NumGoroutine()
+		opts = append(opts, primitive.WithSessionTimeout(timeout), primitive.WithLeaderCache(primitive.NewLeaderCache()))
+		open := make([]*primitive.Session, 0, sessions)
+		for i := 0; i < sessions; i++ {
+			session, err := primitive.NewSession(context.TODO(), partitions[0], opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			open = append(open, session)
+		}
+		running := runtime.NumGoroutine() - goroutines
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			time.Sleep(timeout / 2)
+		}
+		b.StopTimer()
+		b.ReportMetric(float64(running), "goroutines")
+		test.CloseSessions(open)
+	}
+
+	b.Run("PerSession", func(b *testing.B) {
+		benchmark(b)
+	})
+	b.Run("Managed", func(b *testing.B) {
+		manager := primitive.NewSessionManager(timeout / 2)
+		defer manager.Close()
+		benchmark(b, primitive.WithSessionManager(manager))
+	})
+}
+
+func TestSessionRetryBackoff(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	session, err := primitive.NewSession(context.TODO(), partitions[0], primitive.WithRetryBackoff(20*time.Millisecond, 40*time.Millisecond))
+	assert.NoError(t, err)
+	defer session.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, session, &counterHandler{})
+	assert.NoError(t, err)
+	defer instance.Close(context.TODO())
+
+	// Redirects without a leader are retried with an increasing delay after the first redirect
+	attempts := make([]time.Time, 0)
+	_, err = instance.DoQuery(context.TODO(), func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		attempts = append(attempts, time.Now())
+		if len(attempts) < 5 {
+			return &headers.ResponseHeader{Status: headers.ResponseStatus_NOT_LEADER}, nil, nil
+		}
+		return &headers.ResponseHeader{Status: headers.ResponseStatus_OK}, nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, attempts, 5)
+	minDelays := []time.Duration{0, 10 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond}
+	for i, minDelay := range minDelays {
+		assert.True(t, attempts[i+1].Sub(attempts[i]) >= minDelay)
+	}
+
+	// A request that is redirected until its context is done fails with the context's error
+	redirects := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		redirects++
+		return &headers.ResponseHeader{Status: headers.ResponseStatus_NOT_LEADER}, nil, nil
+	})
+	assert.True(t, goerrors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < time.Second)
+	assert.True(t, redirects < 10)
+}
+
+func TestSessionRequestCanceled(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	session, err := primitive.NewSession(context.TODO(), partitions[0])
+	assert.NoError(t, err)
+	defer session.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, session, &counterHandler{})
+	assert.NoError(t, err)
+	defer instance.Close(context.TODO())
+
+	// A request whose context is canceled while it's being redirected is not retried
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attempts := 0
+	start := time.Now()
+	_, err = instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		attempts++
+		if attempts == 3 {
+			cancel()
+		}
+		return &headers.ResponseHeader{Status: headers.ResponseStatus_NOT_LEADER}, nil, nil
+	})
+	assert.True(t, goerrors.Is(err, context.Canceled))
+	assert.True(t, errors.IsCanceled(err))
+	assert.Equal(t, 3, attempts)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// A request whose context is canceled after the first redirect is not retried
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	attempts = 0
+	_, err = instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		attempts++
+		cancel()
+		return &headers.ResponseHeader{Status: headers.ResponseStatus_NOT_LEADER}, nil, nil
+	})
+	assert.True(t, goerrors.Is(err, context.Canceled))
+	assert.True(t, errors.IsCanceled(err))
+	assert.Equal(t, 1, attempts)
+
+	// A request whose context is canceled while it's backing off after a failure fails with a Canceled error
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	_, err = instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		time.AfterFunc(50*time.Millisecond, cancel)
+		return nil, nil, status.Error(codes.Unavailable, "unavailable")
+	})
+	assert.True(t, goerrors.Is(err, context.Canceled))
+	assert.True(t, errors.IsCanceled(err))
+
+	// A request whose deadline expires before it can be retried fails with a Timeout error
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		return nil, nil, status.Error(codes.Unavailable, "unavailable")
+	})
+	assert.True(t, goerrors.Is(err, context.DeadlineExceeded))
+	assert.True(t, errors.IsTimeout(err))
+}
+
+func TestSessionBatch(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	session, err := primitive.NewSession(context.TODO(), partitions[0])
+	assert.NoError(t, err)
+	defer session.Close()
+
+	name := primitive.NewName("default", "test", "default", "test")
+	instance, err := primitive.NewInstance(context.TODO(), name, session, &counterHandler{})
+	assert.NoError(t, err)
+	defer instance.Close(context.TODO())
+
+	increment := func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		response, err := counterapi.NewCounterServiceClient(conn).Increment(ctx, &counterapi.IncrementRequest{Header: header, Delta: 1})
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	}
+	get := func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+		response, err := counterapi.NewCounterServiceClient(conn).Get(ctx, &counterapi.GetRequest{Header: header})
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Header, response, nil
+	}
+
+	// A batch waiting behind a blocked lock does not block the commands that release the lock
+	lockName := primitive.NewName("default", "test", "default", "lock")
+	l1, err := lock.New(context.TODO(), lockName, []*primitive.Session{session})
+	assert.NoError(t, err)
+	l2, err := lock.New(context.TODO(), lockName, []*primitive.Session{session})
+	assert.NoError(t, err)
+	_, err = l1.Lock(context.TODO())
+	assert.NoError(t, err)
+	locked := make(chan error)
+	go func() {
+		_, err := l2.Lock(context.TODO())
+		locked <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	batched := make(chan error)
+	go func() {
+		_, err := instance.DoBatch(context.TODO(), []primitive.CommandFunc{increment, increment})
+		batched <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	unlocked, err := l1.Unlock(ctx)
+	assert.NoError(t, err)
+	assert.True(t, unlocked)
+	assert.NoError(t, <-locked)
+	assert.NoError(t, <-batched)
+
+	// A batch aborted by its context does not block the commands sent after it
+	batchCtx, batchCancel := context.WithCancel(context.Background())
+	results, err := instance.DoBatch(batchCtx, []primitive.CommandFunc{
+		increment,
+		func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			batchCancel()
+			return increment(ctx, conn, header)
+		},
+		increment,
+	})
+	assert.Error(t, err)
+	assert.Len(t, results, 1)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = instance.DoCommand(ctx, increment)
+	assert.NoError(t, err)
+	response, err := instance.DoQuery(context.TODO(), get)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), response.(*counterapi.GetResponse).Value)
+
+	// A batch aborted by an error status does not block the commands sent after it
+	results, err = instance.DoBatch(context.TODO(), []primitive.CommandFunc{
+		increment,
+		func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			responseHeader, response, err := increment(ctx, conn, header)
+			if err != nil {
+				return nil, nil, err
+			}
+			conflict := *responseHeader
+			conflict.Status = headers.ResponseStatus_CONFLICT
+			return &conflict, response, nil
+		},
+		increment,
+	})
+	assert.True(t, errors.IsConflict(err))
+	assert.Len(t, results, 1)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = instance.DoCommand(ctx, increment)
+	assert.NoError(t, err)
+	response, err = instance.DoQuery(context.TODO(), get)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), response.(*counterapi.GetResponse).Value)
+}
@@ -0,0 +1,67 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+// Shared returns a SharedSession that closes the given session once the last primitive created with it is closed
+// Each primitive instance created with the session after it's shared holds a reference to the session, which is
+// released when the instance is closed or deleted. Once all references have been released, the session is
+// closed. Instances created with the session before it was shared do not hold references. The session may
+// still be closed explicitly, in which case it's closed regardless of the instances holding references.
+func Shared(session *Session) *SharedSession {
+	session.mu.Lock()
+	session.shared = true
+	session.mu.Unlock()
+	return &SharedSession{
+		Session: session,
+	}
+}
+
+// SharedSession is a reference-counted session shared by multiple primitives
+type SharedSession struct {
+	*Session
+}
+
+// Refs returns the number of primitive instances holding references to the session
+func (s *SharedSession) Refs() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.refs
+}
+
+// acquire acquires a reference to the session if it's shared
+func (s *Session) acquire() {
+	s.mu.Lock()
+	if s.shared {
+		s.refs++
+	}
+	s.mu.Unlock()
+}
+
+// release releases a reference to the session if it's shared, closing the session once all references have been
+// released
+func (s *Session) release() error {
+	s.mu.Lock()
+	if !s.shared || s.refs == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	s.refs--
+	closed := s.refs == 0
+	s.mu.Unlock()
+	if closed {
+		return s.Close()
+	}
+	return nil
+}
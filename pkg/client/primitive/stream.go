@@ -0,0 +1,76 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"github.com/atomix/api/proto/atomix/headers"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+)
+
+// StreamPolicy is the policy by which a session delivers command stream responses that are received out of order
+type StreamPolicy int
+
+const (
+	// DropLate drops responses that do not immediately follow the last response delivered on the stream
+	// DropLate is the default policy. Late and duplicate responses are dropped, and if a response is missed,
+	// no further responses are delivered on the stream.
+	DropLate StreamPolicy = iota
+
+	// DeliverAll delivers all responses, including late and duplicate responses
+	// Consumers must tolerate duplicate and reordered responses. If responses are missed, delivery continues
+	// from the next response received.
+	DeliverAll
+
+	// ErrorOnGap closes the stream with an error if a response is missed
+	// Late and duplicate responses are dropped. If a response is received before the responses preceding it,
+	// the stream is closed and the error is passed to the session's stream error listeners.
+	ErrorOnGap
+)
+
+// streamErrorListener is a listener for command stream errors
+type streamErrorListener struct {
+	f func(err error)
+}
+
+// sequence sequences the given response on the stream according to the given policy
+// A bool indicating whether the response should be delivered is returned. If the policy requires the stream
+// to be closed, an error is returned.
+func (s *Stream) sequence(header *headers.ResponseHeader, policy StreamPolicy) (bool, error) {
+	switch policy {
+	case DeliverAll:
+		s.advance(header)
+		return true, nil
+	case ErrorOnGap:
+		s.mu.RLock()
+		responseID := s.responseID
+		s.mu.RUnlock()
+		if header.ResponseID > responseID+1 {
+			return false, errors.New(errors.Unavailable, "stream %d missed responses %d to %d", s.ID, responseID+1, header.ResponseID-1)
+		}
+	}
+	return s.serialize(header), nil
+}
+
+// advance advances the stream to the response ID in the given header if it's greater than the last response ID
+func (s *Stream) advance(header *headers.ResponseHeader) {
+	s.mu.Lock()
+	if header.ResponseID <= s.responseID {
+		s.mu.Unlock()
+		return
+	}
+	s.responseID = header.ResponseID
+	s.mu.Unlock()
+	s.session.invalidateStreamHeaders()
+}
@@ -0,0 +1,93 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package primitive
+
+import (
+	"context"
+	"github.com/atomix/api/proto/atomix/headers"
+	primitiveapi "github.com/atomix/api/proto/atomix/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+// streamResponses crafts a stream with the given sequence of response IDs following the stream handshake
+func streamResponses(responseIDs ...uint64) []*headers.ResponseHeader {
+	responses := []*headers.ResponseHeader{{Type: headers.ResponseType_OPEN_STREAM, ResponseID: 1}}
+	for _, responseID := range responseIDs {
+		responses = append(responses, &headers.ResponseHeader{
+			Type:       headers.ResponseType_RESPONSE,
+			Status:     headers.ResponseStatus_OK,
+			ResponseID: responseID,
+		})
+	}
+	return responses
+}
+
+// receiveStream runs the given responses through a command stream with the given policy and returns the
+// response IDs delivered and the stream errors
+func receiveStream(policy StreamPolicy, responses []*headers.ResponseHeader) ([]uint64, []error) {
+	session := newTestSession(0)
+	session.policy = policy
+	var errs []error
+	session.OnStreamError(func(err error) {
+		errs = append(errs, err)
+	})
+
+	stream, requestHeader := session.nextStreamHeader(primitiveapi.PrimitiveId{})
+	responseFunc := func(interface{}) (*headers.ResponseHeader, interface{}, error) {
+		if len(responses) == 0 {
+			return nil, nil, io.EOF
+		}
+		response := responses[0]
+		responses = responses[1:]
+		return response, response, nil
+	}
+
+	responseCh := make(chan interface{})
+	go session.commandStream(context.TODO(), nil, responseFunc, nil, stream, requestHeader, nil, responseCh)
+
+	var responseIDs []uint64
+	for response := range responseCh {
+		responseIDs = append(responseIDs, response.(*headers.ResponseHeader).ResponseID)
+	}
+	return responseIDs, errs
+}
+
+func TestStreamPolicy(t *testing.T) {
+	// Responses 2 and 3 are followed by a duplicate, a late response, and a gap at response 4
+	responses := func() []*headers.ResponseHeader {
+		return streamResponses(2, 3, 3, 2, 5, 6)
+	}
+
+	responseIDs, errs := receiveStream(DropLate, responses())
+	assert.Equal(t, []uint64{2, 3}, responseIDs)
+	assert.Len(t, errs, 0)
+
+	responseIDs, errs = receiveStream(DeliverAll, responses())
+	assert.Equal(t, []uint64{2, 3, 3, 2, 5, 6}, responseIDs)
+	assert.Len(t, errs, 0)
+
+	responseIDs, errs = receiveStream(ErrorOnGap, responses())
+	assert.Equal(t, []uint64{2, 3}, responseIDs)
+	assert.Len(t, errs, 1)
+	assert.True(t, errors.IsUnavailable(errs[0]))
+
+	// Late and duplicate responses are dropped without error if no response is missed
+	responseIDs, errs = receiveStream(ErrorOnGap, streamResponses(2, 2, 3, 1, 4))
+	assert.Equal(t, []uint64{2, 3, 4}, responseIDs)
+	assert.Len(t, errs, 0)
+}
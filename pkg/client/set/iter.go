@@ -0,0 +1,34 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build go1.23
+
+package set
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/util"
+	"iter"
+)
+
+// All returns an iterator over the elements in the given set
+// The elements are read from the set each time the iterator is ranged over, in the same order as by Elements
+// with the given options. Breaking out of the loop cancels the iteration and closes the underlying streams. If
+// the elements cannot be read, or the context is done before all the elements have been read, the error is
+// yielded with an empty element and the iteration ends. All requires Go 1.23; on earlier versions of Go, use
+// Elements.
+func All(ctx context.Context, s ReadOnlySet, opts ...ElementsOption) iter.Seq2[string, error] {
+	return util.Seq(ctx, func(ctx context.Context, ch chan<- string) error {
+		return s.Elements(ctx, ch, opts...)
+	})
+}
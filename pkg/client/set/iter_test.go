@@ -0,0 +1,60 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build go1.23
+
+package set
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+	"github.com/lucasbfernandes/go-client/pkg/client/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	set, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = set.AddAll(context.TODO(), []string{"foo", "bar", "baz"})
+	assert.NoError(t, err)
+
+	values := make([]string, 0)
+	for value, err := range All(context.TODO(), set, WithSorted()) {
+		assert.NoError(t, err)
+		values = append(values, value)
+	}
+	assert.Equal(t, []string{"bar", "baz", "foo"}, values)
+
+	// Breaking out of the loop stops the iteration
+	count := 0
+	for _, err := range All(context.TODO(), set.ReadOnly()) {
+		assert.NoError(t, err)
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+
+	contains, err := set.Contains(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.True(t, contains)
+}
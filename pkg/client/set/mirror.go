@@ -0,0 +1,173 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"context"
+	"github.com/cenkalti/backoff"
+	"sort"
+	"sync"
+)
+
+// newMirror creates a mirror of the given set
+// The mirror is synced with the set before it's returned, so a mirror returned without error reflects all
+// changes to the set that were observed before the call.
+func newMirror(ctx context.Context, set ReadOnlySet) (*SetMirror, error) {
+	mirror := &SetMirror{
+		set: set,
+	}
+	done, err := mirror.sync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go mirror.run(ctx, done)
+	return mirror, nil
+}
+
+// SetMirror is a local copy of a set that's kept in sync with the set
+// The mirror is populated from a snapshot of the set's elements and then updated from the set's change events.
+// The set is watched before the snapshot is read, and events received while the snapshot is read are applied
+// to it once it's complete, so changes made while the mirror is synced are not lost. If the watch stream is
+// closed before the mirror's context is canceled, the set is watched and read again and the new snapshot
+// replaces the members of the mirror, so changes missed while the stream was closed do not cause the mirror
+// to drift. Values removed by Clear remain in the mirror until it's synced again. A SetMirror is safe for
+// concurrent use.
+type SetMirror struct {
+	set     ReadOnlySet
+	members map[string]bool
+	pending []*Event
+	synced  bool
+	mu      sync.RWMutex
+}
+
+// Members returns the members of the set in lexicographic order
+func (m *SetMirror) Members() []string {
+	m.mu.RLock()
+	members := make([]string, 0, len(m.members))
+	for value := range m.members {
+		members = append(members, value)
+	}
+	m.mu.RUnlock()
+	sort.Strings(members)
+	return members
+}
+
+// Contains returns a bool indicating whether the set contains the given value
+func (m *SetMirror) Contains(value string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.members[value]
+}
+
+// Len returns the number of members in the set
+func (m *SetMirror) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.members)
+}
+
+// run syncs the mirror each time the watch stream is closed until the context is canceled
+func (m *SetMirror) run(ctx context.Context, done <-chan struct{}) {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0
+	for {
+		<-done
+		if ctx.Err() != nil {
+			return
+		}
+		_ = backoff.Retry(func() error {
+			next, err := m.sync(ctx)
+			if err != nil {
+				return err
+			}
+			done = next
+			return nil
+		}, backoff.WithContext(b, ctx))
+	}
+}
+
+// sync watches the set and replaces the members of the mirror with a snapshot of the set
+// The returned channel is closed once the watch stream is closed.
+func (m *SetMirror) sync(ctx context.Context) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	m.mu.Lock()
+	m.pending = nil
+	m.synced = false
+	m.mu.Unlock()
+
+	events := make(chan *Event)
+	if err := m.set.Watch(ctx, events); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		for event := range events {
+			m.apply(event)
+		}
+	}()
+
+	members := make(map[string]bool)
+	elements := make(chan string)
+	if err := m.set.Elements(ctx, elements); err != nil {
+		cancel()
+		<-done
+		return nil, err
+	}
+	for value := range elements {
+		members[value] = true
+	}
+	if err := ctx.Err(); err != nil {
+		cancel()
+		<-done
+		return nil, err
+	}
+
+	m.mu.Lock()
+	for _, event := range m.pending {
+		applyEvent(members, event)
+	}
+	m.members = members
+	m.pending = nil
+	m.synced = true
+	m.mu.Unlock()
+	return done, nil
+}
+
+// apply applies the given event to the mirror, or buffers it if the mirror is being synced
+func (m *SetMirror) apply(event *Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.synced {
+		m.pending = append(m.pending, event)
+		return
+	}
+	applyEvent(m.members, event)
+}
+
+// applyEvent applies the given event to the given members
+// Applying an event is idempotent, so events for changes already reflected in the snapshot may be applied.
+func applyEvent(members map[string]bool, event *Event) {
+	switch event.Type {
+	case EventAdded:
+		members[event.Value] = true
+	case EventRemoved:
+		delete(members, event.Value)
+	}
+}
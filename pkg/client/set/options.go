@@ -38,3 +38,66 @@ func (o replayOption) beforeWatch(request *api.EventRequest) {
 func (o replayOption) afterWatch(response *api.EventResponse) {
 
 }
+
+// WithEmptinessEvents returns a Watch option that publishes an EventEmptinessChanged event when the set
+// transitions to or from empty
+// The size of the set is read once when the watch is opened and then tracked from the change events, so
+// emptiness events do not require additional requests. Events are only published for transitions that occur
+// after the watch is opened, and transitions caused by Clear are not observed.
+func WithEmptinessEvents() WatchOption {
+	return emptinessOption{}
+}
+
+type emptinessOption struct{}
+
+func (o emptinessOption) beforeWatch(request *api.EventRequest) {
+
+}
+
+func (o emptinessOption) afterWatch(response *api.EventResponse) {
+
+}
+
+// hasEmptinessEvents returns whether the given options enable emptiness events
+func hasEmptinessEvents(opts []WatchOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(emptinessOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ElementsOption is an option for set Elements calls
+type ElementsOption interface {
+	beforeElements(request *api.IterateRequest)
+	afterElements(response *api.IterateResponse)
+}
+
+// WithSorted returns an Elements option that lists the elements in lexicographic byte order
+// The elements are buffered and sorted by the client before any element is pushed onto the channel. Sorted
+// iteration requires memory proportional to the size of the set, and the first element is not delivered until
+// all elements have been read from the set.
+func WithSorted() ElementsOption {
+	return sortedOption{}
+}
+
+type sortedOption struct{}
+
+func (o sortedOption) beforeElements(request *api.IterateRequest) {
+
+}
+
+func (o sortedOption) afterElements(response *api.IterateResponse) {
+
+}
+
+// isSorted returns whether the given options enable sorted iteration
+func isSorted(opts []ElementsOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(sortedOption); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -63,6 +63,37 @@ func (s *setPartition) Add(ctx context.Context, value string) (bool, error) {
 	return response.Added, nil
 }
 
+func (s *setPartition) AddAll(ctx context.Context, values []string) (bool, error) {
+	fns := make([]primitive.CommandFunc, len(values))
+	for i, value := range values {
+		value := value
+		fns[i] = func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewSetServiceClient(conn)
+			request := &api.AddRequest{
+				Header: header,
+				Value:  value,
+			}
+			response, err := client.Add(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		}
+	}
+	results, err := s.instance.DoBatch(ctx, fns)
+	if err != nil {
+		return false, err
+	}
+
+	added := false
+	for _, result := range results {
+		if result.(*api.AddResponse).Added {
+			added = true
+		}
+	}
+	return added, nil
+}
+
 func (s *setPartition) Remove(ctx context.Context, value string) (bool, error) {
 	r, err := s.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewSetServiceClient(conn)
@@ -121,6 +152,10 @@ func (s *setPartition) Len(ctx context.Context) (int, error) {
 	return int(response.(*api.SizeResponse).Size_), nil
 }
 
+func (s *setPartition) LenApprox(ctx context.Context) (int, error) {
+	return s.Len(ctx)
+}
+
 func (s *setPartition) Clear(ctx context.Context) error {
 	_, err := s.instance.DoCommand(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
 		client := api.NewSetServiceClient(conn)
@@ -136,18 +171,30 @@ func (s *setPartition) Clear(ctx context.Context) error {
 	return err
 }
 
-func (s *setPartition) Elements(ctx context.Context, ch chan<- string) error {
+func (s *setPartition) Elements(ctx context.Context, ch chan<- string, opts ...ElementsOption) error {
+	if isSorted(opts) {
+		return sortElements(ch, func(ch chan<- string) error {
+			return s.Elements(ctx, ch)
+		})
+	}
+
 	stream, err := s.instance.DoQueryStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewSetServiceClient(conn)
 		request := &api.IterateRequest{
 			Header: header,
 		}
+		for _, opt := range opts {
+			opt.beforeElements(request)
+		}
 		return client.Iterate(ctx, request)
 	}, func(responses interface{}) (*headers.ResponseHeader, interface{}, error) {
 		response, err := responses.(api.SetService_IterateClient).Recv()
 		if err != nil {
 			return nil, nil, err
 		}
+		for _, opt := range opts {
+			opt.afterElements(response)
+		}
 		return response.Header, response, nil
 	})
 	if err != nil {
@@ -157,13 +204,30 @@ func (s *setPartition) Elements(ctx context.Context, ch chan<- string) error {
 	go func() {
 		defer close(ch)
 		for event := range stream {
-			ch <- event.(*api.IterateResponse).Value
+			select {
+			case ch <- event.(*api.IterateResponse).Value:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return nil
 }
 
 func (s *setPartition) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	_, err := s.watch(ctx, ch, opts, hasEmptinessEvents(opts))
+	return err
+}
+
+func (s *setPartition) watchEmptiness(ctx context.Context, ch chan<- *Event, opts []WatchOption) (bool, error) {
+	return s.watch(ctx, ch, opts, true)
+}
+
+// watch watches the partition for changes
+// If emptiness is tracked, the size of the partition is read once the listener has been registered, and
+// changes that occurred at or before the index at which the size was read are not counted.
+func (s *setPartition) watch(ctx context.Context, ch chan<- *Event, opts []WatchOption, emptiness bool) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	stream, err := s.instance.DoCommandStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewSetServiceClient(conn)
 		request := &api.EventRequest{
@@ -184,10 +248,34 @@ func (s *setPartition) Watch(ctx context.Context, ch chan<- *Event, opts ...Watc
 		return response.Header, response, nil
 	})
 	if err != nil {
-		return err
+		cancel()
+		return false, err
+	}
+
+	var size int
+	var index uint64
+	if emptiness {
+		response, err := s.instance.DoQuery(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (*headers.ResponseHeader, interface{}, error) {
+			client := api.NewSetServiceClient(conn)
+			request := &api.SizeRequest{
+				Header: header,
+			}
+			response, err := client.Size(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Header, response, nil
+		})
+		if err != nil {
+			cancel()
+			return false, err
+		}
+		size = int(response.(*api.SizeResponse).Size_)
+		index = response.(*api.SizeResponse).Header.Index
 	}
 
 	go func() {
+		defer cancel()
 		defer close(ch)
 		for event := range stream {
 			response := event.(*api.EventResponse)
@@ -205,9 +293,38 @@ func (s *setPartition) Watch(ctx context.Context, ch chan<- *Event, opts ...Watc
 				Type:  t,
 				Value: response.Value,
 			}
+
+			if emptiness && response.Header.Index > index {
+				switch t {
+				case EventAdded:
+					size++
+					if size == 1 {
+						ch <- &Event{
+							Type:  EventEmptinessChanged,
+							Empty: false,
+						}
+					}
+				case EventRemoved:
+					size--
+					if size == 0 {
+						ch <- &Event{
+							Type:  EventEmptinessChanged,
+							Empty: true,
+						}
+					}
+				}
+			}
 		}
 	}()
-	return nil
+	return size == 0, nil
+}
+
+func (s *setPartition) Mirror(ctx context.Context) (*SetMirror, error) {
+	return newMirror(ctx, s)
+}
+
+func (s *setPartition) ReadOnly() ReadOnlySet {
+	return newReadOnlySet(s)
 }
 
 func (s *setPartition) Close(ctx context.Context) error {
@@ -0,0 +1,91 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"context"
+	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
+)
+
+// ReadOnlySet is a read-only view of a Set
+// A ReadOnlySet does not expose the methods that modify the set, so code that's only given a ReadOnlySet
+// cannot modify the set. The view does not own the set: closing or deleting the set is left to the owner.
+type ReadOnlySet interface {
+	// Name returns the name of the set
+	Name() primitive.Name
+
+	// Contains returns a bool indicating whether the set contains the given value
+	Contains(ctx context.Context, value string) (bool, error)
+
+	// Len gets the set size in number of elements
+	Len(ctx context.Context) (int, error)
+
+	// LenApprox gets an approximate set size in number of elements
+	LenApprox(ctx context.Context) (int, error)
+
+	// Elements lists the elements in the set
+	Elements(ctx context.Context, ch chan<- string, opts ...ElementsOption) error
+
+	// Watch watches the set for changes
+	// This is a non-blocking method. If the method returns without error, set events will be pushed onto
+	// the given channel.
+	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
+
+	// Mirror returns a local copy of the set that's kept in sync with the set until the context is canceled
+	Mirror(ctx context.Context) (*SetMirror, error)
+}
+
+var _ primitive.Sized = ReadOnlySet(nil)
+
+// newReadOnlySet returns a read-only view of the given Set
+func newReadOnlySet(set Set) ReadOnlySet {
+	return &readOnlySet{
+		delegate: set,
+	}
+}
+
+// readOnlySet is a ReadOnlySet that delegates reads to an underlying Set
+// The underlying Set is not embedded so the view cannot be converted back to a Set.
+type readOnlySet struct {
+	delegate Set
+}
+
+func (s *readOnlySet) Name() primitive.Name {
+	return s.delegate.Name()
+}
+
+func (s *readOnlySet) Contains(ctx context.Context, value string) (bool, error) {
+	return s.delegate.Contains(ctx, value)
+}
+
+func (s *readOnlySet) Len(ctx context.Context) (int, error) {
+	return s.delegate.Len(ctx)
+}
+
+func (s *readOnlySet) LenApprox(ctx context.Context) (int, error) {
+	return s.delegate.LenApprox(ctx)
+}
+
+func (s *readOnlySet) Elements(ctx context.Context, ch chan<- string, opts ...ElementsOption) error {
+	return s.delegate.Elements(ctx, ch, opts...)
+}
+
+func (s *readOnlySet) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	return s.delegate.Watch(ctx, ch, opts...)
+}
+
+func (s *readOnlySet) Mirror(ctx context.Context) (*SetMirror, error) {
+	return newMirror(ctx, s)
+}
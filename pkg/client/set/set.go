@@ -12,12 +12,18 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package set provides a distributed set primitive
+//
+// The set service does not order its elements, estimate its size or publish events when it's cleared. Sorted
+// iteration therefore buffers and sorts the elements on the client, LenApprox currently returns an exact
+// count, and emptiness events and mirrors do not observe values removed by Clear until they're synced again.
 package set
 
 import (
 	"context"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/util"
+	"sort"
 	"sync"
 )
 
@@ -39,6 +45,11 @@ type Set interface {
 	// Add adds a value to the set
 	Add(ctx context.Context, value string) (bool, error)
 
+	// AddAll adds the given values to the set
+	// A bool indicating whether any of the values was added to the set will be returned. The values stored in
+	// each partition are added in a single batch.
+	AddAll(ctx context.Context, values []string) (bool, error)
+
 	// Remove removes a value from the set
 	// A bool indicating whether the set contained the given value will be returned
 	Remove(ctx context.Context, value string) (bool, error)
@@ -49,18 +60,35 @@ type Set interface {
 	// Len gets the set size in number of elements
 	Len(ctx context.Context) (int, error)
 
+	// LenApprox gets an approximate set size in number of elements
+	LenApprox(ctx context.Context) (int, error)
+
 	// Clear removes all values from the set
 	Clear(ctx context.Context) error
 
 	// Elements lists the elements in the set
-	Elements(ctx context.Context, ch chan<- string) error
+	// This is a non-blocking method. If the method returns without error, elements will be pushed on to the
+	// given channel and the channel will be closed once all elements have been read from the set. Elements
+	// are listed in no particular order unless WithSorted is passed. If the context is canceled, the iteration
+	// is stopped and the channel is closed without the remaining elements.
+	Elements(ctx context.Context, ch chan<- string, opts ...ElementsOption) error
 
 	// Watch watches the set for changes
 	// This is a non-blocking method. If the method returns without error, set events will be pushed onto
 	// the given channel.
 	Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error
+
+	// Mirror returns a local copy of the set that's kept in sync with the set until the context is canceled
+	// The mirror is populated from a snapshot of the set before Mirror returns and is then updated from the
+	// set's change events. If the watch stream is closed, the mirror is synced with a new snapshot.
+	Mirror(ctx context.Context) (*SetMirror, error)
+
+	// ReadOnly returns a read-only view of the set
+	ReadOnly() ReadOnlySet
 }
 
+var _ primitive.Sized = Set(nil)
+
 // EventType is the type of a set event
 type EventType string
 
@@ -73,6 +101,9 @@ const (
 
 	// EventRemoved indicates a value was removed from the set
 	EventRemoved EventType = "removed"
+
+	// EventEmptinessChanged indicates the set transitioned to or from empty
+	EventEmptinessChanged EventType = "emptiness-changed"
 )
 
 // Event is a set change event
@@ -82,6 +113,37 @@ type Event struct {
 
 	// Value is the value that changed
 	Value string
+
+	// Empty indicates whether the set is empty following an EventEmptinessChanged event
+	Empty bool
+}
+
+// emptinessWatcher is implemented by set partitions that can track whether they're empty while watched
+type emptinessWatcher interface {
+	// watchEmptiness watches the partition with emptiness events enabled and returns whether the
+	// partition was empty when the watch was opened
+	watchEmptiness(ctx context.Context, ch chan<- *Event, opts []WatchOption) (bool, error)
+}
+
+// sortElements lists the elements listed by the given function in sorted order
+// The elements are buffered until the function closes its channel and are then pushed onto the given channel.
+func sortElements(ch chan<- string, elements func(ch chan<- string) error) error {
+	unsorted := make(chan string)
+	if err := elements(unsorted); err != nil {
+		return err
+	}
+	go func() {
+		defer close(ch)
+		values := make([]string, 0)
+		for value := range unsorted {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			ch <- value
+		}
+	}()
+	return nil
 }
 
 // New creates a new partitioned set primitive
@@ -130,6 +192,34 @@ func (s *set) Add(ctx context.Context, value string) (bool, error) {
 	return partition.Add(ctx, value)
 }
 
+func (s *set) AddAll(ctx context.Context, values []string) (bool, error) {
+	partitionValues := make([][]string, len(s.partitions))
+	for _, value := range values {
+		i, err := util.GetPartitionIndex(value, len(s.partitions))
+		if err != nil {
+			return false, err
+		}
+		partitionValues[i] = append(partitionValues[i], value)
+	}
+
+	results, err := util.ExecuteAsync(len(s.partitions), func(i int) (interface{}, error) {
+		if len(partitionValues[i]) == 0 {
+			return false, nil
+		}
+		return s.partitions[i].AddAll(ctx, partitionValues[i])
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, result := range results {
+		if result.(bool) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (s *set) Remove(ctx context.Context, value string) (bool, error) {
 	partition, err := s.getPartition(value)
 	if err != nil {
@@ -161,7 +251,17 @@ func (s *set) Len(ctx context.Context) (int, error) {
 	return total, nil
 }
 
-func (s *set) Elements(ctx context.Context, ch chan<- string) error {
+func (s *set) LenApprox(ctx context.Context) (int, error) {
+	return s.Len(ctx)
+}
+
+func (s *set) Elements(ctx context.Context, ch chan<- string, opts ...ElementsOption) error {
+	if isSorted(opts) {
+		return sortElements(ch, func(ch chan<- string) error {
+			return s.Elements(ctx, ch)
+		})
+	}
+
 	n := len(s.partitions)
 	wg := sync.WaitGroup{}
 	wg.Add(n)
@@ -175,7 +275,10 @@ func (s *set) Elements(ctx context.Context, ch chan<- string) error {
 		partitionCh := make(chan string)
 		go func() {
 			for kv := range partitionCh {
-				ch <- kv
+				select {
+				case ch <- kv:
+				case <-ctx.Done():
+				}
 			}
 			wg.Done()
 		}()
@@ -190,6 +293,10 @@ func (s *set) Clear(ctx context.Context) error {
 }
 
 func (s *set) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption) error {
+	if hasEmptinessEvents(opts) {
+		return s.watchEmptiness(ctx, ch, opts)
+	}
+
 	n := len(s.partitions)
 	wg := sync.WaitGroup{}
 	wg.Add(n)
@@ -211,6 +318,71 @@ func (s *set) Watch(ctx context.Context, ch chan<- *Event, opts ...WatchOption)
 	})
 }
 
+// watchEmptiness watches all partitions with emptiness events enabled
+// Partitions publish events when they transition to or from empty, and the set publishes an event only
+// when the number of non-empty partitions changes to or from zero.
+func (s *set) watchEmptiness(ctx context.Context, ch chan<- *Event, opts []WatchOption) error {
+	n := len(s.partitions)
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	// Partition events are not forwarded until the initial number of non-empty partitions is known
+	mu := sync.Mutex{}
+	nonEmpty := 0
+	ready := make(chan struct{})
+	err := util.IterAsync(n, func(i int) error {
+		partitionCh := make(chan *Event)
+		go func() {
+			<-ready
+			for event := range partitionCh {
+				if event.Type != EventEmptinessChanged {
+					ch <- event
+					continue
+				}
+				mu.Lock()
+				if event.Empty {
+					nonEmpty--
+					if nonEmpty == 0 {
+						ch <- event
+					}
+				} else {
+					nonEmpty++
+					if nonEmpty == 1 {
+						ch <- event
+					}
+				}
+				mu.Unlock()
+			}
+			wg.Done()
+		}()
+		empty, err := s.partitions[i].(emptinessWatcher).watchEmptiness(ctx, partitionCh, opts)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			mu.Lock()
+			nonEmpty++
+			mu.Unlock()
+		}
+		return nil
+	})
+	close(ready)
+	return err
+}
+
+func (s *set) Mirror(ctx context.Context) (*SetMirror, error) {
+	return newMirror(ctx, s)
+}
+
+func (s *set) ReadOnly() ReadOnlySet {
+	return newReadOnlySet(s)
+}
+
 func (s *set) Close(ctx context.Context) error {
 	return util.IterAsync(len(s.partitions), func(i int) error {
 		return s.partitions[i].Close(ctx)
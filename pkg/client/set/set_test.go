@@ -16,11 +16,14 @@ package set
 
 import (
 	"context"
+	"fmt"
 	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/test"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSetOperations(t *testing.T) {
@@ -178,3 +181,268 @@ func TestSetOperations(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, size)
 }
+
+func TestSetAddAll(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	set, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	added, err := set.AddAll(context.TODO(), []string{"foo", "bar", "baz"})
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	size, err := set.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+
+	added, err = set.AddAll(context.TODO(), []string{"foo", "bar"})
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	added, err = set.AddAll(context.TODO(), []string{"foo", "qux"})
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	size, err = set.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, size)
+}
+
+func TestSetLenApprox(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	set, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	size, err := set.LenApprox(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+
+	_, err = set.AddAll(context.TODO(), []string{"foo", "bar", "baz"})
+	assert.NoError(t, err)
+
+	size, err = set.LenApprox(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+}
+
+func TestSetWatchEmptiness(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	set, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	ch := make(chan *Event)
+	err = set.Watch(context.TODO(), ch, WithEmptinessEvents())
+	assert.NoError(t, err)
+
+	next := func(n int) []*Event {
+		events := make([]*Event, 0, n)
+		for i := 0; i < n; i++ {
+			select {
+			case event := <-ch:
+				events = append(events, event)
+			case <-time.After(5 * time.Second):
+				t.Fatal("missing event")
+			}
+		}
+		return events
+	}
+
+	_, err = set.Add(context.TODO(), "foo")
+	assert.NoError(t, err)
+	events := next(2)
+	assert.Equal(t, EventAdded, events[0].Type)
+	assert.Equal(t, EventEmptinessChanged, events[1].Type)
+	assert.False(t, events[1].Empty)
+
+	for _, value := range []string{"bar", "baz"} {
+		_, err = set.Add(context.TODO(), value)
+		assert.NoError(t, err)
+		events = next(1)
+		assert.Equal(t, EventAdded, events[0].Type)
+	}
+
+	for _, value := range []string{"foo", "bar"} {
+		_, err = set.Remove(context.TODO(), value)
+		assert.NoError(t, err)
+		events = next(1)
+		assert.Equal(t, EventRemoved, events[0].Type)
+	}
+
+	_, err = set.Remove(context.TODO(), "baz")
+	assert.NoError(t, err)
+	events = next(2)
+	assert.Equal(t, EventRemoved, events[0].Type)
+	assert.Equal(t, EventEmptinessChanged, events[1].Type)
+	assert.True(t, events[1].Empty)
+
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSetReadOnly(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	set, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	view := set.ReadOnly()
+	assert.Equal(t, name, view.Name())
+	_, ok := view.(Set)
+	assert.False(t, ok)
+
+	_, err = set.AddAll(context.TODO(), []string{"foo", "bar"})
+	assert.NoError(t, err)
+
+	contains, err := view.Contains(context.TODO(), "foo")
+	assert.NoError(t, err)
+	assert.True(t, contains)
+
+	size, err := view.Len(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+
+	ch := make(chan string)
+	err = view.Elements(context.TODO(), ch)
+	assert.NoError(t, err)
+	elements := make(map[string]bool)
+	for element := range ch {
+		elements[element] = true
+	}
+	assert.Equal(t, map[string]bool{"foo": true, "bar": true}, elements)
+}
+
+func TestSetElementsSorted(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	set1, err := New(context.TODO(), primitive.NewName("default", "test", "default", "set1"), sessions)
+	assert.NoError(t, err)
+	set2, err := New(context.TODO(), primitive.NewName("default", "test", "default", "set2"), sessions)
+	assert.NoError(t, err)
+
+	_, err = set1.AddAll(context.TODO(), []string{"e", "b", "a", "d", "g"})
+	assert.NoError(t, err)
+	_, err = set2.AddAll(context.TODO(), []string{"f", "d", "b", "c", "g", "h"})
+	assert.NoError(t, err)
+
+	ch1 := make(chan string)
+	err = set1.Elements(context.TODO(), ch1, WithSorted())
+	assert.NoError(t, err)
+	ch2 := make(chan string)
+	err = set2.Elements(context.TODO(), ch2, WithSorted())
+	assert.NoError(t, err)
+
+	// Intersect the sets by merging the sorted streams
+	intersection := make([]string, 0)
+	value1, ok1 := <-ch1
+	value2, ok2 := <-ch2
+	for ok1 && ok2 {
+		switch {
+		case value1 < value2:
+			value1, ok1 = <-ch1
+		case value1 > value2:
+			value2, ok2 = <-ch2
+		default:
+			intersection = append(intersection, value1)
+			value1, ok1 = <-ch1
+			value2, ok2 = <-ch2
+		}
+	}
+	for range ch1 {
+	}
+	for range ch2 {
+	}
+	assert.Equal(t, []string{"b", "d", "g"}, intersection)
+}
+
+func TestSetMirror(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(3)
+	defer test.StopTestPartitions(closers)
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	name := primitive.NewName("default", "test", "default", "test")
+	set, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	_, err = set.AddAll(context.TODO(), []string{"foo", "bar"})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mirror, err := set.Mirror(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bar", "foo"}, mirror.Members())
+	assert.True(t, mirror.Contains("foo"))
+	assert.False(t, mirror.Contains("baz"))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				value := fmt.Sprintf("value-%d", (i*7+j)%20)
+				if j%3 == 2 {
+					_, err := set.Remove(context.TODO(), value)
+					assert.NoError(t, err)
+				} else {
+					_, err := set.Add(context.TODO(), value)
+					assert.NoError(t, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ch := make(chan string)
+	err = set.Elements(context.TODO(), ch, WithSorted())
+	assert.NoError(t, err)
+	elements := make([]string, 0)
+	for value := range ch {
+		elements = append(elements, value)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && mirror.Len() != len(elements) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, elements, mirror.Members())
+}
@@ -148,6 +148,43 @@ func ExecuteOrderedAsync(n int, f func(i int) (interface{}, error)) ([]interface
 	return results, nil
 }
 
+// ExecuteAllAsync executes the given function f up to n times concurrently, populating
+// the given results slice with the results of each function call.
+// Each call is done in a separate goroutine. On each iteration, the function f
+// will be called with a unique sequential index i such that the index can be
+// used to reference an element in an array or slice. Unlike ExecuteOrderedAsync,
+// an error returned by the function f for an index does not discard the results
+// of other indexes: the results are returned in order once all function calls
+// have completed, with a nil result for each index that failed, along with a map
+// of the errors returned for each index that failed. The map is nil if all the
+// function calls succeeded.
+func ExecuteAllAsync(n int, f func(i int) (interface{}, error)) ([]interface{}, map[int]error) {
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	results := make([]interface{}, n)
+	var errs map[int]error
+
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(j int) {
+			result, err := f(j)
+			mu.Lock()
+			if err != nil {
+				if errs == nil {
+					errs = make(map[int]error)
+				}
+				errs[j] = err
+			} else {
+				results[j] = result
+			}
+			mu.Unlock()
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+	return results, errs
+}
+
 type asyncResult struct {
 	i      int
 	result interface{}
@@ -15,6 +15,7 @@
 package util
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -62,3 +63,26 @@ func TestExecuteOrderedAsync(t *testing.T) {
 	assert.Equal(t, "two", results[1].(string))
 	assert.Equal(t, "three", results[2].(string))
 }
+
+func TestExecuteAllAsync(t *testing.T) {
+	values := []string{
+		"one",
+		"two",
+		"three",
+	}
+	results, errs := ExecuteAllAsync(len(values), func(i int) (interface{}, error) {
+		return values[i], nil
+	})
+	assert.Nil(t, errs)
+	assert.Equal(t, []interface{}{"one", "two", "three"}, results)
+
+	results, errs = ExecuteAllAsync(len(values), func(i int) (interface{}, error) {
+		if i == 1 {
+			return nil, errors.New("two")
+		}
+		return values[i], nil
+	})
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[1], "two")
+	assert.Equal(t, []interface{}{"one", nil, "three"}, results)
+}
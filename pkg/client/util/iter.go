@@ -0,0 +1,56 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build go1.23
+
+package util
+
+import (
+	"context"
+	"iter"
+)
+
+// Seq returns an iterator over the values pushed onto a channel by the given function
+// The function is called with a channel each time the iterator is ranged over and must push values onto the
+// channel and close it once all values have been pushed, as the channel-based iteration methods of the
+// primitives do. If the function fails, the error is yielded and the iteration ends. If the loop stops
+// early, the context passed to the function is canceled to stop the underlying stream, and the remaining
+// values are drained in the background. If the given context is done before all values have been pushed,
+// the context's error is yielded once the channel has been closed.
+func Seq[T any](ctx context.Context, f func(ctx context.Context, ch chan<- T) error) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		iterCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var zero T
+		ch := make(chan T)
+		if err := f(iterCtx, ch); err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for value := range ch {
+			if !yield(value, nil) {
+				cancel()
+				go func() {
+					for range ch {
+					}
+				}()
+				return
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2019-present Open Networking Foundation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build go1.23
+
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSeq(t *testing.T) {
+	values := func(n int, canceled chan<- struct{}) func(ctx context.Context, ch chan<- int) error {
+		return func(ctx context.Context, ch chan<- int) error {
+			go func() {
+				defer close(ch)
+				for i := 0; i < n; i++ {
+					select {
+					case ch <- i:
+					case <-ctx.Done():
+						close(canceled)
+						return
+					}
+				}
+			}()
+			return nil
+		}
+	}
+
+	// All values are yielded
+	result := make([]int, 0)
+	for value, err := range Seq(context.TODO(), values(5, make(chan struct{}))) {
+		assert.NoError(t, err)
+		result = append(result, value)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, result)
+
+	// Breaking out of the loop cancels the iteration
+	canceled := make(chan struct{})
+	for value, err := range Seq(context.TODO(), values(100, canceled)) {
+		assert.NoError(t, err)
+		if value == 2 {
+			break
+		}
+	}
+	<-canceled
+
+	// Errors are yielded
+	failed := errors.New("failed")
+	count := 0
+	for _, err := range Seq(context.TODO(), func(ctx context.Context, ch chan<- int) error {
+		return failed
+	}) {
+		assert.Equal(t, failed, err)
+		count++
+	}
+	assert.Equal(t, 1, count)
+
+	// The context's error is yielded if the context is done before all values are read
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var last error
+	for value, err := range Seq(ctx, values(100, make(chan struct{}))) {
+		if value == 2 {
+			cancel()
+		}
+		last = err
+	}
+	assert.Equal(t, context.Canceled, last)
+}
@@ -15,7 +15,10 @@
 package net
 
 import (
+	"context"
+	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"sync"
 )
 
@@ -23,26 +26,30 @@ import (
 type Address string
 
 // Connect creates a gRPC client connection to the given address
-func Connect(address Address) (*grpc.ClientConn, error) {
+// The given dial options are applied in addition to the default options.
+func Connect(address Address, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	return grpc.Dial(
 		string(address),
-		grpc.WithInsecure())
+		append([]grpc.DialOption{grpc.WithInsecure()}, opts...)...)
 }
 
 // NewConns returns a new gRPC client connection manager
-func NewConns(address Address) *Conns {
+// The given dial options are applied to each connection created by the manager.
+func NewConns(address Address, opts ...grpc.DialOption) *Conns {
 	return &Conns{
-		Address: address,
-		leader:  address,
+		Address:  address,
+		leader:   address,
+		dialOpts: opts,
 	}
 }
 
 // Conns is a gRPC client connection manager
 type Conns struct {
-	Address Address
-	leader  Address
-	conn    *grpc.ClientConn
-	mu      sync.RWMutex
+	Address  Address
+	leader   Address
+	conn     *grpc.ClientConn
+	dialOpts []grpc.DialOption
+	mu       sync.RWMutex
 }
 
 // Connect gets the connection to the service
@@ -62,7 +69,7 @@ func (c *Conns) Connect() (*grpc.ClientConn, error) {
 		return conn, nil
 	}
 
-	conn, err := Connect(c.leader)
+	conn, err := Connect(c.leader, c.dialOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +77,52 @@ func (c *Conns) Connect() (*grpc.ClientConn, error) {
 	return conn, nil
 }
 
+// Use calls the given function with the connection to the service
+// The connection is not closed by Reconnect while the function is running.
+func (c *Conns) Use(f func(conn *grpc.ClientConn) error) error {
+	for {
+		if _, err := c.Connect(); err != nil {
+			return err
+		}
+		c.mu.RLock()
+		conn := c.conn
+		if conn != nil {
+			defer c.mu.RUnlock()
+			return f(conn)
+		}
+		// The connection was closed by a concurrent Reconnect
+		c.mu.RUnlock()
+	}
+}
+
+// WaitForReady connects to the service and waits for the connection to become ready
+// An error is returned if the connection fails or the context is done before the connection is ready.
+func (c *Conns) WaitForReady(ctx context.Context) error {
+	conn, err := c.Connect()
+	if err != nil {
+		return err
+	}
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return fmt.Errorf("failed to connect to %s", c.Leader())
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+}
+
+// Leader returns the address to which the client is connected
+func (c *Conns) Leader() Address {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.leader
+}
+
 // Reconnect reconnects the client to the given leader if necessary
 func (c *Conns) Reconnect(leader Address) {
 	if leader == "" {
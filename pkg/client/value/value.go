@@ -12,12 +12,18 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package value provides a distributed atomic value primitive
+//
+// The value service does not return the previous value from a set, so GetAndSet reads the value and sets it
+// on the condition that its version has not changed, retrying on conflicts. The first set of a value cannot
+// be made conditional, so concurrent callers may both observe an empty previous value.
 package value
 
 import (
 	"context"
 	"github.com/atomix/api/proto/atomix/headers"
 	api "github.com/atomix/api/proto/atomix/value"
+	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/util"
 	"google.golang.org/grpc"
@@ -42,6 +48,9 @@ type Value interface {
 	// Get gets the current value and version
 	Get(ctx context.Context) ([]byte, uint64, error)
 
+	// GetAndSet sets the current value and returns the previous value
+	GetAndSet(ctx context.Context, value []byte) ([]byte, error)
+
 	// Watch watches the value for changes
 	Watch(ctx context.Context, ch chan<- *Event) error
 }
@@ -150,6 +159,21 @@ func (v *value) Get(ctx context.Context) ([]byte, uint64, error) {
 	return response.Value, response.Version, nil
 }
 
+func (v *value) GetAndSet(ctx context.Context, value []byte) ([]byte, error) {
+	for {
+		prev, version, err := v.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		_, err = v.Set(ctx, value, IfVersion(version))
+		if err == nil {
+			return prev, nil
+		} else if !errors.IsConflict(err) {
+			return nil, err
+		}
+	}
+}
+
 func (v *value) Watch(ctx context.Context, ch chan<- *Event) error {
 	stream, err := v.instance.DoCommandStream(ctx, func(ctx context.Context, conn *grpc.ClientConn, header *headers.RequestHeader) (interface{}, error) {
 		client := api.NewValueServiceClient(conn)
@@ -16,10 +16,12 @@ package value
 
 import (
 	"context"
+	"fmt"
 	"github.com/lucasbfernandes/go-client/pkg/client/errors"
 	"github.com/lucasbfernandes/go-client/pkg/client/primitive"
 	"github.com/lucasbfernandes/go-client/pkg/client/test"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
@@ -130,3 +132,61 @@ func TestValue(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, val)
 }
+
+func TestValueGetAndSet(t *testing.T) {
+	partitions, closers := test.StartTestPartitions(1)
+	defer test.StopTestPartitions(closers)
+
+	name := primitive.NewName("default", "test", "default", "test")
+
+	sessions, err := test.OpenSessions(partitions)
+	assert.NoError(t, err)
+	defer test.CloseSessions(sessions)
+
+	value, err := New(context.TODO(), name, sessions)
+	assert.NoError(t, err)
+
+	prev, err := value.GetAndSet(context.TODO(), []byte("init"))
+	assert.NoError(t, err)
+	assert.Nil(t, prev)
+
+	prev, err = value.GetAndSet(context.TODO(), []byte("foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "init", string(prev))
+
+	n := 5
+	prevs := make(chan string, n*5)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		sessions, err := test.OpenSessions(partitions)
+		assert.NoError(t, err)
+		defer test.CloseSessions(sessions)
+
+		swapper, err := New(context.TODO(), name, sessions)
+		assert.NoError(t, err)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				prev, err := swapper.GetAndSet(context.TODO(), []byte(fmt.Sprintf("%d-%d", i, j)))
+				assert.NoError(t, err)
+				prevs <- string(prev)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(prevs)
+
+	// Each value must have been replaced exactly once, except the final value
+	replaced := make(map[string]bool)
+	for prev := range prevs {
+		assert.False(t, replaced[prev], "value %s replaced more than once", prev)
+		replaced[prev] = true
+	}
+	current, _, err := value.Get(context.TODO())
+	assert.NoError(t, err)
+	assert.False(t, replaced[string(current)])
+	assert.True(t, replaced["foo"])
+	assert.Len(t, replaced, n*5)
+}